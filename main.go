@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 
 	"github.com/rogep/s3-tui/pkg/awslib"
 	"github.com/rogep/s3-tui/pkg/gui"
@@ -12,12 +14,44 @@ import (
 )
 
 func main() {
-	envPtr, credPtr, profilePtr := utils.ParseFlags()
+	flags := utils.ParseFlags()
+	awslib.MFAPrompt = gui.MFAPrompt
+	if err := gui.SetColumns(*flags.Columns); err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	}
 
 	var cfg aws.Config
 
-	cfg, envName := awslib.InitCredentials(flag.CommandLine, envPtr, credPtr, profilePtr)
-	s3Client := s3.NewFromConfig(cfg)
-	s := awslib.NewS3Handler(*s3Client)
-	gui.S3Gui(s, envName)
+	cfg, envName, endpoint, err := awslib.InitCredentials(flag.CommandLine, flags.Env, flags.Cred, flags.Profile, flags.Region)
+	if errors.Is(err, awslib.ErrUsage) {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Println("Error loading AWS credentials:", err)
+		os.Exit(1)
+	}
+	// flags win over whatever the profile/environment said
+	endpointFlags := awslib.EndpointOptions{
+		URL:                *flags.Endpoint,
+		PathStyle:          *flags.PathStyle,
+		InsecureSkipVerify: *flags.Insecure,
+		CABundle:           *flags.CABundle,
+	}
+	s, err := awslib.NewS3HandlerFromConfig(cfg, endpoint.Merge(endpointFlags))
+	if err != nil {
+		fmt.Println("Error creating S3 client:", err)
+		os.Exit(1)
+	}
+
+	// used when swapping profiles from inside the gui
+	connect := func(profile string) (awslib.ObjectStore, error) {
+		return awslib.ConnectProfile(profile, *flags.Region, endpointFlags)
+	}
+	if err := gui.S3Gui(s, envName, connect); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
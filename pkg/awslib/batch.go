@@ -0,0 +1,220 @@
+package awslib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// objects worked on at once by the per-object batch operations
+const batchWorkers = 8
+
+// StorageClasses are the classes objects can be moved between by hand
+var StorageClasses = []string{
+	"STANDARD",
+	"STANDARD_IA",
+	"ONEZONE_IA",
+	"INTELLIGENT_TIERING",
+	"GLACIER_IR",
+	"GLACIER",
+	"DEEP_ARCHIVE",
+}
+
+// BatchSummary describes what a bulk operation did
+type BatchSummary struct {
+	Done   int
+	Failed map[string]error
+}
+
+func (b BatchSummary) String() string {
+	msg := fmt.Sprintf("%d done", b.Done)
+	if len(b.Failed) > 0 {
+		msg += fmt.Sprintf(", %d failed", len(b.Failed))
+	}
+	return msg
+}
+
+// CopyJob is one object to copy within a bucket. Size is as listed, objects
+// over 5 GiB are copied part by part.
+type CopyJob struct {
+	From string
+	To   string
+	Size int64
+}
+
+// runBatch calls fn for every key on a few goroutines. Keys that fail are
+// collected in the summary. An error that says the rest will fail too (bad
+// credentials, unknown errors) stops the batch and is returned.
+func runBatch(keys []string, progress ProgressFunc, fn func(ctx context.Context, key string) error) (BatchSummary, error) {
+	summary := BatchSummary{Failed: map[string]error{}}
+	tracker := newProgressTracker(int64(len(keys)), progress)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var once sync.Once
+	var abortErr error
+	queue := make(chan string)
+
+	for i := 0; i < batchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				err := fn(ctx, key)
+				mu.Lock()
+				if err != nil {
+					summary.Failed[key] = err
+					if ActionFor(err) == Abort {
+						once.Do(func() {
+							abortErr = err
+							cancel()
+						})
+					}
+				} else {
+					summary.Done++
+				}
+				mu.Unlock()
+				tracker.add(1)
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- key
+	}
+	close(queue)
+	wg.Wait()
+
+	return summary, abortErr
+}
+
+// CopyKeys copies objects server side, keeping their metadata
+func (s *S3Handler) CopyKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error) {
+	byKey := map[string]CopyJob{}
+	var from []string
+	for _, j := range jobs {
+		byKey[j.From] = j
+		from = append(from, j.From)
+	}
+	// progress is counted in keys by runBatch
+	bytes := newProgressTracker(0, nil)
+	return runBatch(from, progress, func(ctx context.Context, key string) error {
+		j := byKey[key]
+		size, err := s.copySize(ctx, bucket, key, j.Size)
+		if err != nil {
+			return opError("copy", bucket, key, err)
+		}
+		return opError("copy", bucket, key, s.copyObject(ctx, bucket, key, bucket, j.To, size, "", bytes))
+	})
+}
+
+// MoveKeys copies objects then deletes the originals that copied cleanly
+func (s *S3Handler) MoveKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error) {
+	return moveKeys(s, bucket, jobs, progress)
+}
+
+// TagKeys replaces the tags on every key with tags
+func (s *S3Handler) TagKeys(bucket string, keys []string, tags map[string]string, progress ProgressFunc) (BatchSummary, error) {
+	var tagSet []types.Tag
+	for _, k := range sortedTagKeys(tags) {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return runBatch(keys, progress, func(ctx context.Context, key string) error {
+		_, err := s.client(bucket).PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(bucket),
+			Key:     aws.String(key),
+			Tagging: &types.Tagging{TagSet: tagSet},
+		})
+		return opError("tag", bucket, key, err)
+	})
+}
+
+// ChangeStorageClass copies every key over itself with a new storage class.
+// sizes are as listed, objects over 5 GiB are copied part by part. Objects
+// already in GLACIER or DEEP_ARCHIVE have to be restored first.
+func (s *S3Handler) ChangeStorageClass(bucket string, keys []string, sizes map[string]int64, class string, progress ProgressFunc) (BatchSummary, error) {
+	bytes := newProgressTracker(0, nil)
+	return runBatch(keys, progress, func(ctx context.Context, key string) error {
+		size, err := s.copySize(ctx, bucket, key, sizes[key])
+		if err != nil {
+			return opError("change storage class of", bucket, key, err)
+		}
+		return opError("change storage class of", bucket, key, s.copyObject(ctx, bucket, key, bucket, key, size, class, bytes))
+	})
+}
+
+// ParseTags reads "key=value, other=value" as typed into the ui
+func ParseTags(text string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(text, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		tags[k] = strings.TrimSpace(v)
+	}
+	// S3's limit per object
+	if len(tags) > 10 {
+		return nil, fmt.Errorf("S3 allows at most 10 tags per object, got %d", len(tags))
+	}
+	return tags, nil
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// moveKeys is MoveKeys for anything that can copy and delete, the deletes go
+// through DeleteKeys so they're batched. Only keys the copy says it did are
+// deleted, and nothing is if the copy was cut short, since the keys it never
+// got to aren't in its summary at all.
+func moveKeys(store interface {
+	CopyKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error)
+	DeleteKeys(bucket string, keys []string, progress ProgressFunc) (BatchSummary, error)
+}, bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error) {
+	summary, err := store.CopyKeys(bucket, jobs, progress)
+	if err != nil {
+		return summary, err
+	}
+	var copied []string
+	for _, j := range jobs {
+		if _, failed := summary.Failed[j.From]; !failed && j.From != j.To {
+			copied = append(copied, j.From)
+		}
+	}
+	if len(copied) == 0 {
+		return summary, nil
+	}
+	deleted, err := store.DeleteKeys(bucket, copied, nil)
+	// a copy left behind is still a failed move
+	for k, e := range deleted.Failed {
+		summary.Failed[k] = e
+		summary.Done--
+	}
+	return summary, err
+}
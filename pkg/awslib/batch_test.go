@@ -0,0 +1,175 @@
+package awslib
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestChangeStorageClassCopiesInPlace(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]http.Header{}
+	s := testHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			return
+		}
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+	})
+	s.regionalClient = nil
+
+	summary, err := s.ChangeStorageClass("bucket", []string{"a.txt", "dir/b.txt"}, nil, "STANDARD_IA", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Done != 2 || len(summary.Failed) != 0 {
+		t.Fatalf("summary = %s", summary)
+	}
+	for _, key := range []string{"a.txt", "dir/b.txt"} {
+		h, ok := seen["/bucket/"+key]
+		if !ok {
+			t.Errorf("%s wasn't copied", key)
+			continue
+		}
+		if got := h.Get("X-Amz-Copy-Source"); got != "/bucket/"+key {
+			t.Errorf("%s copy source = %q", key, got)
+		}
+		if got := h.Get("X-Amz-Storage-Class"); got != "STANDARD_IA" {
+			t.Errorf("%s storage class = %q", key, got)
+		}
+		if got := h.Get("X-Amz-Metadata-Directive"); got != "COPY" {
+			t.Errorf("%s metadata directive = %q", key, got)
+		}
+	}
+}
+
+func TestMoveKeysAbortedDeletesNothingUncopied(t *testing.T) {
+	f := newFakeS3("b")
+	var jobs []CopyJob
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("src/%03d", i)
+		f.put("b", key, []byte(key))
+		jobs = append(jobs, CopyJob{From: key, To: "dst/" + key[len("src/"):]})
+	}
+	// an error the rest of the batch would get too, so it stops
+	f.fail = func(op string, bucket string, key string) (string, int) {
+		if op == "CopyObject" && key == "dst/004" {
+			return "InvalidRequest", http.StatusBadRequest
+		}
+		return "", 0
+	}
+	s := f.handler(t)
+
+	summary, err := s.MoveKeys("b", jobs, nil)
+	if err == nil {
+		t.Fatalf("move wasn't stopped, summary = %s", summary)
+	}
+	if n := f.count("DeleteObjects") + f.count("DeleteObject"); n != 0 {
+		t.Errorf("%d deletes after the copy was stopped", n)
+	}
+	for _, j := range jobs {
+		_, src := f.get("b", j.From)
+		_, dst := f.get("b", j.To)
+		if !src && !dst {
+			t.Errorf("%s is gone without being copied", j.From)
+		}
+	}
+}
+
+func TestCopyKeysMultipart(t *testing.T) {
+	oldMax, oldPart := maxCopySize, copyPartSize
+	maxCopySize, copyPartSize = 10, 4
+	t.Cleanup(func() { maxCopySize, copyPartSize = oldMax, oldPart })
+
+	f := newFakeS3("b")
+	big := []byte("0123456789abc")
+	f.put("b", "big.bin", big)
+	f.put("b", "small.txt", []byte("small"))
+	s := f.handler(t)
+
+	summary, err := s.CopyKeys("b", []CopyJob{
+		{From: "big.bin", To: "copy/big.bin", Size: int64(len(big))},
+		{From: "small.txt", To: "copy/small.txt", Size: 5},
+	}, nil)
+	if err != nil || len(summary.Failed) != 0 {
+		t.Fatalf("copy: %s, %v, %v", summary, err, summary.Failed)
+	}
+	if data, _ := f.get("b", "copy/big.bin"); !bytes.Equal(data, big) {
+		t.Errorf("copy/big.bin = %q", data)
+	}
+	// 13 bytes in parts of 4
+	if n := f.count("UploadPartCopy"); n != 4 {
+		t.Errorf("%d parts copied, want 4", n)
+	}
+
+	summary, err = s.ChangeStorageClass("b", []string{"big.bin"}, map[string]int64{"big.bin": int64(len(big))}, "STANDARD_IA", nil)
+	if err != nil || len(summary.Failed) != 0 {
+		t.Fatalf("change storage class: %s, %v, %v", summary, err, summary.Failed)
+	}
+	if n := f.count("UploadPartCopy"); n != 8 {
+		t.Errorf("%d parts copied, want 8", n)
+	}
+	if data, _ := f.get("b", "big.bin"); !bytes.Equal(data, big) {
+		t.Errorf("big.bin = %q after changing its class", data)
+	}
+	if got := f.buckets["b"]["big.bin"].headers.Get("X-Amz-Storage-Class"); got != "STANDARD_IA" {
+		t.Errorf("storage class = %q", got)
+	}
+}
+
+func TestMemoryStoreMoveAndTag(t *testing.T) {
+	m := NewMemoryStore()
+	m.Put("b", "a.txt", []byte("a"))
+	m.Put("b", "dir/c.txt", []byte("c"))
+
+	summary, err := m.MoveKeys("b", []CopyJob{
+		{From: "a.txt", To: "moved/a.txt"},
+		{From: "missing.txt", To: "moved/missing.txt"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Done != 1 || ErrorKindOf(summary.Failed["missing.txt"]) != KindNotFound {
+		t.Fatalf("summary = %s, failed = %v", summary, summary.Failed)
+	}
+	if _, ok := m.Get("b", "a.txt"); ok {
+		t.Error("a.txt is still there after moving")
+	}
+	if data, ok := m.Get("b", "moved/a.txt"); !ok || string(data) != "a" {
+		t.Errorf("moved/a.txt = %q, %v", data, ok)
+	}
+
+	if _, err := m.TagKeys("b", []string{"moved/a.txt", "dir/c.txt"}, map[string]string{"team": "data"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Tags("b", "dir/c.txt")["team"]; got != "data" {
+		t.Errorf("dir/c.txt team tag = %q", got)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags(" team = data, env=prod,, empty=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"team": "data", "env": "prod", "empty": ""}
+	if len(tags) != len(want) {
+		t.Fatalf("got %v", tags)
+	}
+	for k, v := range want {
+		if tags[k] != v {
+			t.Errorf("%s = %q, want %q", k, tags[k], v)
+		}
+	}
+
+	for _, bad := range []string{"novalue", "=value", "1=1,2=2,3=3,4=4,5=5,6=6,7=7,8=8,9=9,10=10,11=11"} {
+		if _, err := ParseTags(bad); err == nil {
+			t.Errorf("ParseTags(%q) didn't fail", bad)
+		}
+	}
+}
@@ -1,129 +1,137 @@
 package awslib
 
 import (
-	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 )
 
-func InitCredentials(flag *flag.FlagSet, envPtr *bool, credPtr *bool, profilePtr *string) (aws.Config, string) {
-	var cfg aws.Config
-	var envName string
-
+// InitCredentials loads credentials from wherever the flags point and returns
+// any endpoint settings that came with them
+func InitCredentials(flag *flag.FlagSet, envPtr *bool, credPtr *bool, profilePtr *string, regionPtr *string) (aws.Config, string, EndpointOptions, error) {
 	if (len(flag.Args()) > 3 || len(flag.Args()) == 1) && *envPtr {
-		fmt.Println("Positional arguments can only be AWS Access key, secret access key and SSO and require the -E flag")
-		flag.Usage()
-		os.Exit(1)
+		return aws.Config{}, "", EndpointOptions{}, ErrUsage
 	} else if len(flag.Args()) > 1 && !*envPtr {
-		fmt.Println("Positional arguments can only be AWS Access key, secret access key and SSO and require the -E flag")
-		flag.Usage()
-		os.Exit(1)
-	} else if len(flag.Args()) == 2 && *credPtr {
-		cfg, _ = config.LoadDefaultConfig(context.TODO(),
-			config.WithRegion("ap-southeast-2"),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(flag.Args()[0], flag.Args()[1], "")),
-		)
-		envName = "cli"
+		return aws.Config{}, "", EndpointOptions{}, ErrUsage
+	}
+
+	region := resolveRegion(*regionPtr, "")
+
+	if len(flag.Args()) == 2 && *credPtr {
+		cfg, err := loadConfig(region, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(flag.Args()[0], flag.Args()[1], "")))
+		return cfg, "cli", EndpointOptions{}, err
 	} else if len(flag.Args()) == 3 && *credPtr {
-		cfg, _ = config.LoadDefaultConfig(context.TODO(),
-			config.WithRegion("ap-southeast-2"),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(flag.Args()[0], flag.Args()[1], flag.Args()[2])),
-		)
-		envName = "cli"
+		cfg, err := loadConfig(region, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(flag.Args()[0], flag.Args()[1], flag.Args()[2])))
+		return cfg, "cli", EndpointOptions{}, err
 	} else if *envPtr {
-		// TODO: remove SSO support -- i dont even use it when i use s3
 		awsAccessKey := os.Getenv("AWS_ACCESS_KEY_ID")
 		awsSecretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
-		awsSSOKey := os.Getenv("AWS_SSO_SOMETHING")
-		cfg, _ = config.LoadDefaultConfig(context.TODO(),
-			config.WithRegion("ap-southeast-2"),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(awsAccessKey, awsSecretAccessKey, awsSSOKey)))
-		envName = "Environment Variables"
-	} else if *profilePtr != "" {
-		creds := getAWSCredentialProfiles()
-		found := false
-		var profileNames []string
-		for _, cred := range creds {
-			profileNames = append(profileNames, cred.name)
-			if cred.name == *profilePtr {
-				cfg, _ = config.LoadDefaultConfig(context.TODO(),
-					config.WithRegion("ap-southeast-2"),
-					config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cred.accessKey, cred.secretAccessKey, cred.sso)))
-				envName = cred.name
+		awsSessionToken := os.Getenv("AWS_SESSION_TOKEN")
+		cfg, err := loadConfig(region, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(awsAccessKey, awsSecretAccessKey, awsSessionToken)))
+		return cfg, "Environment Variables", endpointFromEnv(), err
+	}
 
-				found = true
-				break
-			}
-			if !found {
-				panic(fmt.Sprintf("Profile: %s not a valid profile. Found: %s", *profilePtr, profileNames))
-			}
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return aws.Config{}, "", EndpointOptions{}, err
+	}
+	if len(profiles) == 0 {
+		return aws.Config{}, "", EndpointOptions{}, fmt.Errorf("no profiles found in %s or %s", CredentialsFile(), ConfigFile())
+	}
+
+	// -p defaults to "default", if there isn't one use the first profile found
+	var profile Profile
+	if *profilePtr == "" || *profilePtr == "default" {
+		profile, err = FindProfile(profiles, "default")
+		if err != nil {
+			profile, err = profiles[0], nil
 		}
 	} else {
-		creds := getAWSCredentialProfiles()
-		cfg, _ = config.LoadDefaultConfig(context.TODO(),
-			config.WithRegion("ap-southeast-2"),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(creds[0].accessKey, creds[0].secretAccessKey, creds[0].sso)))
-		envName = creds[0].name
+		profile, err = FindProfile(profiles, *profilePtr)
+	}
+	if err != nil {
+		return aws.Config{}, "", EndpointOptions{}, err
 	}
-	return cfg, envName
-}
 
-type awsCreds struct {
-	name            string
-	accessKey       string
-	secretAccessKey string
-	sso             string
+	cfg, err := ProfileConfig(profile, *regionPtr)
+	return cfg, profile.Name, profile.endpoint(), err
 }
 
-func getAWSCredentialProfiles() []awsCreds {
-	awsCredentialsFile := os.Getenv("HOME") + "/.aws/credentials"
-	file, err := os.Open(awsCredentialsFile)
+// ConnectProfile builds a handler for a named profile, e.g. when swapping
+// credentials from inside the gui. override goes on top of the profile's own
+// endpoint settings, the same as the command line flags do.
+func ConnectProfile(name string, regionFlag string, override EndpointOptions) (*S3Handler, error) {
+	profiles, err := LoadProfiles()
 	if err != nil {
-		fmt.Println("Error opening AWS credentials file:", err)
-		panic(err)
+		return nil, err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
+	profile, err := FindProfile(profiles, name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ProfileConfig(profile, regionFlag)
+	if err != nil {
+		return nil, err
+	}
+	return NewS3HandlerFromConfig(cfg, profile.endpoint().Merge(override))
+}
 
-	var credStruct awsCreds
-	var profiles []awsCreds
+// ProfileConfig builds the aws config for a profile from LoadProfiles. Plain
+// access key profiles are used as is, anything fancier (role_arn, sso_session,
+// credential_process, ...) is handed to the SDK to build the provider chain.
+func ProfileConfig(profile Profile, regionFlag string) (aws.Config, error) {
+	region := resolveRegion(regionFlag, profile.Get("region"))
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	accessKey := profile.Get("aws_access_key_id")
+	secretKey := profile.Get("aws_secret_access_key")
+	if accessKey != "" && secretKey != "" && profile.Get("role_arn") == "" {
+		provider := credentials.NewStaticCredentialsProvider(accessKey, secretKey, profile.sessionToken())
+		return loadConfig(region, config.WithCredentialsProvider(provider))
+	}
+	if !profile.hasCredentialSource() {
+		return aws.Config{}, fmt.Errorf("profile %q has no credentials: expected access keys, role_arn, sso settings or credential_process", profile.Name)
+	}
 
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			profile := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
-			credStruct = awsCreds{}
-			credStruct.name = profile
-		} else if strings.HasPrefix(line, "aws_access_key_id") {
-			credStruct.accessKey = strings.Split(line, " ")[2]
-		} else if strings.HasPrefix(line, "aws_secret_access_key") {
-			credStruct.secretAccessKey = strings.Split(line, " ")[2]
-		} else if strings.HasPrefix(line, "sso") {
-			credStruct.sso = strings.Split(line, " ")[2]
-		} else if line == "\n" || line == "" {
-			if credStruct != (awsCreds{}) {
-				profiles = append(profiles, credStruct)
-				credStruct = awsCreds{}
+	mfaSerial := profile.Get("mfa_serial")
+	return loadConfig(region,
+		config.WithSharedConfigProfile(profile.Name),
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			o.TokenProvider = func() (string, error) {
+				return MFAPrompt(mfaSerial)
 			}
+		}),
+	)
+}
+
+// resolveRegion picks, in order, the -region flag, AWS_REGION/AWS_DEFAULT_REGION,
+// then the profile's region. Empty means let the SDK decide.
+func resolveRegion(regionFlag string, profileRegion string) string {
+	for _, region := range []string{regionFlag, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), profileRegion} {
+		if region != "" {
+			return region
 		}
 	}
-	// handle EOF case
-	if credStruct != (awsCreds{}) {
-		profiles = append(profiles, credStruct)
-	}
+	return ""
+}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Println("Error reading AWS credentials file:", err)
-		panic(err)
+// loadConfig builds the aws config, falling back to defaultRegion if neither
+// region nor the SDK's own lookup came up with one
+func loadConfig(region string, opts ...func(*config.LoadOptions) error) (aws.Config, error) {
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
 	}
-	return profiles
+	return cfg, nil
 }
@@ -0,0 +1,122 @@
+package awslib
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// the most keys DeleteObjects takes in one request
+	deleteBatchSize = 1000
+	deleteWorkers   = 4
+)
+
+// PrefixListing is everything under a prefix, used to show what a recursive
+// delete is about to do before doing it
+type PrefixListing struct {
+	Keys  []string
+	Sizes []int64 // alongside Keys
+	Bytes int64
+}
+
+// ListPrefix lists every key under prefix, including folder markers, with no
+// delimiter so nested folders are included
+func (s *S3Handler) ListPrefix(bucket string, prefix string) (PrefixListing, error) {
+	var listing PrefixListing
+	paginator := s3.NewListObjectsV2Paginator(s.client(bucket), urlEncoded(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}))
+	for paginator.HasMorePages() {
+		output, err := nextPage(paginator)
+		if err != nil {
+			return listing, opError("list", bucket, prefix, err)
+		}
+		for _, obj := range output.Contents {
+			listing.Keys = append(listing.Keys, aws.ToString(obj.Key))
+			listing.Sizes = append(listing.Sizes, obj.Size)
+			listing.Bytes += obj.Size
+		}
+	}
+	return listing, nil
+}
+
+// DeleteKeys removes keys in batches of 1000, a few batches at a time. Keys S3
+// refuses to delete end up in the summary's Failed map. If a whole batch fails
+// in a way that will keep failing (bad credentials etc) the remaining batches
+// aren't sent and that error is returned.
+func (s *S3Handler) DeleteKeys(bucket string, keys []string, progress ProgressFunc) (BatchSummary, error) {
+	summary := BatchSummary{Failed: map[string]error{}}
+	tracker := newProgressTracker(int64(len(keys)), progress)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var once sync.Once
+	var abortErr error
+	batches := make(chan []string)
+
+	for i := 0; i < deleteWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if ctx.Err() != nil {
+					continue
+				}
+				objects := make([]types.ObjectIdentifier, len(batch))
+				for i, key := range batch {
+					objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+				}
+				output, err := s.client(bucket).DeleteObjects(ctx, &s3.DeleteObjectsInput{
+					Bucket: aws.String(bucket),
+					Delete: &types.Delete{Objects: objects, Quiet: true},
+				})
+
+				mu.Lock()
+				if err != nil {
+					for _, key := range batch {
+						summary.Failed[key] = opError("delete", bucket, key, err)
+					}
+					if ActionFor(summary.Failed[batch[0]]) == Abort {
+						once.Do(func() {
+							abortErr = opError("delete", bucket, "", err)
+							cancel()
+						})
+					}
+				} else {
+					// quiet mode only reports the keys that failed
+					for _, e := range output.Errors {
+						key := aws.ToString(e.Key)
+						summary.Failed[key] = opError("delete", bucket, key, &smithy.GenericAPIError{
+							Code:    aws.ToString(e.Code),
+							Message: aws.ToString(e.Message),
+						})
+					}
+					summary.Done += len(batch) - len(output.Errors)
+				}
+				mu.Unlock()
+				tracker.add(int64(len(batch)))
+			}
+		}()
+	}
+
+	for start := 0; start < len(keys) && ctx.Err() == nil; start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batches <- keys[start:end]
+	}
+	close(batches)
+	wg.Wait()
+
+	return summary, abortErr
+}
@@ -0,0 +1,112 @@
+package awslib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+type deleteRequest struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+	Quiet bool `xml:"Quiet"`
+}
+
+func TestDeleteKeysBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+	s := testHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["delete"]; !ok || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			return
+		}
+		var req deleteRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		batchSizes = append(batchSizes, len(req.Objects))
+		mu.Unlock()
+
+		// every key called locked/... is refused
+		w.Write([]byte(`<DeleteResult>`))
+		for _, obj := range req.Objects {
+			if len(obj.Key) > 7 && obj.Key[:7] == "locked/" {
+				fmt.Fprintf(w, `<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`, obj.Key)
+			}
+		}
+		w.Write([]byte(`</DeleteResult>`))
+	})
+	// one region is plenty here
+	s.regionalClient = nil
+
+	var keys []string
+	for i := 0; i < 2500; i++ {
+		keys = append(keys, fmt.Sprintf("data/%04d", i))
+	}
+	keys = append(keys, "locked/a", "locked/b")
+
+	var done, total int64
+	summary, err := s.DeleteKeys("bucket", keys, func(d, t int64) {
+		mu.Lock()
+		done, total = d, t
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Done != 2500 || len(summary.Failed) != 2 {
+		t.Errorf("summary = %s", summary)
+	}
+	if ErrorKindOf(summary.Failed["locked/a"]) != KindAccessDenied {
+		t.Errorf("locked/a failed with %v", summary.Failed["locked/a"])
+	}
+
+	if len(batchSizes) != 3 {
+		t.Fatalf("sent %d batches, want 3", len(batchSizes))
+	}
+	sent := 0
+	for _, n := range batchSizes {
+		if n > deleteBatchSize {
+			t.Errorf("batch of %d keys", n)
+		}
+		sent += n
+	}
+	if sent != len(keys) || done != total || total != int64(len(keys)) {
+		t.Errorf("sent %d keys, progress %d/%d", sent, done, total)
+	}
+}
+
+func TestDeleteKeysStopsOnBadCredentials(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	s := testHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>InvalidAccessKeyId</Code><Message>nope</Message></Error>`))
+	})
+	s.regionalClient = nil
+
+	keys := make([]string, deleteBatchSize*(deleteWorkers*3))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+	}
+	summary, err := s.DeleteKeys("bucket", keys, nil)
+	if ErrorKindOf(err) != KindExpired {
+		t.Fatalf("err = %v", err)
+	}
+	if summary.Done != 0 {
+		t.Errorf("summary = %s", summary)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls >= deleteWorkers*3 {
+		t.Errorf("kept going for %d batches after the credentials were refused", calls)
+	}
+}
@@ -0,0 +1,278 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	downloadWorkers int = 4
+	// in-flight downloads are written here and renamed once complete, which also
+	// lets us pick up where we left off if the app is killed halfway through
+	partialSuffix = ".s3tui-part"
+	// and the ETag of the object a partial file is of, so it's only resumed if
+	// the object is still the same one
+	etagSuffix = ".s3tui-etag"
+)
+
+// DownloadSummary describes what a Download call did
+type DownloadSummary struct {
+	Downloaded int
+	// keys already downloaded, the same size and modified time as the object
+	Skipped []string
+	Resumed int
+	Bytes   int64
+	Failed  map[string]error
+}
+
+func (d DownloadSummary) String() string {
+	msg := fmt.Sprintf("%d downloaded (%d resumed), %d skipped", d.Downloaded, d.Resumed, len(d.Skipped))
+	if len(d.Failed) > 0 {
+		msg += fmt.Sprintf(", %d failed", len(d.Failed))
+	}
+	return msg
+}
+
+type downloadJob struct {
+	key      string
+	dest     string
+	size     int64
+	etag     string
+	modified time.Time
+}
+
+// Download copies key into localDir. If key is a folder ("a/b/") everything
+// beneath it is downloaded into localDir/b/, keeping the folder layout.
+func (s *S3Handler) Download(bucket string, key string, localDir string, progress ProgressFunc) (DownloadSummary, error) {
+	return s.DownloadKeys(bucket, []string{key}, localDir, progress)
+}
+
+// DownloadKeys is Download for several keys at once, sharing one progress total
+func (s *S3Handler) DownloadKeys(bucket string, keys []string, localDir string, progress ProgressFunc) (DownloadSummary, error) {
+	summary := DownloadSummary{Failed: map[string]error{}}
+
+	var jobs []downloadJob
+	for _, key := range keys {
+		keyJobs, err := s.downloadJobs(bucket, key, localDir)
+		if err != nil {
+			return summary, opError("download", bucket, key, err)
+		}
+		jobs = append(jobs, keyJobs...)
+	}
+
+	var total int64
+	for _, j := range jobs {
+		total += j.size
+	}
+	tracker := newProgressTracker(total, progress)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan downloadJob)
+
+	for i := 0; i < downloadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				resumed, skipped, err := s.downloadFile(bucket, j, tracker)
+				mu.Lock()
+				switch {
+				case err != nil:
+					summary.Failed[j.key] = opError("download", bucket, j.key, err)
+				case skipped:
+					summary.Skipped = append(summary.Skipped, j.key)
+				default:
+					summary.Downloaded++
+					summary.Bytes += j.size
+					if resumed {
+						summary.Resumed++
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	sort.Strings(summary.Skipped)
+	return summary, nil
+}
+
+func (s *S3Handler) downloadJobs(bucket string, key string, localDir string) ([]downloadJob, error) {
+	root, err := filepath.Abs(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(key, "/") {
+		head, err := s.client(bucket).HeadObject(context.TODO(), &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		dest, err := localPath(root, path.Base(key))
+		if err != nil {
+			return nil, err
+		}
+		return []downloadJob{{
+			key:      key,
+			dest:     dest,
+			size:     head.ContentLength,
+			etag:     aws.ToString(head.ETag),
+			modified: aws.ToTime(head.LastModified),
+		}}, nil
+	}
+
+	// keep the selected folder's own name locally, i.e. a/b/ -> localDir/b/
+	parent := path.Dir(strings.TrimSuffix(key, "/")) + "/"
+	if parent == "./" {
+		parent = ""
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client(bucket), urlEncoded(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	}))
+	var jobs []downloadJob
+	for paginator.HasMorePages() {
+		output, err := nextPage(paginator)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range output.Contents {
+			k := *obj.Key
+			// "folder" placeholder objects
+			if strings.HasSuffix(k, "/") {
+				continue
+			}
+			dest, err := localPath(root, strings.TrimPrefix(k, parent))
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, downloadJob{
+				key:      k,
+				dest:     dest,
+				size:     obj.Size,
+				etag:     aws.ToString(obj.ETag),
+				modified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return jobs, nil
+}
+
+// downloadFile fetches a single object, resuming from a partial file if one is
+// lying around from an earlier attempt at the same object. A file already
+// there is only skipped if it's the size of the object and has its modified
+// time, which downloads are given once finished.
+func (s *S3Handler) downloadFile(bucket string, j downloadJob, tracker *progressTracker) (resumed bool, skipped bool, err error) {
+	if info, err := os.Stat(j.dest); err == nil && info.Size() == j.size && sameSecond(info.ModTime(), j.modified) {
+		tracker.add(j.size)
+		return false, true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(j.dest), 0o755); err != nil {
+		return false, false, err
+	}
+
+	partial := j.dest + partialSuffix
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(j.key),
+	}
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil && info.Size() > 0 && info.Size() <= j.size && j.etag != "" {
+		// the partial file is only any good if it's of the object as it is now
+		if started, err := os.ReadFile(j.dest + etagSuffix); err == nil && string(started) == j.etag {
+			offset = info.Size()
+			input.IfMatch = aws.String(j.etag)
+		}
+	}
+
+	if offset == j.size {
+		tracker.add(offset)
+		return true, false, finishDownload(j, partial)
+	}
+	if offset > 0 {
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	output, err := s.client(bucket).GetObject(context.TODO(), input)
+	var respErr *smithyhttp.ResponseError
+	if offset > 0 && errors.As(err, &respErr) && respErr.HTTPStatusCode() == 412 {
+		// object was replaced since it was listed, start again from scratch
+		offset = 0
+		input.Range = nil
+		input.IfMatch = nil
+		output, err = s.client(bucket).GetObject(context.TODO(), input)
+	}
+	if err != nil {
+		return false, false, err
+	}
+	defer output.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if offset == 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		// what's being fetched, to check against before resuming it
+		if err := os.WriteFile(j.dest+etagSuffix, []byte(aws.ToString(output.ETag)), 0o644); err != nil {
+			return false, false, err
+		}
+		if output.LastModified != nil {
+			j.modified = *output.LastModified
+		}
+	}
+	file, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return false, false, err
+	}
+
+	tracker.add(offset)
+	_, err = io.Copy(io.MultiWriter(file, tracker), output.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return offset > 0, false, finishDownload(j, partial)
+}
+
+// finishDownload puts a complete partial file in place, with the object's
+// modified time so it can be told apart from a different file of the same size
+func finishDownload(j downloadJob, partial string) error {
+	if err := os.Rename(partial, j.dest); err != nil {
+		return err
+	}
+	os.Remove(j.dest + etagSuffix)
+	if j.modified.IsZero() {
+		return nil
+	}
+	return os.Chtimes(j.dest, j.modified, j.modified)
+}
+
+// sameSecond compares modified times, which S3 keeps to the second
+func sameSecond(a time.Time, b time.Time) bool {
+	return !b.IsZero() && a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
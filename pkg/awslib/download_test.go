@@ -0,0 +1,110 @@
+package awslib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDownloadResumesTheSameObject(t *testing.T) {
+	f := newFakeS3("b")
+	data := []byte("0123456789abcdefghij")
+	f.put("b", "dir/file.txt", data)
+	// put a while ago, so a file written locally now doesn't share its time
+	f.mu.Lock()
+	obj := f.buckets["b"]["dir/file.txt"]
+	obj.modified = obj.modified.Add(-time.Hour)
+	f.buckets["b"]["dir/file.txt"] = obj
+	f.mu.Unlock()
+	s := f.handler(t)
+	local := t.TempDir()
+	dest := filepath.Join(local, "file.txt")
+
+	// an earlier attempt got part of the way
+	os.WriteFile(dest+partialSuffix, data[:8], 0o644)
+	os.WriteFile(dest+etagSuffix, []byte(etag(data)), 0o644)
+	summary, err := s.Download("b", "dir/file.txt", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Downloaded != 1 || summary.Resumed != 1 {
+		t.Fatalf("summary = %s", summary)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != string(data) {
+		t.Errorf("file.txt = %q", got)
+	}
+	for _, leftover := range []string{dest + partialSuffix, dest + etagSuffix} {
+		if _, err := os.Stat(leftover); err == nil {
+			t.Errorf("%s is still there", filepath.Base(leftover))
+		}
+	}
+
+	// it's there and unchanged now
+	summary, err = s.Download("b", "dir/file.txt", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Downloaded != 0 || !reflect.DeepEqual(summary.Skipped, []string{"dir/file.txt"}) {
+		t.Errorf("second download: %s, skipped %q", summary, summary.Skipped)
+	}
+
+	// a different file the same size isn't taken for it
+	os.WriteFile(dest, []byte("not the same content"), 0o644)
+	summary, err = s.Download("b", "dir/file.txt", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Downloaded != 1 || len(summary.Skipped) != 0 {
+		t.Errorf("download over another file: %s", summary)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != string(data) {
+		t.Errorf("file.txt = %q", got)
+	}
+}
+
+func TestDownloadRestartsAChangedObject(t *testing.T) {
+	f := newFakeS3("b")
+	old := []byte("the old content")
+	f.put("b", "a.txt", []byte("the new content, longer"))
+	f.put("b", "b.txt", []byte("bbbbbbbbbb"))
+	s := f.handler(t)
+	local := t.TempDir()
+
+	// a partial file of what a.txt used to be, and one from before partial
+	// files had their ETag kept
+	os.WriteFile(filepath.Join(local, "a.txt"+partialSuffix), old[:8], 0o644)
+	os.WriteFile(filepath.Join(local, "a.txt"+etagSuffix), []byte(etag(old)), 0o644)
+	os.WriteFile(filepath.Join(local, "b.txt"+partialSuffix), []byte("xxxx"), 0o644)
+
+	summary, err := s.DownloadKeys("b", []string{"a.txt", "b.txt"}, local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Downloaded != 2 || summary.Resumed != 0 || len(summary.Failed) != 0 {
+		t.Fatalf("summary = %s", summary)
+	}
+	for key, want := range map[string]string{"a.txt": "the new content, longer", "b.txt": "bbbbbbbbbb"} {
+		if got, _ := os.ReadFile(filepath.Join(local, key)); string(got) != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	// replaced with another object the same size
+	f.mu.Lock()
+	obj := f.buckets["b"]["b.txt"]
+	obj.data, obj.modified = []byte("BBBBBBBBBB"), obj.modified.Add(time.Minute)
+	f.buckets["b"]["b.txt"] = obj
+	f.mu.Unlock()
+	summary, err = s.Download("b", "b.txt", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Downloaded != 1 || len(summary.Skipped) != 0 {
+		t.Errorf("summary = %s", summary)
+	}
+	if got, _ := os.ReadFile(filepath.Join(local, "b.txt")); string(got) != "BBBBBBBBBB" {
+		t.Errorf("b.txt = %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package awslib
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// EndpointOptions point the s3 client at something other than AWS, e.g. MinIO,
+// LocalStack or Ceph. The zero value talks to AWS as usual.
+type EndpointOptions struct {
+	URL                string // e.g. http://localhost:9000
+	PathStyle          bool   // http://host/bucket/key rather than http://bucket.host/key
+	InsecureSkipVerify bool
+	CABundle           string // path to a PEM file trusted on top of the system roots
+}
+
+// Merge returns e with any settings made in override applied on top
+func (e EndpointOptions) Merge(override EndpointOptions) EndpointOptions {
+	if override.URL != "" {
+		e.URL = override.URL
+	}
+	if override.CABundle != "" {
+		e.CABundle = override.CABundle
+	}
+	e.PathStyle = e.PathStyle || override.PathStyle
+	e.InsecureSkipVerify = e.InsecureSkipVerify || override.InsecureSkipVerify
+	return e
+}
+
+// endpointFromEnv picks up the endpoint variables the AWS CLI understands
+func endpointFromEnv() EndpointOptions {
+	var e EndpointOptions
+	if url := os.Getenv("AWS_ENDPOINT_URL_S3"); url != "" {
+		e.URL = url
+	} else {
+		e.URL = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	e.CABundle = os.Getenv("AWS_CA_BUNDLE")
+	return e
+}
+
+// NewS3Client builds an s3 client from cfg with the endpoint overrides applied
+func NewS3Client(cfg aws.Config, e EndpointOptions) (*s3.Client, error) {
+	if e.InsecureSkipVerify || e.CABundle != "" {
+		tlsConfig, err := e.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		cfg.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			tr.TLSClientConfig = tlsConfig
+		})
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if e.URL != "" {
+			o.BaseEndpoint = aws.String(e.URL)
+		}
+		o.UsePathStyle = e.PathStyle
+	}), nil
+}
+
+func (e EndpointOptions) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: e.InsecureSkipVerify,
+	}
+	if e.CABundle == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(e.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", e.CABundle)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
@@ -0,0 +1,58 @@
+package awslib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestNewS3ClientCustomEndpoint(t *testing.T) {
+	var gotHost, gotPath string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath = r.Host, r.URL.Path
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		// don't sit through retries of the deliberately failing request
+		RetryMaxAttempts: 1,
+	}
+
+	// the test server's certificate is self signed, so it has to be rejected by default
+	client, err := NewS3Client(cfg, EndpointOptions{URL: srv.URL, PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.HeadObject(context.TODO(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a/b.txt")})
+	if err == nil {
+		t.Fatal("expected a certificate error without -insecure")
+	}
+
+	client, err = NewS3Client(cfg, EndpointOptions{URL: srv.URL, PathStyle: true, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.HeadObject(context.TODO(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a/b.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotHost != srv.Listener.Addr().String() || gotPath != "/bucket/a/b.txt" {
+		t.Errorf("request went to %s%s", gotHost, gotPath)
+	}
+}
+
+func TestEndpointOptionsMerge(t *testing.T) {
+	profile := EndpointOptions{URL: "http://minio:9000", CABundle: "/etc/ca.pem"}
+	got := profile.Merge(EndpointOptions{URL: "http://localhost:4566", PathStyle: true})
+	want := EndpointOptions{URL: "http://localhost:4566", PathStyle: true, CABundle: "/etc/ca.pem"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
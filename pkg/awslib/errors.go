@@ -0,0 +1,196 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ErrUsage means the command line didn't make sense, the caller should print usage
+var ErrUsage = errors.New("positional arguments can only be AWS access key, secret access key and session token and require the -E flag")
+
+// ErrorKind is a rough category for an S3 failure, enough for the ui to word
+// the error and pick what to do next
+type ErrorKind int
+
+const (
+	KindUnknown ErrorKind = iota
+	KindNotFound
+	KindAccessDenied
+	KindExpired // the credentials themselves are bad or out of date
+	KindThrottled
+	KindNetwork
+	KindConflict // e.g. the bucket name is already taken
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindAccessDenied:
+		return "access denied"
+	case KindExpired:
+		return "credentials expired or invalid"
+	case KindThrottled:
+		return "throttled"
+	case KindNetwork:
+		return "network error"
+	case KindConflict:
+		return "conflict"
+	}
+	return "error"
+}
+
+// Action is what a caller working through many objects should do about an error
+type Action int
+
+const (
+	Abort Action = iota
+	Retry
+	Skip
+)
+
+// OpError is what every ObjectStore method returns when S3 says no. Op is a
+// short verb ("list", "delete") and Bucket/Key say what it was done to.
+type OpError struct {
+	Op     string
+	Bucket string
+	Key    string
+	Kind   ErrorKind
+	Code   string // the S3 error code when there is one, e.g. AccessDenied
+	Err    error
+}
+
+func (e *OpError) Error() string {
+	target := e.Bucket
+	if e.Key != "" {
+		target += "/" + e.Key
+	}
+	if target == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, target, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Message is the error without the op and target, for when those are shown
+// separately. S3 API errors are boiled down to "Code: message".
+func (e *OpError) Message() string {
+	var apiErr smithy.APIError
+	if errors.As(e.Err, &apiErr) {
+		if msg := apiErr.ErrorMessage(); msg != "" {
+			return apiErr.ErrorCode() + ": " + msg
+		}
+		return apiErr.ErrorCode()
+	}
+	return e.Err.Error()
+}
+
+// Action says whether retrying err could help. Throttling and network blips
+// are worth another go, a missing or forbidden object can be skipped over, and
+// anything wrong with the credentials stops everything.
+func (e *OpError) Action() Action {
+	switch e.Kind {
+	case KindThrottled, KindNetwork:
+		return Retry
+	case KindNotFound, KindAccessDenied, KindConflict:
+		return Skip
+	}
+	return Abort
+}
+
+// opError wraps err with what we were doing. Errors that are already wrapped
+// keep their original context.
+func opError(op string, bucket string, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *OpError
+	if errors.As(err, &existing) {
+		return err
+	}
+	kind, code := classify(err)
+	return &OpError{Op: op, Bucket: bucket, Key: key, Kind: kind, Code: code, Err: err}
+}
+
+// ErrorKindOf categorises any error, wrapped or not
+func ErrorKindOf(err error) ErrorKind {
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		return opErr.Kind
+	}
+	kind, _ := classify(err)
+	return kind
+}
+
+// ActionFor is OpError.Action for any error, unknown errors abort
+func ActionFor(err error) Action {
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		return opErr.Action()
+	}
+	return Abort
+}
+
+func classify(err error) (ErrorKind, string) {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		switch code {
+		case "NoSuchBucket", "NoSuchKey", "NotFound", "NoSuchUpload":
+			return KindNotFound, code
+		case "AccessDenied", "Forbidden", "AllAccessDisabled", "AccountProblem":
+			return KindAccessDenied, code
+		case "ExpiredToken", "ExpiredTokenException", "InvalidAccessKeyId", "InvalidToken",
+			"SignatureDoesNotMatch", "InvalidClientTokenId", "TokenRefreshRequired":
+			return KindExpired, code
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded",
+			"TooManyRequestsException", "ServiceUnavailable", "RequestTimeout", "InternalError":
+			return KindThrottled, code
+		case "BucketAlreadyExists", "BucketAlreadyOwnedByYou", "OperationAborted":
+			return KindConflict, code
+		}
+	}
+
+	// HEAD requests have no body, so all we get is the status code
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		code := ""
+		if apiErr != nil {
+			code = apiErr.ErrorCode()
+		}
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return KindNotFound, code
+		case http.StatusForbidden:
+			return KindAccessDenied, code
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusInternalServerError:
+			return KindThrottled, code
+		case http.StatusConflict:
+			return KindConflict, code
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return KindNetwork, ""
+	}
+	if apiErr != nil {
+		return KindUnknown, apiErr.ErrorCode()
+	}
+	return KindUnknown, ""
+}
+
+// apiError builds the same kind of error S3 would send back, for the in
+// memory store
+func apiError(code string, format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
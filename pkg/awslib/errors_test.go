@@ -0,0 +1,80 @@
+package awslib
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func TestClassifyErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		kind   ErrorKind
+		action Action
+	}{
+		{apiError("NoSuchKey", "gone"), KindNotFound, Skip},
+		{apiError("AccessDenied", "Access Denied"), KindAccessDenied, Skip},
+		{apiError("ExpiredToken", "expired"), KindExpired, Abort},
+		{apiError("SlowDown", "slow down"), KindThrottled, Retry},
+		{apiError("BucketAlreadyExists", "taken"), KindConflict, Skip},
+		{apiError("SomethingNew", "?"), KindUnknown, Abort},
+		// HEAD responses have no body, only the status code to go on
+		{&smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 403}}, Err: errors.New("forbidden")}, KindAccessDenied, Skip},
+		{errors.New("boom"), KindUnknown, Abort},
+	}
+	for _, tt := range tests {
+		err := opError("delete", "bucket", "key", tt.err)
+		if got := ErrorKindOf(err); got != tt.kind {
+			t.Errorf("%v: kind = %v, want %v", tt.err, got, tt.kind)
+		}
+		if got := ActionFor(err); got != tt.action {
+			t.Errorf("%v: action = %v, want %v", tt.err, got, tt.action)
+		}
+	}
+}
+
+func TestOpError(t *testing.T) {
+	cause := apiError("AccessDenied", "Access Denied")
+	err := opError("rename", "alpha", "docs/a.txt", cause)
+
+	if err.Error() != "rename alpha/docs/a.txt: api error AccessDenied: Access Denied" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Code != "AccessDenied" || opErr.Message() != "AccessDenied: Access Denied" {
+		t.Errorf("OpError = %+v", opErr)
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		t.Error("the api error should still be reachable")
+	}
+
+	// wrapping again keeps the original context
+	if again := opError("delete", "beta", "", err); again != err {
+		t.Errorf("rewrapped as %v", again)
+	}
+	if opError("delete", "beta", "", nil) != nil {
+		t.Error("nil errors should stay nil")
+	}
+}
+
+func TestMemoryStoreErrors(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("alpha", "a.txt", []byte("a"))
+
+	_, err := store.PreviewFile("alpha", "missing.txt")
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("PreviewFile error = %v", err)
+	}
+	if opErr.Op != "preview" || opErr.Bucket != "alpha" || opErr.Key != "missing.txt" || opErr.Kind != KindNotFound {
+		t.Errorf("OpError = %+v", opErr)
+	}
+
+	if _, err := store.GetDirectoryStructure("nope", "/", ""); ErrorKindOf(err) != KindNotFound {
+		t.Errorf("listing a missing bucket = %v", err)
+	}
+}
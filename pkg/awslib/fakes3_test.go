@@ -0,0 +1,437 @@
+package awslib
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is just enough of S3 over http to run the handler's listing, copy,
+// multipart and delete calls against. Path style only.
+type fakeS3 struct {
+	mu       sync.Mutex
+	buckets  map[string]map[string]fakeObject
+	uploads  map[string]*fakeUpload
+	nextID   int
+	pageSize int
+	// counts requests by operation, e.g. "CopyObject"
+	calls map[string]int
+	// fail lets a test refuse a request, returning an S3 error code and status
+	fail func(op string, bucket string, key string) (code string, status int)
+}
+
+type fakeObject struct {
+	data     []byte
+	modified time.Time
+	headers  http.Header
+}
+
+type fakeUpload struct {
+	bucket  string
+	key     string
+	parts   map[int][]byte
+	headers http.Header
+}
+
+func newFakeS3(buckets ...string) *fakeS3 {
+	f := &fakeS3{
+		buckets:  map[string]map[string]fakeObject{},
+		uploads:  map[string]*fakeUpload{},
+		pageSize: 1000,
+		calls:    map[string]int{},
+	}
+	for _, b := range buckets {
+		f.buckets[b] = map[string]fakeObject{}
+	}
+	return f
+}
+
+// handler returns an S3Handler talking to f
+func (f *fakeS3) handler(t *testing.T) *S3Handler {
+	s := testHandler(t, f.ServeHTTP)
+	s.regionalClient = nil
+	return s
+}
+
+func (f *fakeS3) put(bucket string, key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket][key] = fakeObject{data: data, modified: time.Now().UTC(), headers: http.Header{}}
+}
+
+func (f *fakeS3) get(bucket string, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.buckets[bucket][key]
+	return obj.data, ok
+}
+
+func (f *fakeS3) keys(bucket string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// operation works out which S3 call r is
+func operation(r *http.Request, key string) string {
+	q := r.URL.Query()
+	_, uploads := q["uploads"]
+	_, del := q["delete"]
+	copying := r.Header.Get("X-Amz-Copy-Source") != ""
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		return "ListObjectsV2"
+	case r.Method == http.MethodPost && del:
+		return "DeleteObjects"
+	case r.Method == http.MethodHead:
+		return "HeadObject"
+	case r.Method == http.MethodGet:
+		return "GetObject"
+	case r.Method == http.MethodPost && uploads:
+		return "CreateMultipartUpload"
+	case r.Method == http.MethodPost && q.Get("uploadId") != "":
+		return "CompleteMultipartUpload"
+	case r.Method == http.MethodPut && q.Get("uploadId") != "" && copying:
+		return "UploadPartCopy"
+	case r.Method == http.MethodPut && q.Get("uploadId") != "":
+		return "UploadPart"
+	case r.Method == http.MethodPut && copying:
+		return "CopyObject"
+	case r.Method == http.MethodPut:
+		return "PutObject"
+	case r.Method == http.MethodDelete && q.Get("uploadId") != "":
+		return "AbortMultipartUpload"
+	case r.Method == http.MethodDelete:
+		return "DeleteObject"
+	}
+	return "Unknown"
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	op := operation(r, key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[op]++
+	if f.fail != nil {
+		if code, status := f.fail(op, bucket, key); code != "" {
+			writeS3Error(w, status, code)
+			return
+		}
+	}
+	objects, ok := f.buckets[bucket]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch op {
+	case "ListObjectsV2":
+		f.list(w, r, objects)
+	case "HeadObject", "GetObject":
+		obj, ok := objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != etag(obj.data) {
+			writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		data := obj.data
+		for k, v := range obj.headers {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", etag(obj.data))
+		w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+		if rng := r.Header.Get("Range"); rng != "" && op == "GetObject" {
+			start, end, ok := parseRange(rng, int64(len(data)))
+			if !ok {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			data = data[start : end+1]
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+		if op == "GetObject" {
+			w.Write(data)
+		}
+	case "PutObject":
+		data, _ := io.ReadAll(r.Body)
+		objects[key] = fakeObject{data: data, modified: time.Now().UTC(), headers: objectHeaders(r.Header)}
+		w.Header().Set("ETag", etag(data))
+	case "CopyObject":
+		src, ok := f.source(w, r)
+		if !ok {
+			return
+		}
+		headers := src.headers
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			headers = objectHeaders(r.Header)
+		}
+		objects[key] = fakeObject{data: src.data, modified: time.Now().UTC(), headers: headers}
+		fmt.Fprintf(w, `<CopyObjectResult><ETag>%s</ETag></CopyObjectResult>`, etag(src.data))
+	case "CreateMultipartUpload":
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = &fakeUpload{bucket: bucket, key: key, parts: map[int][]byte{}, headers: objectHeaders(r.Header)}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, id)
+	case "UploadPart", "UploadPartCopy":
+		upload, ok := f.uploads[r.URL.Query().Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		n, _ := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		var data []byte
+		if op == "UploadPart" {
+			data, _ = io.ReadAll(r.Body)
+		} else {
+			src, ok := f.source(w, r)
+			if !ok {
+				return
+			}
+			data = src.data
+			if rng := r.Header.Get("X-Amz-Copy-Source-Range"); rng != "" {
+				start, end, _ := parseRange(rng, int64(len(data)))
+				data = data[start : end+1]
+			}
+		}
+		upload.parts[n] = data
+		if op == "UploadPart" {
+			w.Header().Set("ETag", etag(data))
+		} else {
+			fmt.Fprintf(w, `<CopyPartResult><ETag>%s</ETag></CopyPartResult>`, etag(data))
+		}
+	case "CompleteMultipartUpload":
+		id := r.URL.Query().Get("uploadId")
+		upload, ok := f.uploads[id]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var numbers []int
+		for n := range upload.parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data bytes.Buffer
+		for _, n := range numbers {
+			data.Write(upload.parts[n])
+		}
+		delete(f.uploads, id)
+		objects[key] = fakeObject{data: data.Bytes(), modified: time.Now().UTC(), headers: upload.headers}
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Key>%s</Key><ETag>"multipart-%d"</ETag></CompleteMultipartUploadResult>`, xmlEscape(key), len(numbers))
+	case "AbortMultipartUpload":
+		delete(f.uploads, r.URL.Query().Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case "DeleteObject":
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case "DeleteObjects":
+		var req deleteRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		io.WriteString(w, `<DeleteResult>`)
+		for _, obj := range req.Objects {
+			if f.fail != nil {
+				if code, _ := f.fail("DeleteKey", bucket, obj.Key); code != "" {
+					fmt.Fprintf(w, `<Error><Key>%s</Key><Code>%s</Code><Message>%s</Message></Error>`, xmlEscape(obj.Key), code, code)
+					continue
+				}
+			}
+			delete(objects, obj.Key)
+		}
+		io.WriteString(w, `</DeleteResult>`)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// source finds the object named by x-amz-copy-source, writing an error if it
+// isn't there. Called with f.mu held.
+func (f *fakeS3) source(w http.ResponseWriter, r *http.Request) (fakeObject, bool) {
+	raw := r.Header.Get("X-Amz-Copy-Source")
+	// stricter than S3, which guesses at some unencoded characters and reads +
+	// as a space, so a missing escape shows up as an error rather than a copy of
+	// the wrong thing
+	decoded, err := url.PathUnescape(raw)
+	unencoded := func(r rune) bool {
+		return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.~/%", r)
+	}
+	if strings.IndexFunc(raw, unencoded) >= 0 {
+		err = fmt.Errorf("unencoded character in %q", raw)
+	}
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+		return fakeObject{}, false
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(decoded, "/"), "/")
+	obj, ok := f.buckets[bucket][key]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return fakeObject{}, false
+	}
+	return obj, true
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request, objects map[string]fakeObject) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	after := q.Get("continuation-token")
+
+	var keys []string
+	for k := range objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string
+		Size         int64
+		ETag         string
+		LastModified string
+		StorageClass string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	type result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Prefix                string
+		EncodingType          string `xml:",omitempty"`
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}
+	// with encoding-type=url keys go back the way S3 sends them, form encoded
+	encode := func(k string) string { return k }
+	res := result{Prefix: prefix}
+	if q.Get("encoding-type") == "url" {
+		encode = url.QueryEscape
+		res.EncodingType = "url"
+		res.Prefix = encode(prefix)
+	}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		// a token ending in the delimiter is a common prefix, skip all of it
+		if k <= after || (delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(k, after)) {
+			continue
+		}
+		if res.KeyCount == f.pageSize {
+			res.IsTruncated = true
+			break
+		}
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				p := k[:len(prefix)+i+len(delimiter)]
+				if !seen[p] {
+					seen[p] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(p)})
+					res.KeyCount++
+					res.NextContinuationToken = p
+				}
+				continue
+			}
+		}
+		obj := objects[k]
+		res.Contents = append(res.Contents, content{
+			Key:          encode(k),
+			Size:         int64(len(obj.data)),
+			ETag:         etag(obj.data),
+			LastModified: obj.modified.Format(time.RFC3339),
+			StorageClass: "STANDARD",
+		})
+		res.KeyCount++
+		res.NextContinuationToken = k
+	}
+	if !res.IsTruncated {
+		res.NextContinuationToken = ""
+	}
+	xml.NewEncoder(w).Encode(res)
+}
+
+// objectHeaders keeps the headers S3 stores with an object
+func objectHeaders(h http.Header) http.Header {
+	kept := http.Header{}
+	for k, v := range h {
+		if strings.HasPrefix(k, "X-Amz-Meta-") || k == "Content-Type" || k == "Cache-Control" || k == "Content-Encoding" || k == "X-Amz-Storage-Class" {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// parseRange reads "bytes=10-99", "bytes=10-" or the last n bytes with
+// "bytes=-n". Like S3 it's not ok when none of the object is covered.
+func parseRange(rng string, size int64) (int64, int64, bool) {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	from, to, _ := strings.Cut(spec, "-")
+	start, end := int64(0), size-1
+	if from == "" {
+		n, _ := strconv.ParseInt(to, 10, 64)
+		start = size - n
+		if start < 0 {
+			start = 0
+		}
+	} else {
+		start, _ = strconv.ParseInt(from, 10, 64)
+		if to != "" {
+			end, _ = strconv.ParseInt(to, 10, 64)
+		}
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, start <= end
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
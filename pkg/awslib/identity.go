@@ -0,0 +1,81 @@
+package awslib
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// MFAPrompt is asked for a code whenever assuming a role needs MFA. serial is
+// the profile's mfa_serial. The gui swaps in a form.
+var MFAPrompt = StdinMFAPrompt
+
+// StdinMFAPrompt asks for an MFA code on the terminal
+func StdinMFAPrompt(serial string) (string, error) {
+	fmt.Printf("Enter MFA code for %s: ", serial)
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(code), err
+}
+
+// Identity is who the current credentials belong to
+type Identity struct {
+	Account   string
+	ARN       string
+	CanExpire bool
+	Expires   time.Time
+}
+
+// Name is the interesting part of the ARN, e.g. "assumed-role/Admin/session"
+func (i Identity) Name() string {
+	parts := strings.SplitN(i.ARN, ":", 6)
+	if len(parts) < 6 {
+		return i.ARN
+	}
+	return parts[5]
+}
+
+// Identity asks STS who we are and when the credentials run out. Custom
+// endpoints are left out, they rarely have STS and their keys mean nothing to
+// AWS's, so only the expiry is known. If STS fails the expiry still comes back
+// along with the error.
+func (s *S3Handler) Identity() (Identity, error) {
+	if s.cfg.Credentials == nil {
+		return Identity{}, errors.New("handler was not built from an aws config")
+	}
+	creds, err := s.cfg.Credentials.Retrieve(context.TODO())
+	if err != nil {
+		return Identity{}, opError("get credentials", "", "", err)
+	}
+	id := Identity{CanExpire: creds.CanExpire, Expires: creds.Expires}
+	if s.endpoint != "" {
+		return id, nil
+	}
+
+	out, err := sts.NewFromConfig(s.cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return id, opError("get caller identity", "", "", err)
+	}
+	id.Account = aws.ToString(out.Account)
+	id.ARN = aws.ToString(out.Arn)
+	return id, nil
+}
+
+// RefreshCredentials throws away cached credentials and fetches new ones,
+// which may mean prompting for MFA again
+func (s *S3Handler) RefreshCredentials() error {
+	if s.cfg.Credentials == nil {
+		return nil
+	}
+	if cache, ok := s.cfg.Credentials.(*aws.CredentialsCache); ok {
+		cache.Invalidate()
+	}
+	_, err := s.cfg.Credentials.Retrieve(context.TODO())
+	return opError("refresh credentials", "", "", err)
+}
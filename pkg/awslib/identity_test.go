@@ -0,0 +1,69 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// expiringConfig has credentials that run out at expires, with every request
+// going through do
+func expiringConfig(expires time.Time, do func(r *http.Request) (*http.Response, error)) aws.Config {
+	return aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret", CanExpire: true, Expires: expires}, nil
+		}),
+		HTTPClient:       httpClientFunc(do),
+		RetryMaxAttempts: 1,
+	}
+}
+
+type httpClientFunc func(r *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestIdentityKeepsExpiryWhenSTSFails(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+	cfg := expiringConfig(expires, func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("no route to sts")
+	})
+	s, err := NewS3HandlerFromConfig(cfg, EndpointOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Identity()
+	if err == nil {
+		t.Fatal("expected STS to fail")
+	}
+	if !id.CanExpire || !id.Expires.Equal(expires) {
+		t.Errorf("identity = %+v, lost the expiry", id)
+	}
+}
+
+func TestIdentitySkipsSTSOnCustomEndpoints(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+	cfg := expiringConfig(expires, func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", r.URL)
+		return nil, errors.New("unexpected request")
+	})
+	s, err := NewS3HandlerFromConfig(cfg, EndpointOptions{URL: "http://localhost:9000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := s.Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.ARN != "" || !id.CanExpire || !id.Expires.Equal(expires) {
+		t.Errorf("identity = %+v", id)
+	}
+}
@@ -0,0 +1,84 @@
+package awslib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// iniSection is one [section] of an AWS style ini file. Nested settings such as
+//
+//	s3 =
+//	  addressing_style = path
+//
+// are flattened to "s3.addressing_style".
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// parseINI reads the subset of ini the AWS CLI understands. filename is only
+// used to make error messages point somewhere useful.
+func parseINI(r io.Reader, filename string) ([]iniSection, error) {
+	var sections []iniSection
+	var current *iniSection
+	// key of a "key =" line with no value, whose indented lines are nested under it
+	var parent string
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: unterminated section header %q", filename, lineNo, line)
+			}
+			// "[ profile  foo ]" is the same section as "[profile foo]"
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if name == "" {
+				return nil, fmt.Errorf("%s:%d: empty section name", filename, lineNo)
+			}
+			sections = append(sections, iniSection{name: name, values: map[string]string{}})
+			current = &sections[len(sections)-1]
+			parent = ""
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: %q is not inside a [section]", filename, lineNo, line)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"key = value\", got %q", filename, lineNo, line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: missing key before \"=\"", filename, lineNo)
+		}
+
+		indented := raw[0] == ' ' || raw[0] == '\t'
+		switch {
+		case indented && parent != "":
+			current.values[parent+"."+key] = value
+		case value == "":
+			parent = key
+		default:
+			parent = ""
+			current.values[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return sections, nil
+}
@@ -0,0 +1,94 @@
+package awslib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Keys can be any UTF-8 at all: spaces, +, #, ?, control characters, double
+// and leading slashes. The SDK escapes keys in request paths, everything here
+// covers the places it doesn't.
+
+// copySource is the x-amz-copy-source value for key in bucket. S3 wants it URL
+// encoded, with the slashes left alone so "a//b" and "/a" survive intact.
+func copySource(bucket string, key string) string {
+	return "/" + bucket + "/" + escapeKey(key)
+}
+
+// escapeKey percent-encodes everything but unreserved characters and slashes.
+// url.PathEscape leaves + alone, which S3 reads back as a space.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// urlEncoded asks S3 to URL encode the keys in a listing. Without it a key
+// with a character XML can't carry breaks the whole page.
+func urlEncoded(input *s3.ListObjectsV2Input) *s3.ListObjectsV2Input {
+	input.EncodingType = types.EncodingTypeUrl
+	return input
+}
+
+// decodeListing undoes urlEncoded on a page of results
+func decodeListing(output *s3.ListObjectsV2Output) error {
+	if output.EncodingType != types.EncodingTypeUrl {
+		return nil
+	}
+	decode := func(s *string) error {
+		if s == nil {
+			return nil
+		}
+		decoded, err := url.QueryUnescape(*s)
+		if err != nil {
+			return fmt.Errorf("decoding key %q: %w", *s, err)
+		}
+		*s = decoded
+		return nil
+	}
+	for i := range output.Contents {
+		if err := decode(output.Contents[i].Key); err != nil {
+			return err
+		}
+	}
+	for i := range output.CommonPrefixes {
+		if err := decode(output.CommonPrefixes[i].Prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage is paginator.NextPage with the keys decoded
+func nextPage(paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
+	output, err := paginator.NextPage(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return output, decodeListing(output)
+}
+
+// localPath is where rel ends up under root, refusing anything that would land
+// outside it, like a key of "../../.bashrc"
+func localPath(root string, rel string) (string, error) {
+	dest := filepath.Join(root, filepath.FromSlash(rel))
+	if !strings.HasPrefix(dest, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to download %q outside of %s", rel, root)
+	}
+	return dest, nil
+}
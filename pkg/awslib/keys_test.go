@@ -0,0 +1,197 @@
+package awslib
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// awkwardKeys have tripped up S3 clients one way or another
+var awkwardKeys = []string{
+	"plain.txt",
+	"with space.txt",
+	"plus+sign.txt",
+	"hash#tag",
+	"question?mark=1&b=2",
+	"percent%20literal",
+	"percent%",
+	"naïve café.txt",
+	"emoji 🙂.bin",
+	"日本語/ファイル.txt",
+	"double//slash",
+	"/leading-slash",
+	"//two-leading",
+	"trailing-dot.",
+	"semi;colon,comma:colon@at",
+	`quote"s and 'apostrophes'`,
+	`back\slash`,
+	"tab\tkey",
+	"angle<bracket>&amp;",
+	"tilde~and`backtick",
+	"[brackets]{braces}(parens)",
+	"dir/sub dir/deep+file",
+	"!$*^|",
+}
+
+// randomKeys builds keys out of the characters above, plus the odd slash
+func randomKeys(n int, seed int64) []string {
+	alphabet := []rune("ab /+#?%&=;:@!$'\"\\<>[]{}~`^|\téü🙂日.-_")
+	r := rand.New(rand.NewSource(seed))
+	seen := map[string]bool{}
+	var keys []string
+	for len(keys) < n {
+		var b strings.Builder
+		for i := 0; i < 1+r.Intn(20); i++ {
+			b.WriteRune(alphabet[r.Intn(len(alphabet))])
+		}
+		key := b.String()
+		// a trailing slash makes it a folder marker, which the round trip
+		// below treats differently
+		if strings.HasSuffix(key, "/") || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestCopySourceEscaping(t *testing.T) {
+	tests := map[string]string{
+		"plain.txt":       "/b/plain.txt",
+		"a b+c/d#?":       "/b/a%20b%2Bc/d%23%3F",
+		"double//slash":   "/b/double//slash",
+		"/leading":        "/b//leading",
+		"100%":            "/b/100%25",
+		"café":            "/b/caf%C3%A9",
+		"x=1&y=2;z:@,$!*": "/b/x%3D1%26y%3D2%3Bz%3A%40%2C%24%21%2A",
+	}
+	for key, want := range tests {
+		if got := copySource("b", key); got != want {
+			t.Errorf("copySource(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+// TestAwkwardKeysRoundTrip copies, renames, lists and deletes every key and
+// checks each one comes out exactly as it went in
+func TestAwkwardKeysRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	corpora := map[string][]string{
+		"awkward": awkwardKeys,
+		"random":  randomKeys(200, 1),
+	}
+	for name, keys := range corpora {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeS3("b")
+			// small pages so the listing round trips continuation tokens too
+			f.pageSize = 7
+			for _, key := range keys {
+				f.put("b", key, []byte("data of "+key))
+			}
+			s := f.handler(t)
+
+			listing, err := s.ListPrefix("b", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]string{}, keys...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(listing.Keys, want) {
+				t.Fatalf("listing = %q\nwant %q", listing.Keys, want)
+			}
+
+			var jobs []CopyJob
+			for _, key := range keys {
+				jobs = append(jobs, CopyJob{From: key, To: "copies/" + key})
+			}
+			summary, err := s.CopyKeys("b", jobs, nil)
+			if err != nil || len(summary.Failed) != 0 {
+				t.Fatalf("copy: %s, %v, %v", summary, err, summary.Failed)
+			}
+
+			for _, key := range keys {
+				ok, err := s.RenameObject("b", "copies/"+key, "renamed/"+key)
+				if !ok || err != nil {
+					t.Errorf("rename %q: %v, %v", key, ok, err)
+					continue
+				}
+				data, found := f.get("b", "renamed/"+key)
+				if !found || !bytes.Equal(data, []byte("data of "+key)) {
+					t.Errorf("renamed/%q = %q, %v", key, data, found)
+				}
+			}
+
+			// everything under renamed/ in one go, folder style
+			if _, err := s.RenamePrefix("b", "renamed/", "moved/", nil); err != nil {
+				t.Fatal(err)
+			}
+			listing, err = s.ListPrefix("b", "moved/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var moved []string
+			for _, key := range want {
+				moved = append(moved, "moved/"+key)
+			}
+			sort.Strings(moved)
+			if !reflect.DeepEqual(listing.Keys, moved) {
+				t.Errorf("moved = %q\nwant %q", listing.Keys, moved)
+			}
+
+			deleted, err := s.DeleteKeys("b", append(listing.Keys, keys...), nil)
+			if err != nil || len(deleted.Failed) != 0 {
+				t.Fatalf("delete: %s, %v, %v", deleted, err, deleted.Failed)
+			}
+			if left := f.keys("b"); len(left) != 0 {
+				t.Errorf("left behind: %q", left)
+			}
+		})
+	}
+}
+
+func TestDirectoryListingAwkwardKeys(t *testing.T) {
+	f := newFakeS3("b")
+	for _, key := range []string{"a b/c+d.txt", "a b/e#f/", "a b/e#f/g?.txt", "x%y.txt"} {
+		f.put("b", key, []byte("x"))
+	}
+	s := f.handler(t)
+
+	got, err := s.GetDirectoryStructure("b", "/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a b/", "x%y.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top = %q, want %q", got, want)
+	}
+	got, err = s.GetDirectoryStructure("b", "/", "a b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"..", "a b/e#f/", "a b/c+d.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("a b/ = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadRefusesEscapingKeys(t *testing.T) {
+	f := newFakeS3("b")
+	f.put("b", "dir/../../escape.txt", []byte("nope"))
+	f.put("b", "..", []byte("nope"))
+	s := f.handler(t)
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local")
+
+	for _, key := range []string{"dir/", ".."} {
+		if _, err := s.Download("b", key, local, nil); err == nil {
+			t.Errorf("downloading %q worked", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); err == nil {
+		t.Error("escape.txt was written outside the download folder")
+	}
+}
@@ -0,0 +1,194 @@
+package awslib
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DirectoryEntry is a key in a listing along with what S3 said about it
+type DirectoryEntry struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	StorageClass string
+	// without the quotes S3 puts around it
+	ETag string
+}
+
+// DirectoryPage is one page of a folder listing. Folders and keys come out of
+// the same ListObjectsV2 call, so a page can hold both.
+type DirectoryPage struct {
+	Folders []string
+	Keys    []DirectoryEntry
+}
+
+// DirectoryLister walks a folder a page at a time so prefixes with millions of
+// keys can be shown before the whole thing has been listed
+type DirectoryLister interface {
+	HasMore() bool
+	NextPage() (DirectoryPage, error)
+}
+
+type s3DirectoryLister struct {
+	paginator *s3.ListObjectsV2Paginator
+	bucket    string
+	prefix    string
+}
+
+// ListDirectory lists the folders and keys directly under prefix in one pass.
+// With no delimiter it's every key under prefix, however deep.
+func (s *S3Handler) ListDirectory(bucket string, delimiter string, prefix string) DirectoryLister {
+	params := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	if delimiter != "" {
+		params.Delimiter = aws.String(delimiter)
+	}
+	return &s3DirectoryLister{
+		paginator: s3.NewListObjectsV2Paginator(s.client(bucket), urlEncoded(params)),
+		bucket:    bucket,
+		prefix:    prefix,
+	}
+}
+
+func (l *s3DirectoryLister) HasMore() bool {
+	return l.paginator.HasMorePages()
+}
+
+func (l *s3DirectoryLister) NextPage() (DirectoryPage, error) {
+	output, err := nextPage(l.paginator)
+	if err != nil {
+		return DirectoryPage{}, opError("list", l.bucket, l.prefix, err)
+	}
+	var page DirectoryPage
+	for _, value := range output.CommonPrefixes {
+		page.Folders = append(page.Folders, *value.Prefix)
+	}
+	for _, value := range output.Contents {
+		if key := *value.Key; isListedKey(key) {
+			page.Keys = append(page.Keys, DirectoryEntry{
+				Key:          key,
+				Size:         value.Size,
+				LastModified: aws.ToTime(value.LastModified),
+				StorageClass: string(value.StorageClass),
+				ETag:         strings.Trim(aws.ToString(value.ETag), `"`),
+			})
+		}
+	}
+	return page, nil
+}
+
+// isListedKey leaves out the "folder/" placeholder keys the console creates
+func isListedKey(key string) bool {
+	return key != "" && !strings.HasSuffix(key, "/")
+}
+
+// readDirectory drains a lister into the flat list GetDirectoryStructure
+// returns: ".." when inside a folder, then folders, then keys
+func readDirectory(lister DirectoryLister, prefix string) ([]string, error) {
+	var folders, keys []string
+	if prefix != "" {
+		folders = append(folders, "..")
+	}
+	for lister.HasMore() {
+		page, err := lister.NextPage()
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, page.Folders...)
+		for _, entry := range page.Keys {
+			keys = append(keys, entry.Key)
+		}
+	}
+	return append(folders, keys...), nil
+}
+
+// memDirectoryLister pages through a snapshot taken on the first call, the
+// way S3 would hand it out
+type memDirectoryLister struct {
+	m                         *MemoryStore
+	bucket, delimiter, prefix string
+	listed                    bool
+	entries                   []memEntry
+}
+
+type memEntry struct {
+	name   string
+	folder bool
+	object memObject
+}
+
+// ListDirectory pages through bucket like S3Handler, SetPageSize entries at a time
+func (m *MemoryStore) ListDirectory(bucket string, delimiter string, prefix string) DirectoryLister {
+	return &memDirectoryLister{m: m, bucket: bucket, delimiter: delimiter, prefix: prefix}
+}
+
+// SetPageSize changes how many entries each listing page holds
+func (m *MemoryStore) SetPageSize(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pageSize = n
+}
+
+func (l *memDirectoryLister) HasMore() bool {
+	return !l.listed || len(l.entries) > 0
+}
+
+func (l *memDirectoryLister) NextPage() (DirectoryPage, error) {
+	m := l.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !l.listed {
+		objects, err := m.bucket(l.bucket)
+		if err != nil {
+			return DirectoryPage{}, opError("list", l.bucket, l.prefix, err)
+		}
+		l.listed = true
+		seen := map[string]bool{}
+		for _, key := range m.sortedKeys(objects) {
+			if !strings.HasPrefix(key, l.prefix) {
+				continue
+			}
+			rest := key[len(l.prefix):]
+			if i := strings.Index(rest, l.delimiter); l.delimiter != "" && i >= 0 {
+				folder := l.prefix + rest[:i+len(l.delimiter)]
+				if !seen[folder] {
+					seen[folder] = true
+					l.entries = append(l.entries, memEntry{name: folder, folder: true})
+				}
+				continue
+			}
+			if isListedKey(key) {
+				l.entries = append(l.entries, memEntry{name: key, object: objects[key]})
+			}
+		}
+	}
+
+	n := m.pageSize
+	if n <= 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	var page DirectoryPage
+	for _, e := range l.entries[:n] {
+		if e.folder {
+			page.Folders = append(page.Folders, e.name)
+		} else {
+			sum := md5.Sum(e.object.data)
+			page.Keys = append(page.Keys, DirectoryEntry{
+				Key:          e.name,
+				Size:         int64(len(e.object.data)),
+				LastModified: e.object.modified,
+				StorageClass: e.object.storageClass,
+				ETag:         hex.EncodeToString(sum[:]),
+			})
+		}
+	}
+	l.entries = l.entries[n:]
+	return page, nil
+}
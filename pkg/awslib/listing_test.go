@@ -0,0 +1,139 @@
+package awslib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestListDirectoryPages(t *testing.T) {
+	f := newFakeS3("big")
+	f.pageSize = 4
+	for i := 0; i < 10; i++ {
+		f.put("big", fmt.Sprintf("logs/%02d.log", i), []byte("x"))
+	}
+	f.put("big", "logs/archive/old.log", []byte("x"))
+	f.put("big", "logs/archive/older.log", []byte("x"))
+	f.put("big", "logs/zz/last.log", []byte("x"))
+	f.put("big", "logs/", nil)
+	s := f.handler(t)
+
+	lister := s.ListDirectory("big", "/", "logs/")
+	var folders, keys []string
+	pages := 0
+	for lister.HasMore() {
+		page, err := lister.NextPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		folders = append(folders, page.Folders...)
+		for _, entry := range page.Keys {
+			keys = append(keys, entry.Key)
+		}
+	}
+
+	// logs/ is a console folder marker and is left out
+	wantFolders := []string{"logs/archive/", "logs/zz/"}
+	if !reflect.DeepEqual(folders, wantFolders) {
+		t.Errorf("folders = %v, want %v", folders, wantFolders)
+	}
+	if len(keys) != 10 || keys[0] != "logs/00.log" || keys[9] != "logs/09.log" {
+		t.Errorf("keys = %v", keys)
+	}
+	// one ListObjectsV2 per page for folders and keys together
+	if n := f.count("ListObjectsV2"); n != pages {
+		t.Errorf("%d list calls for %d pages", n, pages)
+	}
+	if pages != 4 {
+		t.Errorf("%d pages, want 4", pages)
+	}
+}
+
+func TestListDirectoryFirstPageOnly(t *testing.T) {
+	f := newFakeS3("big")
+	f.pageSize = 2
+	for i := 0; i < 20; i++ {
+		f.put("big", fmt.Sprintf("%02d.txt", i), []byte("x"))
+	}
+	s := f.handler(t)
+
+	page, err := s.ListDirectory("big", "/", "").NextPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Keys) != 2 || page.Keys[0].Key != "00.txt" || page.Keys[1].Key != "01.txt" {
+		t.Errorf("first page = %v", page.Keys)
+	}
+	// everything S3 says about the key comes along with it
+	first := page.Keys[0]
+	if first.Size != 1 || first.StorageClass != "STANDARD" || first.LastModified.IsZero() {
+		t.Errorf("00.txt = %+v", first)
+	}
+	if want := strings.Trim(etag([]byte("x")), `"`); first.ETag != want {
+		t.Errorf("etag = %q, want %q", first.ETag, want)
+	}
+	if n := f.count("ListObjectsV2"); n != 1 {
+		t.Errorf("%d list calls for the first page", n)
+	}
+}
+
+func TestMemoryStoreListDirectoryPages(t *testing.T) {
+	m := NewMemoryStore()
+	m.SetPageSize(2)
+	for _, key := range []string{"a.txt", "b/1", "b/2", "c.txt", "d/1", "e.txt"} {
+		m.Put("bkt", key, []byte(key))
+	}
+	m.SetStorageClass("bkt", "e.txt", "GLACIER")
+
+	lister := m.ListDirectory("bkt", "/", "")
+	var got [][]string
+	for lister.HasMore() {
+		page, err := lister.NextPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := page.Folders
+		for _, entry := range page.Keys {
+			names = append(names, entry.Key+" "+entry.StorageClass)
+		}
+		got = append(got, names)
+	}
+	want := [][]string{
+		{"b/", "a.txt STANDARD"},
+		{"d/", "c.txt STANDARD"},
+		{"e.txt GLACIER"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+
+	if _, err := m.ListDirectory("missing", "/", "").NextPage(); err == nil {
+		t.Error("listing a missing bucket worked")
+	}
+}
+
+func TestListDirectoryWithoutDelimiter(t *testing.T) {
+	f := newFakeS3("b")
+	for _, key := range []string{"a/1", "a/b/2", "a/b/c/3", "a/", "z"} {
+		f.put("b", key, []byte("x"))
+	}
+	lister := f.handler(t).ListDirectory("b", "", "a/")
+	var keys []string
+	for lister.HasMore() {
+		page, err := lister.NextPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Folders) != 0 {
+			t.Errorf("folders without a delimiter: %v", page.Folders)
+		}
+		for _, entry := range page.Keys {
+			keys = append(keys, entry.Key)
+		}
+	}
+	if want := []string{"a/1", "a/b/2", "a/b/c/3"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
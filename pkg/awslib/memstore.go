@@ -0,0 +1,539 @@
+package awslib
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+type memObject struct {
+	data         []byte
+	storageClass string
+	tags         map[string]string
+	modified     time.Time
+}
+
+// MemoryStore is an ObjectStore that keeps everything in maps. It mimics the
+// bits of S3 behaviour the gui relies on (delimiter listing, "folder" keys,
+// ranged previews) so the ui can be driven without an AWS account.
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]map[string]memObject
+	pageSize int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  map[string]map[string]memObject{},
+		pageSize: 1000,
+	}
+}
+
+// Put creates bucket if needed and stores data under key
+func (m *MemoryStore) Put(bucket string, key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = map[string]memObject{}
+	}
+	m.buckets[bucket][key] = memObject{data: data, storageClass: "STANDARD", modified: time.Now()}
+}
+
+// Get returns the contents of key and whether it exists
+func (m *MemoryStore) Get(bucket string, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.buckets[bucket][key]
+	return obj.data, ok
+}
+
+// SetStorageClass changes the storage class of an existing key, e.g. "GLACIER"
+func (m *MemoryStore) SetStorageClass(bucket string, key string, class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if obj, ok := m.buckets[bucket][key]; ok {
+		obj.storageClass = class
+		m.buckets[bucket][key] = obj
+	}
+}
+
+// SetModified backdates an existing key
+func (m *MemoryStore) SetModified(bucket string, key string, modified time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if obj, ok := m.buckets[bucket][key]; ok {
+		obj.modified = modified
+		m.buckets[bucket][key] = obj
+	}
+}
+
+// StorageClass returns the storage class of key, "" if it doesn't exist
+func (m *MemoryStore) StorageClass(bucket string, key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buckets[bucket][key].storageClass
+}
+
+// Tags returns the tags on key
+func (m *MemoryStore) Tags(bucket string, key string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buckets[bucket][key].tags
+}
+
+func (m *MemoryStore) bucket(name string) (map[string]memObject, error) {
+	objects, ok := m.buckets[name]
+	if !ok {
+		return nil, apiError("NoSuchBucket", "The specified bucket does not exist")
+	}
+	return objects, nil
+}
+
+func (m *MemoryStore) object(bucket string, key string) (memObject, error) {
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return memObject{}, err
+	}
+	obj, ok := objects[key]
+	if !ok {
+		return memObject{}, apiError("NoSuchKey", "The specified key does not exist.")
+	}
+	return obj, nil
+}
+
+func (m *MemoryStore) sortedKeys(objects map[string]memObject) []string {
+	keys := make([]string, 0, len(objects))
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Identity is a fixed, never expiring, pretend user
+func (m *MemoryStore) Identity() (Identity, error) {
+	return Identity{Account: "000000000000", ARN: "arn:aws:iam::000000000000:user/memory"}, nil
+}
+
+func (m *MemoryStore) RefreshCredentials() error {
+	return nil
+}
+
+func (m *MemoryStore) GetBuckets() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var buckets []string
+	for name := range m.buckets {
+		buckets = append(buckets, name)
+	}
+	sort.Strings(buckets)
+	return buckets, nil
+}
+
+func (m *MemoryStore) CreateBucket(name string, length int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[name]; !ok {
+		m.buckets[name] = map[string]memObject{}
+		return true, nil
+	}
+	for {
+		hash, err := utils.GenerateRandomString(length)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := m.buckets[name+"-"+hash]; !ok {
+			m.buckets[name+"-"+hash] = map[string]memObject{}
+			return false, nil
+		}
+	}
+}
+
+// BucketRegion always says us-east-1, there is only one region in memory
+func (m *MemoryStore) BucketRegion(bucket string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.bucket(bucket); err != nil {
+		return "", opError("find region of", bucket, "", err)
+	}
+	return "us-east-1", nil
+}
+
+// GetDirectoryStructure lists folders (common prefixes) then keys, like S3Handler
+func (m *MemoryStore) GetDirectoryStructure(bucket string, delimiter string, prefix string) ([]string, error) {
+	return readDirectory(m.ListDirectory(bucket, delimiter, prefix), prefix)
+}
+
+func (m *MemoryStore) PreviewFile(bucket string, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return nil, opError("preview", bucket, key, err)
+	}
+	// byteRange is inclusive so it covers 1001 bytes
+	if len(obj.data) > 1001 {
+		return obj.data[:1001], nil
+	}
+	return obj.data, nil
+}
+
+func (m *MemoryStore) IsGlacier(bucket string, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return false, opError("check storage class", bucket, key, err)
+	}
+	return obj.storageClass == "GLACIER", nil
+}
+
+func (m *MemoryStore) DeleteObject(bucket string, key string) (bool, error) {
+	if key == ".." || strings.HasSuffix(key, "/") {
+		return false, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return false, opError("delete", bucket, key, err)
+	}
+	// S3 happily deletes keys that don't exist
+	delete(objects, key)
+	return true, nil
+}
+
+// ListPrefix lists everything under prefix, folder markers included
+func (m *MemoryStore) ListPrefix(bucket string, prefix string) (PrefixListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var listing PrefixListing
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return listing, opError("list", bucket, prefix, err)
+	}
+	for _, key := range m.sortedKeys(objects) {
+		if strings.HasPrefix(key, prefix) {
+			size := int64(len(objects[key].data))
+			listing.Keys = append(listing.Keys, key)
+			listing.Sizes = append(listing.Sizes, size)
+			listing.Bytes += size
+		}
+	}
+	return listing, nil
+}
+
+func (m *MemoryStore) DeleteKeys(bucket string, keys []string, progress ProgressFunc) (BatchSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := BatchSummary{Failed: map[string]error{}}
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return summary, opError("delete", bucket, "", err)
+	}
+	for _, key := range keys {
+		delete(objects, key)
+		summary.Done++
+	}
+	if progress != nil {
+		progress(int64(len(keys)), int64(len(keys)))
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) CopyKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := BatchSummary{Failed: map[string]error{}}
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return summary, opError("copy", bucket, "", err)
+	}
+	for _, j := range jobs {
+		obj, ok := objects[j.From]
+		if !ok {
+			summary.Failed[j.From] = opError("copy", bucket, j.From, apiError("NoSuchKey", "The specified key does not exist."))
+			continue
+		}
+		objects[j.To] = obj
+		summary.Done++
+	}
+	if progress != nil {
+		progress(int64(len(jobs)), int64(len(jobs)))
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) MoveKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error) {
+	return moveKeys(m, bucket, jobs, progress)
+}
+
+func (m *MemoryStore) TagKeys(bucket string, keys []string, tags map[string]string, progress ProgressFunc) (BatchSummary, error) {
+	return m.updateKeys(bucket, keys, "tag", progress, func(obj *memObject) {
+		obj.tags = tags
+	})
+}
+
+func (m *MemoryStore) ChangeStorageClass(bucket string, keys []string, sizes map[string]int64, class string, progress ProgressFunc) (BatchSummary, error) {
+	return m.updateKeys(bucket, keys, "change storage class of", progress, func(obj *memObject) {
+		obj.storageClass = class
+	})
+}
+
+// updateKeys applies fn to each existing key, missing ones fail like S3 would
+func (m *MemoryStore) updateKeys(bucket string, keys []string, op string, progress ProgressFunc, fn func(obj *memObject)) (BatchSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := BatchSummary{Failed: map[string]error{}}
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return summary, opError(op, bucket, "", err)
+	}
+	for _, key := range keys {
+		obj, ok := objects[key]
+		if !ok {
+			summary.Failed[key] = opError(op, bucket, key, apiError("NoSuchKey", "The specified key does not exist."))
+			continue
+		}
+		fn(&obj)
+		objects[key] = obj
+		summary.Done++
+	}
+	if progress != nil {
+		progress(int64(len(keys)), int64(len(keys)))
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) RenameObject(bucket string, oldKey string, newKey string) (bool, error) {
+	if oldKey == ".." {
+		return false, nil
+	}
+	if strings.HasSuffix(oldKey, "/") {
+		summary, err := m.RenamePrefix(bucket, oldKey, newKey, nil)
+		return err == nil && len(summary.Failed) == 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.object(bucket, oldKey)
+	if err != nil {
+		return false, opError("rename", bucket, oldKey, err)
+	}
+	m.buckets[bucket][newKey] = obj
+	if newKey != oldKey {
+		delete(m.buckets[bucket], oldKey)
+	}
+	return true, nil
+}
+
+// RenamePrefix moves everything under from to to, journalled like the real thing
+func (m *MemoryStore) RenamePrefix(bucket string, from string, to string, progress ProgressFunc) (RenameSummary, error) {
+	summary := RenameSummary{Failed: map[string]error{}}
+	to, err := checkRename(from, to)
+	if err != nil {
+		return summary, opError("rename", bucket, from, apiError("InvalidArgument", "%v", err))
+	}
+	rename, _, err := startRename(bucket, from, to)
+	if err != nil {
+		return summary, opError("rename", bucket, from, err)
+	}
+
+	m.mu.Lock()
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		m.mu.Unlock()
+		return summary, opError("rename", bucket, from, err)
+	}
+	var total int64
+	var keys []string
+	for _, key := range m.sortedKeys(objects) {
+		if strings.HasPrefix(key, from) {
+			keys = append(keys, key)
+			total += int64(len(objects[key].data))
+		}
+	}
+	tracker := newProgressTracker(total, progress)
+	for _, key := range keys {
+		obj := objects[key]
+		delete(objects, key)
+		objects[to+strings.TrimPrefix(key, from)] = obj
+		summary.Moved++
+		tracker.add(int64(len(obj.data)))
+	}
+	m.mu.Unlock()
+
+	err = updateRenameJournal(func(all []PendingRename) []PendingRename {
+		return removeRename(all, rename)
+	})
+	return summary, opError("rename", bucket, from, err)
+}
+
+func (m *MemoryStore) CopyBetween(srcBucket string, dstBucket string, jobs []TransferJob, progress ProgressFunc) (BatchSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := BatchSummary{Failed: map[string]error{}}
+	src, err := m.bucket(srcBucket)
+	if err != nil {
+		return summary, opError("copy", srcBucket, "", err)
+	}
+	dst, err := m.bucket(dstBucket)
+	if err != nil {
+		return summary, opError("copy", dstBucket, "", err)
+	}
+	var total int64
+	for _, j := range jobs {
+		total += j.Size
+	}
+	tracker := newProgressTracker(total, progress)
+	for _, j := range jobs {
+		obj, ok := src[j.From]
+		if !ok {
+			summary.Failed[j.From] = opError("copy", srcBucket, j.From, apiError("NoSuchKey", "The specified key does not exist."))
+			continue
+		}
+		dst[j.To] = obj
+		summary.Done++
+		tracker.add(j.Size)
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) OpenObject(bucket string, key string) (io.ReadCloser, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return nil, 0, opError("read", bucket, key, err)
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), int64(len(obj.data)), nil
+}
+
+func (m *MemoryStore) WriteObject(bucket string, key string, body io.Reader, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return opError("write", bucket, key, err)
+	}
+	return opError("write", bucket, key, m.upload(bucket, key, data))
+}
+
+func (m *MemoryStore) UploadFile(bucket string, prefix string, localPath string, progress ProgressFunc) (string, error) {
+	key := prefix + filepath.Base(localPath)
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", opError("upload", bucket, key, err)
+	}
+	if err := m.upload(bucket, key, data); err != nil {
+		return "", opError("upload", bucket, key, err)
+	}
+	if progress != nil {
+		progress(int64(len(data)), int64(len(data)))
+	}
+	return key, nil
+}
+
+func (m *MemoryStore) UploadDirectory(bucket string, prefix string, localDir string, progress ProgressFunc) ([]string, error) {
+	root := filepath.Clean(localDir)
+	base := prefix + filepath.Base(root) + "/"
+	var keys []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		key := base + filepath.ToSlash(rel)
+		keys = append(keys, key)
+		return opError("upload", bucket, key, m.upload(bucket, key, data))
+	})
+	if err != nil {
+		return nil, opError("upload", bucket, base, err)
+	}
+	if progress != nil {
+		progress(1, 1)
+	}
+	return keys, nil
+}
+
+func (m *MemoryStore) upload(bucket string, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	objects[key] = memObject{data: data, storageClass: "STANDARD", modified: time.Now()}
+	return nil
+}
+
+func (m *MemoryStore) Download(bucket string, key string, localDir string, progress ProgressFunc) (DownloadSummary, error) {
+	return m.DownloadKeys(bucket, []string{key}, localDir, progress)
+}
+
+func (m *MemoryStore) DownloadKeys(bucket string, keys []string, localDir string, progress ProgressFunc) (DownloadSummary, error) {
+	summary := DownloadSummary{Failed: map[string]error{}}
+	m.mu.Lock()
+	objects, err := m.bucket(bucket)
+	if err != nil {
+		m.mu.Unlock()
+		return summary, opError("download", bucket, "", err)
+	}
+	wanted := map[string][]byte{}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			parent := path.Dir(strings.TrimSuffix(key, "/")) + "/"
+			if parent == "./" {
+				parent = ""
+			}
+			for k, obj := range objects {
+				if strings.HasPrefix(k, key) && !strings.HasSuffix(k, "/") {
+					wanted[strings.TrimPrefix(k, parent)] = obj.data
+				}
+			}
+		} else if obj, ok := objects[key]; ok {
+			wanted[path.Base(key)] = obj.data
+		} else {
+			m.mu.Unlock()
+			return summary, opError("download", bucket, key, apiError("NoSuchKey", "The specified key does not exist."))
+		}
+	}
+	m.mu.Unlock()
+
+	root, err := filepath.Abs(localDir)
+	if err != nil {
+		return summary, opError("download", bucket, "", err)
+	}
+	for rel, data := range wanted {
+		dest, err := localPath(root, rel)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(dest), 0o755)
+		}
+		if err == nil {
+			err = os.WriteFile(dest, data, 0o644)
+		}
+		if err != nil {
+			summary.Failed[rel] = err
+			continue
+		}
+		summary.Downloaded++
+		summary.Bytes += int64(len(data))
+	}
+	if progress != nil {
+		progress(summary.Bytes, summary.Bytes)
+	}
+	return summary, nil
+}
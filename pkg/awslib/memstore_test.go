@@ -0,0 +1,57 @@
+package awslib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryStoreDirectoryStructure(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("b", "a.txt", nil)
+	store.Put("b", "dir/", nil)
+	store.Put("b", "dir/b.txt", nil)
+	store.Put("b", "dir/sub/c.txt", nil)
+	store.Put("b", "other/d.txt", nil)
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"dir/", "other/", "a.txt"}},
+		{"dir/", []string{"..", "dir/sub/", "dir/b.txt"}},
+		{"dir/sub/", []string{"..", "dir/sub/c.txt"}},
+		{"missing/", []string{".."}},
+	}
+	for _, tt := range tests {
+		got, err := store.GetDirectoryStructure("b", "/", tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("prefix %q: got %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreRename(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := NewMemoryStore()
+	store.Put("b", "dir/a.txt", []byte("a"))
+
+	if ok, err := store.RenameObject("b", "..", "x"); ok || err != nil {
+		t.Errorf("RenameObject(\"..\") = %v, %v", ok, err)
+	}
+	if ok, err := store.RenameObject("b", "dir/a.txt", "dir/b.txt"); !ok || err != nil {
+		t.Fatalf("RenameObject = %v, %v", ok, err)
+	}
+	if _, ok := store.Get("b", "dir/a.txt"); ok {
+		t.Error("old key still present")
+	}
+	// folders move with everything in them
+	if ok, err := store.RenameObject("b", "dir/", "x"); !ok || err != nil {
+		t.Fatalf("RenameObject(\"dir/\") = %v, %v", ok, err)
+	}
+	if _, ok := store.Get("b", "x/b.txt"); !ok {
+		t.Error("dir/b.txt wasn't moved to x/b.txt")
+	}
+}
@@ -0,0 +1,145 @@
+package awslib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Profile is a named profile with the settings from ~/.aws/config and
+// ~/.aws/credentials merged together, the credentials file winning.
+type Profile struct {
+	Name   string
+	values map[string]string
+}
+
+// Get returns a setting, e.g. "region" or "s3.addressing_style"
+func (p Profile) Get(key string) string {
+	return p.values[key]
+}
+
+func (p Profile) endpoint() EndpointOptions {
+	style := p.Get("s3.addressing_style")
+	if style == "" {
+		style = p.Get("addressing_style")
+	}
+	url := p.Get("s3.endpoint_url")
+	if url == "" {
+		url = p.Get("endpoint_url")
+	}
+	return EndpointOptions{
+		URL:                url,
+		PathStyle:          style == "path",
+		CABundle:           p.Get("ca_bundle"),
+		InsecureSkipVerify: p.Get("insecure_skip_verify") == "true",
+	}
+}
+
+func (p Profile) sessionToken() string {
+	if token := p.Get("aws_session_token"); token != "" {
+		return token
+	}
+	// older tools still write this name
+	return p.Get("aws_security_token")
+}
+
+// hasCredentialSource reports whether the SDK has anything to build credentials
+// from besides access keys
+func (p Profile) hasCredentialSource() bool {
+	for _, key := range []string{"role_arn", "sso_session", "sso_start_url", "credential_process", "web_identity_token_file"} {
+		if p.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialsFile is where profiles' keys live, honouring AWS_SHARED_CREDENTIALS_FILE
+func CredentialsFile() string {
+	if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".aws", "credentials")
+}
+
+// ConfigFile is where profiles' settings live, honouring AWS_CONFIG_FILE
+func ConfigFile() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".aws", "config")
+}
+
+// LoadProfiles reads every profile from the credentials and config files, in
+// the order they were first seen. Missing files are fine, malformed ones aren't.
+func LoadProfiles() ([]Profile, error) {
+	var profiles []Profile
+	index := map[string]int{}
+
+	add := func(name string, values map[string]string) {
+		i, ok := index[name]
+		if !ok {
+			index[name] = len(profiles)
+			profiles = append(profiles, Profile{Name: name, values: map[string]string{}})
+			i = len(profiles) - 1
+		}
+		for k, v := range values {
+			profiles[i].values[k] = v
+		}
+	}
+
+	credSections, err := readINIFile(CredentialsFile())
+	if err != nil {
+		return nil, err
+	}
+	configSections, err := readINIFile(ConfigFile())
+	if err != nil {
+		return nil, err
+	}
+
+	// register the credentials file's profiles first so its order wins...
+	for _, section := range credSections {
+		add(section.name, nil)
+	}
+	// ...but its values are applied last so they win too
+	for _, section := range configSections {
+		// the config file names everything but default "profile x", other
+		// sections (sso-session, services) aren't profiles
+		if name, ok := strings.CutPrefix(section.name, "profile "); ok {
+			add(name, section.values)
+		} else if section.name == "default" {
+			add(section.name, section.values)
+		}
+	}
+	for _, section := range credSections {
+		add(section.name, section.values)
+	}
+	return profiles, nil
+}
+
+// FindProfile returns the named profile from profiles
+func FindProfile(profiles []Profile, name string) (Profile, error) {
+	var names []string
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+		names = append(names, p.Name)
+	}
+	return Profile{}, fmt.Errorf("profile %q not found, found: %s", name, strings.Join(names, ", "))
+}
+
+func readINIFile(path string) ([]iniSection, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseINI(file, path)
+}
@@ -0,0 +1,202 @@
+package awslib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	input := `# leading comment
+[default]
+aws_access_key_id=AKIADEFAULT
+aws_secret_access_key = secret/with+chars==
+; another comment
+
+[ profile   dev ]
+region = eu-west-1
+s3 =
+  addressing_style = path
+  endpoint_url = http://localhost:9000
+output = json
+`
+	sections, err := parseINI(strings.NewReader(input), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 {
+		t.Fatalf("got %d sections, want 2", len(sections))
+	}
+
+	def := sections[0]
+	if def.name != "default" || def.values["aws_access_key_id"] != "AKIADEFAULT" || def.values["aws_secret_access_key"] != "secret/with+chars==" {
+		t.Errorf("default section = %+v", def)
+	}
+
+	dev := sections[1]
+	want := map[string]string{
+		"region":              "eu-west-1",
+		"s3.addressing_style": "path",
+		"s3.endpoint_url":     "http://localhost:9000",
+		"output":              "json",
+	}
+	if dev.name != "profile dev" {
+		t.Errorf("section name = %q", dev.name)
+	}
+	for k, v := range want {
+		if dev.values[k] != v {
+			t.Errorf("%s = %q, want %q", k, dev.values[k], v)
+		}
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	tests := map[string]string{
+		"no section":   "aws_access_key_id = x\n",
+		"no equals":    "[default]\naws_access_key_id AKIA\n",
+		"unterminated": "[default\n",
+		"no key":       "[default]\n= x\n",
+	}
+	for name, input := range tests {
+		if _, err := parseINI(strings.NewReader(input), "credentials"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if !strings.HasPrefix(err.Error(), "credentials:") {
+			t.Errorf("%s: error %q doesn't name the file", name, err)
+		}
+	}
+}
+
+func writeAWSFiles(t *testing.T, credentials string, config string) {
+	t.Helper()
+	dir := t.TempDir()
+	credPath := filepath.Join(dir, "credentials")
+	configPath := filepath.Join(dir, "config")
+	if err := os.WriteFile(credPath, []byte(credentials), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credPath)
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+}
+
+func TestLoadProfilesMergesFiles(t *testing.T) {
+	writeAWSFiles(t, `
+[work]
+aws_access_key_id=AKIAWORK
+aws_secret_access_key=workSecret
+aws_session_token=token
+region = ap-southeast-2
+
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = defaultSecret
+`, `
+[default]
+region = us-west-2
+
+[profile work]
+region = eu-central-1
+endpoint_url = https://minio.internal
+
+[profile config-only]
+region = us-east-2
+
+[sso-session corp]
+sso_region = us-east-1
+`)
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "work,default,config-only" {
+		t.Fatalf("profiles = %s", got)
+	}
+
+	work, err := FindProfile(profiles, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the credentials file wins over the config file
+	if work.Get("region") != "ap-southeast-2" {
+		t.Errorf("work region = %q", work.Get("region"))
+	}
+	if work.sessionToken() != "token" || work.endpoint().URL != "https://minio.internal" {
+		t.Errorf("work = %+v", work)
+	}
+
+	def, _ := FindProfile(profiles, "default")
+	if def.Get("region") != "us-west-2" || def.Get("aws_access_key_id") != "AKIADEFAULT" {
+		t.Errorf("default = %+v", def)
+	}
+
+	if _, err := FindProfile(profiles, "missing"); err == nil {
+		t.Error("expected an error for a missing profile")
+	}
+}
+
+func TestLoadProfilesMalformed(t *testing.T) {
+	writeAWSFiles(t, "[default]\naws_access_key_id AKIA\n", "")
+	if _, err := LoadProfiles(); err == nil {
+		t.Error("expected an error for a malformed credentials file")
+	}
+}
+
+func TestLoadProfilesMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "nope"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "nope"))
+	profiles, err := LoadProfiles()
+	if err != nil || len(profiles) != 0 {
+		t.Errorf("LoadProfiles = %v, %v", profiles, err)
+	}
+}
+
+func TestProfileConfig(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	writeAWSFiles(t, `
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = defaultSecret
+`, `
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/Admin
+source_profile = default
+mfa_serial = arn:aws:iam::123456789012:mfa/me
+region = eu-west-2
+
+[profile empty]
+region = us-east-2
+`)
+	profiles, err := LoadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin, _ := FindProfile(profiles, "admin")
+	cfg, err := ProfileConfig(admin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Region != "eu-west-2" {
+		t.Errorf("region = %q", cfg.Region)
+	}
+
+	cfg, err = ProfileConfig(admin, "ap-southeast-2")
+	if err != nil || cfg.Region != "ap-southeast-2" {
+		t.Errorf("-region didn't win: %q, %v", cfg.Region, err)
+	}
+
+	empty, _ := FindProfile(profiles, "empty")
+	if _, err := ProfileConfig(empty, ""); err == nil {
+		t.Error("expected an error for a profile without credentials")
+	}
+}
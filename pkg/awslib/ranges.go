@@ -0,0 +1,135 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// ObjectRange is part of an object, read with a Range request
+type ObjectRange struct {
+	Data []byte
+	// where Data starts in the object
+	Offset int64
+	// the whole object's size, not just this part
+	Size int64
+	// what the object says it is, if anything
+	ContentType string
+}
+
+// End is the offset just past Data
+func (r ObjectRange) End() int64 {
+	return r.Offset + int64(len(r.Data))
+}
+
+// rangeHeader asks for length bytes from offset, or the last length bytes when
+// offset is negative
+func rangeHeader(offset int64, length int64) string {
+	if offset < 0 {
+		return fmt.Sprintf("bytes=-%d", length)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// ReadRange reads length bytes of key from offset. A negative offset reads the
+// last length bytes instead, so the end of an object can be shown without
+// knowing its size first. Reading past the end gives back no data rather than
+// an error. Cancelling ctx stops the request.
+func (s *S3Handler) ReadRange(ctx context.Context, bucket string, key string, offset int64, length int64) (ObjectRange, error) {
+	output, err := s.client(bucket).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rangeHeader(offset, length)),
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+		// S3 says no to any range of an empty object, and to ranges past the end
+		head, err := s.client(bucket).HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return ObjectRange{}, opError("read", bucket, key, err)
+		}
+		if offset < 0 || offset > head.ContentLength {
+			offset = head.ContentLength
+		}
+		return ObjectRange{Offset: offset, Size: head.ContentLength, ContentType: aws.ToString(head.ContentType)}, nil
+	}
+	if err != nil {
+		return ObjectRange{}, opError("read", bucket, key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return ObjectRange{}, opError("read", bucket, key, err)
+	}
+	r := ObjectRange{Data: data, Offset: offset, Size: int64(len(data)), ContentType: aws.ToString(output.ContentType)}
+	if start, size, ok := parseContentRange(aws.ToString(output.ContentRange)); ok {
+		r.Offset, r.Size = start, size
+	} else if offset > 0 {
+		r.Size = offset + int64(len(data))
+	}
+	return r, nil
+}
+
+// parseContentRange reads the start and total size out of "bytes 0-99/1234".
+// S3 leaves it off when the range covered the whole object.
+func parseContentRange(header string) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	span, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	from, _, _ := strings.Cut(span, "-")
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+func (m *MemoryStore) ReadRange(ctx context.Context, bucket string, key string, offset int64, length int64) (ObjectRange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.object(bucket, key)
+	if err != nil {
+		return ObjectRange{}, opError("read", bucket, key, err)
+	}
+	size := int64(len(obj.data))
+	if offset < 0 {
+		offset = size - length
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > size {
+		offset = size
+	}
+	end := offset + length
+	if end > size {
+		end = size
+	}
+	// a copy, so whoever has it can append to it
+	return ObjectRange{
+		Data:        append([]byte(nil), obj.data[offset:end]...),
+		Offset:      offset,
+		Size:        size,
+		ContentType: aws.ToString(contentType(key)),
+	}, nil
+}
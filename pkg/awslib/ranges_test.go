@@ -0,0 +1,88 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadRange(t *testing.T) {
+	f := newFakeS3("b")
+	f.put("b", "digits.txt", []byte("0123456789"))
+	f.put("b", "empty.txt", nil)
+	s := f.handler(t)
+
+	tests := []struct {
+		name           string
+		key            string
+		offset, length int64
+		want           string
+		wantOffset     int64
+		wantSize       int64
+	}{
+		{"start", "digits.txt", 0, 4, "0123", 0, 10},
+		{"middle", "digits.txt", 4, 3, "456", 4, 10},
+		{"runs off the end", "digits.txt", 8, 4, "89", 8, 10},
+		{"past the end", "digits.txt", 12, 4, "", 10, 10},
+		{"suffix", "digits.txt", -1, 3, "789", 7, 10},
+		{"suffix bigger than the object", "digits.txt", -1, 20, "0123456789", 0, 10},
+		{"empty object", "empty.txt", 0, 4, "", 0, 0},
+		{"empty object suffix", "empty.txt", -1, 4, "", 0, 0},
+	}
+	for _, tt := range tests {
+		r, err := s.ReadRange(context.Background(), "b", tt.key, tt.offset, tt.length)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(r.Data) != tt.want || r.Offset != tt.wantOffset || r.Size != tt.wantSize {
+			t.Errorf("%s: got %q at %d of %d, want %q at %d of %d", tt.name, r.Data, r.Offset, r.Size, tt.want, tt.wantOffset, tt.wantSize)
+		}
+		if r.End() != r.Offset+int64(len(tt.want)) {
+			t.Errorf("%s: End() = %d", tt.name, r.End())
+		}
+
+		// the memory store should agree
+		m := NewMemoryStore()
+		m.Put("b", "digits.txt", []byte("0123456789"))
+		m.Put("b", "empty.txt", nil)
+		mr, err := m.ReadRange(context.Background(), "b", tt.key, tt.offset, tt.length)
+		if err != nil || string(mr.Data) != tt.want || mr.Offset != tt.wantOffset || mr.Size != tt.wantSize {
+			t.Errorf("%s: memory store got %q at %d of %d, %v", tt.name, mr.Data, mr.Offset, mr.Size, err)
+		}
+	}
+}
+
+func TestReadRangeMissingKey(t *testing.T) {
+	f := newFakeS3("b")
+	s := f.handler(t)
+	_, err := s.ReadRange(context.Background(), "b", "missing.txt", 0, 10)
+	if ErrorKindOf(err) != KindNotFound {
+		t.Errorf("error = %v, want not found", err)
+	}
+}
+
+func TestReadRangeCancelled(t *testing.T) {
+	f := newFakeS3("b")
+	f.put("b", "digits.txt", []byte("0123456789"))
+	s := f.handler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.ReadRange(ctx, "b", "digits.txt", 0, 4); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want canceled", err)
+	}
+	if n := f.count("GetObject"); n != 0 {
+		t.Errorf("%d GetObject calls went out", n)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	if start, size, ok := parseContentRange("bytes 100-199/1234"); !ok || start != 100 || size != 1234 {
+		t.Errorf("got %d, %d, %v", start, size, ok)
+	}
+	for _, bad := range []string{"", "bytes */1234", "bytes 1-2/*", "items 1-2/3"} {
+		if _, _, ok := parseContentRange(bad); ok {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// used when nothing (flag, env, ~/.aws/config) tells us which region to use
+const defaultRegion = "us-east-1"
+
+// NewS3HandlerFromConfig builds a handler that talks to each bucket through a
+// client for the bucket's own region, so buckets outside cfg.Region don't fail
+// with redirects. Custom endpoints (MinIO etc) only have the one region so
+// discovery is turned off for them.
+func NewS3HandlerFromConfig(cfg aws.Config, e EndpointOptions) (*S3Handler, error) {
+	client, err := NewS3Client(cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	s := NewS3Handler(*client)
+	s.cfg = cfg
+	s.region = cfg.Region
+	s.endpoint = e.URL
+	if e.URL == "" {
+		s.regionalClient = func(region string) (*s3.Client, error) {
+			regional := cfg.Copy()
+			regional.Region = region
+			return NewS3Client(regional, e)
+		}
+	}
+	return s, nil
+}
+
+// BucketRegion returns the region bucket lives in, asking S3 the first time
+func (s *S3Handler) BucketRegion(bucket string) (string, error) {
+	s.mu.Lock()
+	region, ok := s.bucketRegions[bucket]
+	s.mu.Unlock()
+	if ok {
+		return region, nil
+	}
+	if s.regionalClient == nil {
+		return s.region, nil
+	}
+
+	region, err := s.lookupBucketRegion(bucket)
+	if err != nil {
+		return "", opError("find region of", bucket, "", err)
+	}
+	s.mu.Lock()
+	s.bucketRegions[bucket] = region
+	s.mu.Unlock()
+	return region, nil
+}
+
+func (s *S3Handler) lookupBucketRegion(bucket string) (string, error) {
+	out, err := s.s3Client.GetBucketLocation(context.TODO(), &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err == nil {
+		switch out.LocationConstraint {
+		// buckets in us-east-1 have no location constraint
+		case "":
+			return "us-east-1", nil
+		// legacy name from before eu-west-1 was a thing
+		case "EU":
+			return "eu-west-1", nil
+		default:
+			return string(out.LocationConstraint), nil
+		}
+	}
+
+	// GetBucketLocation needs its own permission, HeadBucket only needs list
+	// access and S3 puts the real region in a header even when redirecting
+	_, err = s.s3Client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err == nil {
+		return s.region, nil
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		if region := respErr.Response.Header.Get("X-Amz-Bucket-Region"); region != "" {
+			return region, nil
+		}
+	}
+	return "", err
+}
+
+// client returns an s3 client for bucket's region. If the region can't be
+// worked out we fall back to the default client and let S3 complain.
+func (s *S3Handler) client(bucket string) *s3.Client {
+	if s.regionalClient == nil {
+		return s.s3Client
+	}
+	region, err := s.BucketRegion(bucket)
+	if err != nil || region == s.region {
+		return s.s3Client
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, ok := s.clients[region]; ok {
+		return client
+	}
+	client, err := s.regionalClient(region)
+	if err != nil {
+		return s.s3Client
+	}
+	s.clients[region] = client
+	return client
+}
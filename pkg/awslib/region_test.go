@@ -0,0 +1,76 @@
+package awslib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func testHandler(t *testing.T, handler http.HandlerFunc) *S3Handler {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := aws.Config{
+		Region:           "ap-southeast-2",
+		Credentials:      credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		RetryMaxAttempts: 1,
+	}
+	client, err := NewS3Client(cfg, EndpointOptions{URL: srv.URL, PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewS3Handler(*client)
+	s.region = cfg.Region
+	s.regionalClient = func(region string) (*s3.Client, error) {
+		regional := cfg.Copy()
+		regional.Region = region
+		return NewS3Client(regional, EndpointOptions{URL: srv.URL, PathStyle: true})
+	}
+	return s
+}
+
+func TestBucketRegionFromLocation(t *testing.T) {
+	calls := 0
+	s := testHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if _, ok := r.URL.Query()["location"]; !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">EU</LocationConstraint>`))
+	})
+
+	for i := 0; i < 2; i++ {
+		region, err := s.BucketRegion("bucket")
+		if err != nil || region != "eu-west-1" {
+			t.Fatalf("BucketRegion = %q, %v", region, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("region looked up %d times, want it cached after the first", calls)
+	}
+}
+
+func TestBucketRegionFromRedirect(t *testing.T) {
+	s := testHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		// what S3 does for a HeadBucket sent to the wrong region
+		w.Header().Set("X-Amz-Bucket-Region", "us-west-2")
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+
+	region, err := s.BucketRegion("bucket")
+	if err != nil || region != "us-west-2" {
+		t.Fatalf("BucketRegion = %q, %v", region, err)
+	}
+	if s.client("bucket") == s.s3Client {
+		t.Error("expected a us-west-2 client, got the default one")
+	}
+}
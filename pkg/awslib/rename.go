@@ -0,0 +1,410 @@
+package awslib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CopyObject refuses sources over 5 GiB, those are copied a part at a time.
+// vars so the tests don't need 5 GiB objects.
+var (
+	maxCopySize  int64 = 5 * 1024 * 1024 * 1024
+	copyPartSize int64 = 512 * 1024 * 1024
+)
+
+// PendingRename is a folder rename that was started and hasn't finished, kept
+// on disk so it can be picked up again after a crash or ctrl+c
+type PendingRename struct {
+	Bucket  string    `json:"bucket"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Started time.Time `json:"started"`
+}
+
+// RenameSummary describes what RenamePrefix did
+type RenameSummary struct {
+	Moved int
+	// of those, how many were already copied by an earlier attempt
+	Resumed int
+	Failed  map[string]error
+}
+
+func (r RenameSummary) String() string {
+	msg := fmt.Sprintf("%d moved", r.Moved)
+	if r.Resumed > 0 {
+		msg += fmt.Sprintf(" (%d already copied)", r.Resumed)
+	}
+	if len(r.Failed) > 0 {
+		msg += fmt.Sprintf(", %d failed", len(r.Failed))
+	}
+	return msg
+}
+
+// RenameJournalFile is where unfinished folder renames are written down
+func RenameJournalFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".s3-tui", "renames.json")
+}
+
+// PendingRenames lists the unfinished renames in bucket, oldest first
+func PendingRenames(bucket string) ([]PendingRename, error) {
+	all, err := readRenameJournal()
+	if err != nil {
+		return nil, err
+	}
+	var pending []PendingRename
+	for _, r := range all {
+		if r.Bucket == bucket {
+			pending = append(pending, r)
+		}
+	}
+	return pending, nil
+}
+
+// ForgetRename drops a rename from the journal without finishing it
+func ForgetRename(r PendingRename) error {
+	return updateRenameJournal(func(all []PendingRename) []PendingRename {
+		return removeRename(all, r)
+	})
+}
+
+var journalMu sync.Mutex
+
+func readRenameJournal() ([]PendingRename, error) {
+	data, err := os.ReadFile(RenameJournalFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []PendingRename
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", RenameJournalFile(), err)
+	}
+	return all, nil
+}
+
+func updateRenameJournal(fn func([]PendingRename) []PendingRename) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	all, err := readRenameJournal()
+	if err != nil {
+		return err
+	}
+	all = fn(all)
+	if len(all) == 0 {
+		err := os.Remove(RenameJournalFile())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(RenameJournalFile(), data, 0o600)
+}
+
+func removeRename(all []PendingRename, r PendingRename) []PendingRename {
+	var kept []PendingRename
+	for _, p := range all {
+		if p.Bucket != r.Bucket || p.From != r.From || p.To != r.To {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// startRename writes the rename down before anything is copied. If the same
+// rename is already in the journal that entry is returned, with its original
+// start time, so the caller knows it's resuming.
+func startRename(bucket string, from string, to string) (PendingRename, bool, error) {
+	r := PendingRename{Bucket: bucket, From: from, To: to, Started: time.Now().UTC()}
+	resuming := false
+	err := updateRenameJournal(func(all []PendingRename) []PendingRename {
+		for _, p := range all {
+			if p.Bucket == bucket && p.From == from && p.To == to {
+				r, resuming = p, true
+				return all
+			}
+		}
+		return append(all, r)
+	})
+	return r, resuming, err
+}
+
+// checkRename catches renames that can't work before anything is touched
+func checkRename(from string, to string) (string, error) {
+	if from == "" || !strings.HasSuffix(from, "/") {
+		return "", fmt.Errorf("%q isn't a folder", from)
+	}
+	if to != "" && !strings.HasSuffix(to, "/") {
+		to += "/"
+	}
+	if to == from {
+		return "", fmt.Errorf("%s is already called that", from)
+	}
+	if strings.HasPrefix(to, from) {
+		return "", fmt.Errorf("can't move %s inside itself", from)
+	}
+	return to, nil
+}
+
+// RenamePrefix moves everything under from to to. Each object is copied server
+// side, checked at the destination and only then are the originals deleted,
+// so stopping halfway never loses anything. Running the same rename again
+// skips objects an earlier attempt already copied. progress counts bytes.
+func (s *S3Handler) RenamePrefix(bucket string, from string, to string, progress ProgressFunc) (RenameSummary, error) {
+	summary := RenameSummary{Failed: map[string]error{}}
+	to, err := checkRename(from, to)
+	if err != nil {
+		return summary, opError("rename", bucket, from, apiError("InvalidArgument", "%v", err))
+	}
+	rename, resuming, err := startRename(bucket, from, to)
+	if err != nil {
+		return summary, opError("rename", bucket, from, err)
+	}
+
+	sources, err := s.listObjects(bucket, from)
+	if err != nil {
+		return summary, err
+	}
+	var done map[string]types.Object
+	if resuming {
+		// anything at the destination written since the rename started is ours
+		if done, err = s.listObjects(bucket, to); err != nil {
+			return summary, err
+		}
+	}
+
+	var total int64
+	sizes := map[string]int64{}
+	var keys []string
+	for key, obj := range sources {
+		total += obj.Size
+		sizes[key] = obj.Size
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tracker := newProgressTracker(total, progress)
+
+	var mu sync.Mutex
+	var verified []string
+	batch, err := runBatch(keys, nil, func(ctx context.Context, key string) error {
+		dest := to + strings.TrimPrefix(key, from)
+		if d, ok := done[dest]; ok && d.Size == sizes[key] && !aws.ToTime(d.LastModified).Before(rename.Started.Truncate(time.Second)) {
+			tracker.add(sizes[key])
+			mu.Lock()
+			verified = append(verified, key)
+			summary.Resumed++
+			mu.Unlock()
+			return nil
+		}
+		if err := s.copyObject(ctx, bucket, key, bucket, dest, sizes[key], "", tracker); err != nil {
+			return opError("rename", bucket, key, err)
+		}
+		if err := s.verifyCopy(ctx, bucket, dest, sizes[key]); err != nil {
+			return opError("rename", bucket, key, err)
+		}
+		mu.Lock()
+		verified = append(verified, key)
+		mu.Unlock()
+		return nil
+	})
+	summary.Failed = batch.Failed
+	if err != nil {
+		return summary, err
+	}
+
+	deleted, err := s.DeleteKeys(bucket, verified, nil)
+	for key, e := range deleted.Failed {
+		summary.Failed[key] = e
+	}
+	summary.Moved = deleted.Done
+	if err != nil {
+		return summary, err
+	}
+	if len(summary.Failed) == 0 {
+		if err := updateRenameJournal(func(all []PendingRename) []PendingRename {
+			return removeRename(all, rename)
+		}); err != nil {
+			return summary, opError("rename", bucket, from, err)
+		}
+	}
+	return summary, nil
+}
+
+// listObjects is everything under prefix by key
+func (s *S3Handler) listObjects(bucket string, prefix string) (map[string]types.Object, error) {
+	objects := map[string]types.Object{}
+	paginator := s3.NewListObjectsV2Paginator(s.client(bucket), urlEncoded(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}))
+	for paginator.HasMorePages() {
+		output, err := nextPage(paginator)
+		if err != nil {
+			return nil, opError("list", bucket, prefix, err)
+		}
+		for _, obj := range output.Contents {
+			objects[aws.ToString(obj.Key)] = obj
+		}
+	}
+	return objects, nil
+}
+
+// copyObject copies key to dest in one request, or part by part when it's too
+// big for CopyObject. The buckets can be in different regions, the request
+// goes to the destination's. class is the copy's storage class, "" keeps the
+// source's.
+func (s *S3Handler) copyObject(ctx context.Context, srcBucket string, key string, bucket string, dest string, size int64, class string, tracker *progressTracker) error {
+	if size <= maxCopySize {
+		input := &s3.CopyObjectInput{
+			Bucket:     aws.String(bucket),
+			CopySource: aws.String(copySource(srcBucket, key)),
+			Key:        aws.String(dest),
+		}
+		if class != "" {
+			input.StorageClass = types.StorageClass(class)
+			input.MetadataDirective = types.MetadataDirectiveCopy
+		}
+		_, err := s.client(bucket).CopyObject(ctx, input)
+		if err == nil {
+			tracker.add(size)
+		}
+		return err
+	}
+	return s.multipartCopy(ctx, srcBucket, key, bucket, dest, size, class, tracker)
+}
+
+// multipartCopy is copyObject for objects over 5 GiB. A multipart upload
+// doesn't bring the source's headers along, so they're copied over by hand.
+func (s *S3Handler) multipartCopy(ctx context.Context, srcBucket string, key string, bucket string, dest string, size int64, class string, tracker *progressTracker) error {
+	head, err := s.client(srcBucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+
+	if class != "" {
+		head.StorageClass = types.StorageClass(class)
+	}
+
+	partSize := copyPartSize
+	if size/maxParts >= partSize {
+		partSize = size/maxParts + 1
+	}
+	numParts := int((size + partSize - 1) / partSize)
+
+	create, err := s.client(bucket).CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(dest),
+		ContentType:        head.ContentType,
+		ContentEncoding:    head.ContentEncoding,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+		Metadata:           head.Metadata,
+		StorageClass:       head.StorageClass,
+	})
+	if err != nil {
+		return err
+	}
+	uploadID := create.UploadId
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]types.CompletedPart, numParts)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for w := 0; w < uploadWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				offset := int64(i) * partSize
+				end := offset + partSize - 1
+				if end >= size {
+					end = size - 1
+				}
+				out, err := s.client(bucket).UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+					Bucket:          aws.String(bucket),
+					Key:             aws.String(dest),
+					UploadId:        uploadID,
+					PartNumber:      int32(i + 1),
+					CopySource:      aws.String(copySource(srcBucket, key)),
+					CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+				})
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				parts[i] = types.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: int32(i + 1)}
+				tracker.add(end - offset + 1)
+			}
+		}()
+	}
+
+	for i := 0; i < numParts && ctx.Err() == nil; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		s.client(bucket).AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(dest),
+			UploadId: uploadID,
+		})
+		return firstErr
+	}
+
+	_, err = s.client(bucket).CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(dest),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+// verifyCopy checks dest made it and is the right size. ETags can't be
+// compared, multipart copies and KMS encrypted objects get new ones.
+func (s *S3Handler) verifyCopy(ctx context.Context, bucket string, dest string, size int64) error {
+	head, err := s.client(bucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(dest),
+	})
+	if err != nil {
+		return err
+	}
+	if head.ContentLength != size {
+		return apiError("BadCopy", "%s is %d bytes, expected %d", dest, head.ContentLength, size)
+	}
+	return nil
+}
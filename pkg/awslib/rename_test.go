@@ -0,0 +1,171 @@
+package awslib
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRenamePrefix(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	f := newFakeS3("bucket")
+	f.put("bucket", "old/", nil)
+	f.put("bucket", "old/a.txt", []byte("aaa"))
+	f.put("bucket", "old/deep/b.txt", []byte("bbbb"))
+	f.put("bucket", "older.txt", []byte("not in old/"))
+	s := f.handler(t)
+
+	var done, total atomic.Int64
+	summary, err := s.RenamePrefix("bucket", "old/", "new", func(d, t int64) {
+		done.Store(d)
+		total.Store(t)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Moved != 3 || len(summary.Failed) != 0 {
+		t.Fatalf("summary = %s", summary)
+	}
+	want := []string{"new/", "new/a.txt", "new/deep/b.txt", "older.txt"}
+	if got := f.keys("bucket"); !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if data, _ := f.get("bucket", "new/deep/b.txt"); string(data) != "bbbb" {
+		t.Errorf("new/deep/b.txt = %q", data)
+	}
+	if done.Load() != 7 || total.Load() != 7 {
+		t.Errorf("progress = %d/%d, want 7/7", done.Load(), total.Load())
+	}
+	if pending, _ := PendingRenames("bucket"); len(pending) != 0 {
+		t.Errorf("finished rename left in the journal: %v", pending)
+	}
+}
+
+func TestRenamePrefixMultipartCopy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	oldMax, oldPart := maxCopySize, copyPartSize
+	maxCopySize, copyPartSize = 10, 4
+	t.Cleanup(func() { maxCopySize, copyPartSize = oldMax, oldPart })
+
+	f := newFakeS3("bucket")
+	big := []byte("0123456789abc")
+	f.put("bucket", "src/big.bin", big)
+	f.buckets["bucket"]["src/big.bin"].headers.Set("Content-Type", "application/x-test")
+	f.put("bucket", "src/small.txt", []byte("small"))
+	s := f.handler(t)
+
+	summary, err := s.RenamePrefix("bucket", "src/", "dst/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Moved != 2 {
+		t.Fatalf("summary = %s", summary)
+	}
+	if data, _ := f.get("bucket", "dst/big.bin"); !bytes.Equal(data, big) {
+		t.Errorf("dst/big.bin = %q", data)
+	}
+	// 13 bytes in parts of 4
+	if n := f.count("UploadPartCopy"); n != 4 {
+		t.Errorf("%d parts copied, want 4", n)
+	}
+	if n := f.count("CopyObject"); n != 1 {
+		t.Errorf("%d single copies, want 1 for small.txt", n)
+	}
+	if got := f.buckets["bucket"]["dst/big.bin"].headers.Get("Content-Type"); got != "application/x-test" {
+		t.Errorf("content type = %q, multipart copy dropped it", got)
+	}
+}
+
+func TestRenameObjectMultipartCopy(t *testing.T) {
+	oldMax, oldPart := maxCopySize, copyPartSize
+	maxCopySize, copyPartSize = 10, 4
+	t.Cleanup(func() { maxCopySize, copyPartSize = oldMax, oldPart })
+
+	f := newFakeS3("bucket")
+	big := []byte("0123456789abc")
+	f.put("bucket", "big.bin", big)
+	s := f.handler(t)
+
+	if ok, err := s.RenameObject("bucket", "big.bin", "moved.bin"); !ok || err != nil {
+		t.Fatalf("RenameObject = %v, %v", ok, err)
+	}
+	if data, _ := f.get("bucket", "moved.bin"); !bytes.Equal(data, big) {
+		t.Errorf("moved.bin = %q", data)
+	}
+	if got := f.keys("bucket"); !reflect.DeepEqual(got, []string{"moved.bin"}) {
+		t.Errorf("keys = %v", got)
+	}
+	if n := f.count("UploadPartCopy"); n != 4 {
+		t.Errorf("%d parts copied, want 4", n)
+	}
+	if n := f.count("CopyObject"); n != 0 {
+		t.Errorf("%d single copies of a key too big for one", n)
+	}
+}
+
+func TestRenamePrefixResumes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	f := newFakeS3("bucket")
+	f.put("bucket", "old/a.txt", []byte("a"))
+	f.put("bucket", "old/b.txt", []byte("b"))
+	s := f.handler(t)
+
+	// everything copies but the originals can't be deleted, as if we'd been
+	// stopped between the two
+	f.fail = func(op string, bucket string, key string) (string, int) {
+		if op == "DeleteKey" {
+			return "AccessDenied", http.StatusForbidden
+		}
+		return "", 0
+	}
+	summary, err := s.RenamePrefix("bucket", "old/", "new/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Failed) != 2 {
+		t.Fatalf("summary = %s", summary)
+	}
+	pending, err := PendingRenames("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].From != "old/" || pending[0].To != "new/" {
+		t.Fatalf("pending = %v", pending)
+	}
+
+	f.fail = nil
+	copies := f.count("CopyObject")
+	summary, err = s.RenamePrefix("bucket", "old/", "new/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Moved != 2 || summary.Resumed != 2 || len(summary.Failed) != 0 {
+		t.Errorf("summary = %s", summary)
+	}
+	if n := f.count("CopyObject") - copies; n != 0 {
+		t.Errorf("resuming copied %d objects again", n)
+	}
+	if got := f.keys("bucket"); !reflect.DeepEqual(got, []string{"new/a.txt", "new/b.txt"}) {
+		t.Errorf("keys = %v", got)
+	}
+	if pending, _ := PendingRenames("bucket"); len(pending) != 0 {
+		t.Errorf("pending = %v", pending)
+	}
+}
+
+func TestRenamePrefixRefusesBadTargets(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := NewMemoryStore()
+	m.Put("bucket", "a/x.txt", []byte("x"))
+
+	for _, to := range []string{"a/", "a", "a/b/"} {
+		if _, err := m.RenamePrefix("bucket", "a/", to, nil); err == nil {
+			t.Errorf("renaming a/ to %q worked", to)
+		}
+	}
+	if _, ok := m.Get("bucket", "a/x.txt"); !ok {
+		t.Error("a/x.txt went missing")
+	}
+}
@@ -3,12 +3,12 @@ package awslib
 import (
 	"bytes"
 	"context"
-	"fmt"
-	_ "strings"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/rogep/s3-tui/pkg/utils"
 )
@@ -17,80 +17,30 @@ const byteRange string = "bytes=0-1000"
 
 type S3Handler struct {
 	s3Client *s3.Client
+	cfg      aws.Config
+	region   string
+	// builds clients for other regions, nil when region discovery is off
+	regionalClient func(region string) (*s3.Client, error)
+	// endpoint is the custom endpoint's URL, "" when it's AWS
+	endpoint string
+
+	mu            sync.Mutex
+	bucketRegions map[string]string
+	clients       map[string]*s3.Client
 }
 
 func NewS3Handler(client s3.Client) *S3Handler {
 	return &S3Handler{
-		s3Client: &client,
+		s3Client:      &client,
+		bucketRegions: map[string]string{},
+		clients:       map[string]*s3.Client{},
 	}
 }
 
+// GetDirectoryStructure lists everything directly under prefix at once. The gui
+// pages through ListDirectory instead so it can show the first page early.
 func (s *S3Handler) GetDirectoryStructure(bucket string, delimiter string, prefix string) ([]string, error) {
-	folders, err := s.GetFolderNames(bucket, delimiter, prefix)
-	if err != nil {
-		return nil, err
-	}
-
-	keys, err := s.GetKeyNames(bucket, delimiter, prefix)
-	if err != nil {
-		return nil, err
-	}
-
-	return append(folders, keys...), nil
-}
-
-func (s *S3Handler) GetFolderNames(bucket string, delimiter string, prefix string) ([]string, error) {
-	params := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(bucket),
-		Delimiter: aws.String(delimiter),
-		Prefix:    aws.String(prefix),
-	}
-
-	paginator := s3.NewListObjectsV2Paginator(s.s3Client, params)
-	var folders []string
-	if prefix != "" {
-		folders = append(folders, "..")
-	}
-
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
-		if err != nil {
-			fmt.Printf("error: %v", err)
-			return nil, err
-		}
-		for _, value := range output.CommonPrefixes {
-			key := *value.Prefix
-			folders = append(folders, key)
-		}
-	}
-
-	return folders, nil
-}
-
-func (s *S3Handler) GetKeyNames(bucket string, delimiter string, prefix string) ([]string, error) {
-	params := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(bucket),
-		Delimiter: aws.String(delimiter),
-		Prefix:    aws.String(prefix),
-	}
-	paginator := s3.NewListObjectsV2Paginator(s.s3Client, params)
-	var keys []string
-
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(context.TODO())
-		if err != nil {
-			fmt.Printf("error: %v", err)
-			return nil, err
-		}
-		for _, value := range output.Contents {
-			key := *value.Key
-			if key[len(key)-1:] == "/" || key == "" {
-				continue
-			}
-			keys = append(keys, key)
-		}
-	}
-	return keys, nil
+	return readDirectory(s.ListDirectory(bucket, delimiter, prefix), prefix)
 }
 
 func (s *S3Handler) GetBuckets() ([]string, error) {
@@ -99,7 +49,7 @@ func (s *S3Handler) GetBuckets() ([]string, error) {
 	var buckets []string
 
 	if err != nil {
-		return nil, err
+		return nil, opError("list buckets", "", "", err)
 	}
 
 	for _, val := range res.Buckets {
@@ -110,12 +60,16 @@ func (s *S3Handler) GetBuckets() ([]string, error) {
 
 func (s *S3Handler) CreateBucket(name string, length int) (bool, error) {
 	input := &s3.CreateBucketInput{
-		Bucket: aws.String(name),
+		Bucket:                    aws.String(name),
+		CreateBucketConfiguration: s.bucketConfiguration(),
 	}
 
 	_, err := s.s3Client.CreateBucket(context.TODO(), input)
-	// TODO: handle collision by adding the hex if needed. Need to import utils
 	if err != nil {
+		// only a name clash is worth trying again with a suffix
+		if ErrorKindOf(err) != KindConflict {
+			return false, opError("create bucket", name, "", err)
+		}
 		hash, hashErr := utils.GenerateRandomString(length)
 		if hashErr != nil {
 			return false, hashErr
@@ -123,36 +77,53 @@ func (s *S3Handler) CreateBucket(name string, length int) (bool, error) {
 		// S3 buckets cannot exceed 63 chars -- ui caps user input at 54 chars
 		uniqueBucketName := name + "-" + hash
 		input = &s3.CreateBucketInput{
-			Bucket: aws.String(uniqueBucketName),
+			Bucket:                    aws.String(uniqueBucketName),
+			CreateBucketConfiguration: s.bucketConfiguration(),
 		}
 		_, err = s.s3Client.CreateBucket(context.TODO(), input)
 		if err != nil {
+			if ErrorKindOf(err) != KindConflict {
+				return false, opError("create bucket", uniqueBucketName, "", err)
+			}
 			// enter some small brain recursion to generate a new hash
 			// inefficient as initial collision will always be hit. but bruh who cares
 			return s.CreateBucket(name, length)
 		}
-		return false, err
+		return false, nil
 	}
 	return true, nil
 }
 
+// bucketConfiguration creates buckets in the client's region. us-east-1 is the
+// default and S3 rejects it if you ask for it explicitly
+func (s *S3Handler) bucketConfiguration() *types.CreateBucketConfiguration {
+	if s.region == "" || s.region == "us-east-1" {
+		return nil
+	}
+	return &types.CreateBucketConfiguration{
+		LocationConstraint: types.BucketLocationConstraint(s.region),
+	}
+}
+
 // TODO: write utility function that checks the byte slice for non utf-8 chars
 // if this is present, display a /// cannot display binary /// message
 func (s *S3Handler) PreviewFile(bucket string, key string) ([]byte, error) {
-	output, err := s.s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+	output, err := s.client(bucket).GetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 		Range:  aws.String(byteRange),
 	})
 	if err != nil {
-		fmt.Println("Error getting object ", err)
+		return nil, opError("preview", bucket, key, err)
 	}
+	defer output.Body.Close()
 
 	// Convert the content to byte slice
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(output.Body)
-	byteContent := buf.Bytes()
-	return byteContent, nil
+	if _, err := buf.ReadFrom(output.Body); err != nil {
+		return nil, opError("preview", bucket, key, err)
+	}
+	return buf.Bytes(), nil
 }
 
 func (s *S3Handler) IsGlacier(bucket string, key string) (bool, error) {
@@ -161,10 +132,9 @@ func (s *S3Handler) IsGlacier(bucket string, key string) (bool, error) {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	}
-	res, err := s.s3Client.HeadObject(context.TODO(), input)
+	res, err := s.client(bucket).HeadObject(context.TODO(), input)
 	if err != nil {
-		// i dunno what to return here
-		return false, err
+		return false, opError("check storage class", bucket, key, err)
 	}
 
 	if res.StorageClass == "GLACIER" {
@@ -184,44 +154,37 @@ func (s *S3Handler) DeleteObject(bucket string, key string) (bool, error) {
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	}
-	_, err := s.s3Client.DeleteObject(context.TODO(), input)
+	_, err := s.client(bucket).DeleteObject(context.TODO(), input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			default:
-				return false, aerr
-			}
-		} else {
-			return false, aerr
-		}
+		return false, opError("delete", bucket, key, err)
 	}
 	return true, nil
 }
 
 func (s *S3Handler) RenameObject(bucket string, oldKey string, newKey string) (bool, error) {
-	sourceKey := "/" + bucket + "/" + oldKey
-	if oldKey[len(oldKey)-1:] == "/" || oldKey == ".." {
+	if oldKey == ".." {
 		return false, nil
 	}
-	input := &s3.CopyObjectInput{
-		Bucket:     aws.String(bucket),
-		CopySource: aws.String(sourceKey),
-		Key:        aws.String(newKey),
+	if strings.HasSuffix(oldKey, "/") {
+		summary, err := s.RenamePrefix(bucket, oldKey, newKey, nil)
+		return err == nil && len(summary.Failed) == 0, err
 	}
-
-	_, err := s.s3Client.CopyObject(context.TODO(), input)
+	// sized first, past 5 GiB it's copied in parts the way a folder's keys are
+	ctx := context.TODO()
+	head, err := s.client(bucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(oldKey),
+	})
 	if err != nil {
-		// if aerr, ok := err.(awserr.Error); ok {
-		// 	switch aerr.Code() {
-		// 	case s3.ErrCodeObjectNotInActiveTierError:
-		// 		fmt.Println(s3.ErrCodeObjectNotInActiveTierError, aerr.Error())
-		// 	default:
-		// 		fmt.Println(aerr.Error())
-		// 	}
-		// } else {
-		// 	fmt.Println(err.Error())
-		// }
-		return false, err
+		return false, opError("rename", bucket, oldKey, err)
+	}
+	size := head.ContentLength
+	if err := s.copyObject(ctx, bucket, oldKey, bucket, newKey, size, "", newProgressTracker(size, nil)); err != nil {
+		// glacier objects come back as InvalidObjectState until restored
+		return false, opError("rename", bucket, oldKey, err)
+	}
+	if err := s.verifyCopy(ctx, bucket, newKey, size); err != nil {
+		return false, opError("rename", bucket, oldKey, err)
 	}
 	res, err := s.DeleteObject(bucket, oldKey)
 	if !res {
@@ -0,0 +1,221 @@
+package awslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// ValidateCredentials checks a set of keys actually work by asking STS who
+// they belong to
+func ValidateCredentials(accessKey string, secretKey string, sessionToken string) (Identity, error) {
+	region := resolveRegion("", "")
+	if region == "" {
+		region = defaultRegion
+	}
+	cfg, err := loadConfig(region, config.WithCredentialsProvider(
+		credentials.NewStaticCredentialsProvider(accessKey, secretKey, sessionToken)))
+	if err != nil {
+		return Identity{}, err
+	}
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Account: aws.ToString(out.Account), ARN: aws.ToString(out.Arn)}, nil
+}
+
+// SaveProfile adds profile to the credentials file, or updates its keys if it's
+// already there. Everything else in the file (comments, other profiles, other
+// settings in this profile) is left alone. An empty sessionToken removes any
+// old token, as it won't be valid for the new keys.
+func SaveProfile(profile string, accessKey string, secretKey string, sessionToken string) error {
+	if profile == "" || strings.ContainsAny(profile, "[] \t") {
+		return fmt.Errorf("invalid profile name %q", profile)
+	}
+	if accessKey == "" || secretKey == "" {
+		return errors.New("access key and secret access key are required")
+	}
+
+	path := CredentialsFile()
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	updated := setProfileKeys(string(content), profile, [][2]string{
+		{"aws_access_key_id", accessKey},
+		{"aws_secret_access_key", secretKey},
+		{"aws_session_token", sessionToken},
+	})
+	// never write out something we can't read back in
+	if _, err := parseINI(strings.NewReader(updated), path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(updated), 0o600)
+}
+
+// SaveSSOProfile adds profile to the config file as one that signs in through
+// the [sso-session session] already there, or points it at that session if
+// it's already a profile. ConnectProfile then hands it to the SDK, which uses
+// the token "aws sso login" leaves behind.
+func SaveSSOProfile(profile string, session string, accountID string, roleName string) error {
+	if profile == "" || strings.ContainsAny(profile, "[] \t") {
+		return fmt.Errorf("invalid profile name %q", profile)
+	}
+	if session == "" || accountID == "" || roleName == "" {
+		return errors.New("sso session, account id and role name are required")
+	}
+
+	path := ConfigFile()
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	sections, err := parseINI(strings.NewReader(string(content)), path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, section := range sections {
+		found = found || section.name == "sso-session "+session
+	}
+	if !found {
+		return fmt.Errorf("no [sso-session %s] in %s, add it with \"aws configure sso-session\"", session, path)
+	}
+
+	// the config file names everything but default "profile x"
+	header := "profile " + profile
+	if profile == "default" {
+		header = profile
+	}
+	updated := setProfileKeys(string(content), header, [][2]string{
+		{"sso_session", session},
+		{"sso_account_id", accountID},
+		{"sso_role_name", roleName},
+	})
+	if _, err := parseINI(strings.NewReader(updated), path); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(updated), 0o600)
+}
+
+// setProfileKeys rewrites the given keys inside [profile], appending the
+// section if it doesn't exist. Empty values remove the key.
+func setProfileKeys(content string, profile string, keys [][2]string) string {
+	lines := strings.Split(content, "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if strings.Join(strings.Fields(trimmed[1:len(trimmed)-1]), " ") == profile {
+			start = i
+		}
+	}
+
+	if start < 0 {
+		// new profile at the end, separated by a blank line
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+profile+"]")
+		for _, kv := range keys {
+			if kv[1] != "" {
+				lines = append(lines, kv[0]+" = "+kv[1])
+			}
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	values := map[string]string{}
+	for _, kv := range keys {
+		values[kv[0]] = kv[1]
+	}
+	written := map[string]bool{}
+
+	var section []string
+	for _, line := range lines[start+1 : end] {
+		key, _, ok := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value, managed := values[key]
+		if !ok || !managed {
+			section = append(section, line)
+			continue
+		}
+		if value != "" && !written[key] {
+			section = append(section, key+" = "+value)
+		}
+		written[key] = true
+	}
+
+	// new keys go after the last setting, before any blank lines
+	insert := len(section)
+	for insert > 0 && strings.TrimSpace(section[insert-1]) == "" {
+		insert--
+	}
+	var missing []string
+	for _, kv := range keys {
+		if kv[1] != "" && !written[kv[0]] {
+			missing = append(missing, kv[0]+" = "+kv[1])
+		}
+	}
+	section = append(section[:insert], append(missing, section[insert:]...)...)
+
+	result := append([]string{}, lines[:start+1]...)
+	result = append(result, section...)
+	result = append(result, lines[end:]...)
+	return strings.Join(result, "\n")
+}
+
+// writeFileAtomic writes to a temp file next to path and renames it over the
+// top, so a crash halfway through never leaves a truncated credentials file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,149 @@
+package awslib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveProfileNewFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".aws", "credentials")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	if err := SaveProfile("work", "AKIAWORK", "workSecret", ""); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	work, err := FindProfile(profiles, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Get("aws_access_key_id") != "AKIAWORK" || work.Get("aws_secret_access_key") != "workSecret" {
+		t.Errorf("work = %+v", work)
+	}
+}
+
+func TestSaveProfileKeepsTheRest(t *testing.T) {
+	writeAWSFiles(t, `# my keys, don't touch
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = defaultSecret
+
+[work]
+; rotated every 90 days
+aws_access_key_id=AKIAOLD
+region = eu-west-1
+aws_secret_access_key=oldSecret
+aws_session_token = oldToken
+
+[other]
+aws_access_key_id = AKIAOTHER
+aws_secret_access_key = otherSecret
+`, "")
+
+	if err := SaveProfile("work", "AKIANEW", "newSecret", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile("fresh", "AKIAFRESH", "freshSecret", "freshToken"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(CredentialsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# my keys, don't touch
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = defaultSecret
+
+[work]
+; rotated every 90 days
+aws_access_key_id = AKIANEW
+region = eu-west-1
+aws_secret_access_key = newSecret
+
+[other]
+aws_access_key_id = AKIAOTHER
+aws_secret_access_key = otherSecret
+
+[fresh]
+aws_access_key_id = AKIAFRESH
+aws_secret_access_key = freshSecret
+aws_session_token = freshToken
+`
+	if string(content) != want {
+		t.Errorf("credentials file =\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestSetProfileKeysAddsMissingKeys(t *testing.T) {
+	got := setProfileKeys("[work]\nregion = eu-west-1\n\n[other]\nregion = us-east-2\n", "work", [][2]string{
+		{"aws_access_key_id", "AKIA"},
+		{"aws_secret_access_key", "secret"},
+		{"aws_session_token", ""},
+	})
+	want := "[work]\nregion = eu-west-1\naws_access_key_id = AKIA\naws_secret_access_key = secret\n\n[other]\nregion = us-east-2\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSaveProfileRejectsBadInput(t *testing.T) {
+	writeAWSFiles(t, "[default]\naws_access_key_id = a\naws_secret_access_key = b\n", "")
+	for _, name := range []string{"", "has space", "[weird]"} {
+		if err := SaveProfile(name, "AKIA", "secret", ""); err == nil {
+			t.Errorf("expected an error for profile name %q", name)
+		}
+	}
+	if err := SaveProfile("work", "", "secret", ""); err == nil {
+		t.Error("expected an error for a missing access key")
+	}
+	content, _ := os.ReadFile(CredentialsFile())
+	if strings.Contains(string(content), "AKIA") {
+		t.Errorf("bad input was written: %s", content)
+	}
+}
+
+func TestSaveSSOProfile(t *testing.T) {
+	writeAWSFiles(t, "", `# sso
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+
+[profile other]
+region = eu-west-1
+`)
+
+	if err := SaveSSOProfile("work", "missing", "123456789012", "ReadOnly"); err == nil {
+		t.Error("saved a profile using an sso-session that isn't there")
+	}
+	if err := SaveSSOProfile("work", "corp", "123456789012", "ReadOnly"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(os.Getenv("AWS_CONFIG_FILE"))
+	want := "[profile work]\nsso_session = corp\nsso_account_id = 123456789012\nsso_role_name = ReadOnly\n"
+	if !strings.HasPrefix(string(content), "# sso\n[sso-session corp]") || !strings.HasSuffix(string(content), "\n\n"+want) {
+		t.Errorf("config file =\n%s", content)
+	}
+
+	// the SDK builds its credentials, which needs an "aws sso login" only once
+	// they're used
+	if _, err := ConnectProfile("work", "", EndpointOptions{}); err != nil {
+		t.Errorf("connecting to work: %v", err)
+	}
+}
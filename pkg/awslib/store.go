@@ -0,0 +1,42 @@
+package awslib
+
+import (
+	"context"
+	"io"
+)
+
+// ObjectStore is everything the gui needs from S3. S3Handler is the real thing,
+// MemoryStore is an in-memory stand-in for tests.
+type ObjectStore interface {
+	Identity() (Identity, error)
+	RefreshCredentials() error
+	GetBuckets() ([]string, error)
+	CreateBucket(name string, length int) (bool, error)
+	BucketRegion(bucket string) (string, error)
+	GetDirectoryStructure(bucket string, delimiter string, prefix string) ([]string, error)
+	ListDirectory(bucket string, delimiter string, prefix string) DirectoryLister
+	PreviewFile(bucket string, key string) ([]byte, error)
+	ReadRange(ctx context.Context, bucket string, key string, offset int64, length int64) (ObjectRange, error)
+	IsGlacier(bucket string, key string) (bool, error)
+	DeleteObject(bucket string, key string) (bool, error)
+	ListPrefix(bucket string, prefix string) (PrefixListing, error)
+	DeleteKeys(bucket string, keys []string, progress ProgressFunc) (BatchSummary, error)
+	RenameObject(bucket string, oldKey string, newKey string) (bool, error)
+	RenamePrefix(bucket string, from string, to string, progress ProgressFunc) (RenameSummary, error)
+	UploadFile(bucket string, prefix string, localPath string, progress ProgressFunc) (string, error)
+	UploadDirectory(bucket string, prefix string, localDir string, progress ProgressFunc) ([]string, error)
+	Download(bucket string, key string, localDir string, progress ProgressFunc) (DownloadSummary, error)
+	DownloadKeys(bucket string, keys []string, localDir string, progress ProgressFunc) (DownloadSummary, error)
+	CopyKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error)
+	MoveKeys(bucket string, jobs []CopyJob, progress ProgressFunc) (BatchSummary, error)
+	TagKeys(bucket string, keys []string, tags map[string]string, progress ProgressFunc) (BatchSummary, error)
+	ChangeStorageClass(bucket string, keys []string, sizes map[string]int64, class string, progress ProgressFunc) (BatchSummary, error)
+	CopyBetween(srcBucket string, dstBucket string, jobs []TransferJob, progress ProgressFunc) (BatchSummary, error)
+	OpenObject(bucket string, key string) (io.ReadCloser, int64, error)
+	WriteObject(bucket string, key string, body io.Reader, size int64) error
+}
+
+var (
+	_ ObjectStore = (*S3Handler)(nil)
+	_ ObjectStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,256 @@
+package awslib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// objects bigger than this are streamed in as a multipart upload, a part at a
+// time, rather than held in memory. A var so tests can stream small objects.
+var streamPartSize = minPartSize
+
+// parts being uploaded while the next one is read, each one is held in memory
+const streamUploads = 2
+
+// TransferJob is one object to copy between buckets. Size is only used for
+// progress.
+type TransferJob struct {
+	From string
+	To   string
+	Size int64
+}
+
+// TransferKeys copies jobs from srcBucket in src to dstBucket in dst. When both
+// ends are the same store S3 copies them server side, which works across
+// regions. Otherwise, e.g. the buckets belong to different accounts, every
+// object is streamed through this machine. With move the originals are deleted
+// once they've been copied. progress counts bytes.
+func TransferKeys(src ObjectStore, srcBucket string, dst ObjectStore, dstBucket string, jobs []TransferJob, move bool, progress ProgressFunc) (BatchSummary, error) {
+	var summary BatchSummary
+	var err error
+	if src == dst {
+		summary, err = src.CopyBetween(srcBucket, dstBucket, jobs, progress)
+	} else {
+		summary, err = streamKeys(src, srcBucket, dst, dstBucket, jobs, progress)
+	}
+	if !move || err != nil {
+		return summary, err
+	}
+
+	var copied []string
+	for _, j := range jobs {
+		if _, failed := summary.Failed[j.From]; !failed && (srcBucket != dstBucket || src != dst || j.From != j.To) {
+			copied = append(copied, j.From)
+		}
+	}
+	if len(copied) == 0 {
+		return summary, nil
+	}
+	deleted, err := src.DeleteKeys(srcBucket, copied, nil)
+	for k, e := range deleted.Failed {
+		summary.Failed[k] = e
+		summary.Done--
+	}
+	return summary, err
+}
+
+// streamKeys reads every object out of src and writes it to dst
+func streamKeys(src ObjectStore, srcBucket string, dst ObjectStore, dstBucket string, jobs []TransferJob, progress ProgressFunc) (BatchSummary, error) {
+	var total int64
+	byKey := map[string]TransferJob{}
+	var keys []string
+	for _, j := range jobs {
+		total += j.Size
+		byKey[j.From] = j
+		keys = append(keys, j.From)
+	}
+	tracker := newProgressTracker(total, progress)
+
+	return runBatch(keys, nil, func(ctx context.Context, key string) error {
+		j := byKey[key]
+		body, size, err := src.OpenObject(srcBucket, j.From)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return dst.WriteObject(dstBucket, j.To, io.TeeReader(body, tracker), size)
+	})
+}
+
+// CopyBetween copies objects server side from one bucket to another, both
+// readable with this handler's credentials
+func (s *S3Handler) CopyBetween(srcBucket string, dstBucket string, jobs []TransferJob, progress ProgressFunc) (BatchSummary, error) {
+	var total int64
+	byKey := map[string]TransferJob{}
+	var keys []string
+	for _, j := range jobs {
+		total += j.Size
+		byKey[j.From] = j
+		keys = append(keys, j.From)
+	}
+	tracker := newProgressTracker(total, progress)
+
+	return runBatch(keys, nil, func(ctx context.Context, key string) error {
+		j := byKey[key]
+		size, err := s.copySize(ctx, srcBucket, j.From, j.Size)
+		if err != nil {
+			return opError("copy", srcBucket, j.From, err)
+		}
+		return opError("copy", srcBucket, j.From, s.copyObject(ctx, srcBucket, j.From, dstBucket, j.To, size, "", tracker))
+	})
+}
+
+// copySize is the size to copy key as, given the size listed. Listings can be
+// stale, so past what CopyObject takes the multipart copy asks for the real one.
+func (s *S3Handler) copySize(ctx context.Context, bucket string, key string, size int64) (int64, error) {
+	if size <= maxCopySize {
+		return size, nil
+	}
+	head, err := s.client(bucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return head.ContentLength, nil
+}
+
+// OpenObject streams an object's contents, the caller closes it
+func (s *S3Handler) OpenObject(bucket string, key string) (io.ReadCloser, int64, error) {
+	output, err := s.client(bucket).GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, 0, opError("read", bucket, key, err)
+	}
+	return output.Body, output.ContentLength, nil
+}
+
+// WriteObject stores size bytes from body under key. body doesn't need to be
+// seekable, big objects are sent a part at a time.
+func (s *S3Handler) WriteObject(bucket string, key string, body io.Reader, size int64) error {
+	if size <= streamPartSize {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return opError("write", bucket, key, err)
+		}
+		_, err = s.client(bucket).PutObject(context.TODO(), &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(data),
+			ContentLength: int64(len(data)),
+			ContentType:   contentType(key),
+		})
+		return opError("write", bucket, key, err)
+	}
+	return opError("write", bucket, key, s.streamMultipart(bucket, key, body, size))
+}
+
+// streamMultipart reads body a part at a time, uploading each one while the
+// next is read
+func (s *S3Handler) streamMultipart(bucket string, key string, body io.Reader, size int64) error {
+	partSize := streamPartSize
+	if size/maxParts >= partSize {
+		partSize = size/maxParts + 1
+	}
+
+	create, err := s.client(bucket).CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: contentType(key),
+	})
+	if err != nil {
+		return err
+	}
+	uploadID := create.UploadId
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var mu sync.Mutex
+	var parts []types.CompletedPart
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	slots := make(chan struct{}, streamUploads)
+
+	for n := int32(1); ctx.Err() == nil; n++ {
+		buf := make([]byte, partSize)
+		read, err := io.ReadFull(body, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fail(err)
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(n int32, data []byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			out, err := s.client(bucket).UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:        aws.String(bucket),
+				Key:           aws.String(key),
+				UploadId:      uploadID,
+				PartNumber:    n,
+				ContentLength: int64(len(data)),
+				Body:          bytes.NewReader(data),
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{ETag: out.ETag, PartNumber: n})
+			mu.Unlock()
+		}(n, buf[:read])
+		if read < len(buf) {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		s.client(bucket).AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: uploadID,
+		})
+		return firstErr
+	}
+
+	sortParts(parts)
+	_, err = s.client(bucket).CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+// sortParts puts parts in order, S3 won't complete an upload otherwise
+func sortParts(parts []types.CompletedPart) {
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+}
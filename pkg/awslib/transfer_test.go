@@ -0,0 +1,81 @@
+package awslib
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestTransferKeysServerSide(t *testing.T) {
+	f := newFakeS3("from", "to")
+	f.put("from", "x/1.txt", []byte("one"))
+	f.put("from", "x/2.txt", []byte("two!"))
+	s := f.handler(t)
+
+	jobs := []TransferJob{
+		{From: "x/1.txt", To: "in/x/1.txt", Size: 3},
+		{From: "x/2.txt", To: "in/x/2.txt", Size: 4},
+	}
+	var done atomic.Int64
+	summary, err := TransferKeys(s, "from", s, "to", jobs, true, func(d, _ int64) { done.Store(d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Done != 2 || len(summary.Failed) != 0 {
+		t.Fatalf("summary = %s", summary)
+	}
+	if n := f.count("GetObject"); n != 0 {
+		t.Errorf("same credentials should copy server side, read %d objects", n)
+	}
+	if data, _ := f.get("to", "in/x/2.txt"); string(data) != "two!" {
+		t.Errorf("in/x/2.txt = %q", data)
+	}
+	if keys := f.keys("from"); len(keys) != 0 {
+		t.Errorf("moving left %v behind", keys)
+	}
+	if done.Load() != 7 {
+		t.Errorf("progress = %d, want 7", done.Load())
+	}
+}
+
+func TestTransferKeysStreamsBetweenAccounts(t *testing.T) {
+	old := streamPartSize
+	streamPartSize = 5
+	t.Cleanup(func() { streamPartSize = old })
+
+	src := newFakeS3("theirs")
+	src.put("theirs", "small.txt", []byte("abc"))
+	src.put("theirs", "big.bin", []byte("0123456789ab"))
+	dst := newFakeS3("ours")
+
+	jobs := []TransferJob{
+		{From: "small.txt", To: "copied/small.txt", Size: 3},
+		{From: "big.bin", To: "copied/big.bin", Size: 12},
+	}
+	var done atomic.Int64
+	summary, err := TransferKeys(src.handler(t), "theirs", dst.handler(t), "ours", jobs, false, func(d, _ int64) { done.Store(d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Done != 2 {
+		t.Fatalf("summary = %s, %v", summary, summary.Failed)
+	}
+	if data, _ := dst.get("ours", "copied/big.bin"); string(data) != "0123456789ab" {
+		t.Errorf("copied/big.bin = %q", data)
+	}
+	if data, _ := dst.get("ours", "copied/small.txt"); string(data) != "abc" {
+		t.Errorf("copied/small.txt = %q", data)
+	}
+	// 12 bytes in parts of 5
+	if n := dst.count("UploadPart"); n != 3 {
+		t.Errorf("%d parts uploaded, want 3", n)
+	}
+	if n := src.count("CopyObject") + dst.count("CopyObject"); n != 0 {
+		t.Errorf("%d server side copies between accounts", n)
+	}
+	if _, ok := src.get("theirs", "big.bin"); !ok {
+		t.Error("copying removed the original")
+	}
+	if done.Load() != 15 {
+		t.Errorf("progress = %d, want 15", done.Load())
+	}
+}
@@ -0,0 +1,285 @@
+package awslib
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// files at or above this size are sent as a multipart upload
+	multipartThreshold int64 = 64 * 1024 * 1024
+	// S3 wants every part but the last to be at least 5 MiB
+	minPartSize int64 = 8 * 1024 * 1024
+	// and caps an upload at 10,000 parts, so huge files get bigger parts
+	maxParts      int64 = 10000
+	uploadWorkers int   = 4
+)
+
+// ProgressFunc is called from transfer goroutines with the bytes moved so far
+// and the total number of bytes in the job. It must be safe for concurrent use.
+type ProgressFunc func(done int64, total int64)
+
+type progressTracker struct {
+	done  atomic.Int64
+	total int64
+	fn    ProgressFunc
+}
+
+func newProgressTracker(total int64, fn ProgressFunc) *progressTracker {
+	return &progressTracker{total: total, fn: fn}
+}
+
+func (t *progressTracker) add(n int64) {
+	done := t.done.Add(n)
+	if t.fn != nil {
+		t.fn(done, t.total)
+	}
+}
+
+// Write counts bytes without storing them so a tracker can sit in an io.MultiWriter
+func (t *progressTracker) Write(b []byte) (int, error) {
+	t.add(int64(len(b)))
+	return len(b), nil
+}
+
+// progressReader reports bytes as the SDK reads the body. The SDK may seek back
+// to the start (signing, retries) so seeks are reported as negative progress.
+type progressReader struct {
+	r       io.ReadSeeker
+	pos     int64
+	tracker *progressTracker
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.pos += int64(n)
+	p.tracker.add(int64(n))
+	return n, err
+}
+
+func (p *progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.r.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	p.tracker.add(pos - p.pos)
+	p.pos = pos
+	return pos, nil
+}
+
+// UploadFile uploads the local file at localPath into bucket under prefix and
+// returns the key it was written to. Large files are streamed from disk as a
+// multipart upload.
+func (s *S3Handler) UploadFile(bucket string, prefix string, localPath string, progress ProgressFunc) (string, error) {
+	key := prefix + filepath.Base(localPath)
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", opError("upload", bucket, key, err)
+	}
+	tracker := newProgressTracker(info.Size(), progress)
+	return key, opError("upload", bucket, key, s.uploadFile(context.TODO(), bucket, key, localPath, info.Size(), tracker))
+}
+
+// UploadDirectory uploads every regular file below localDir into bucket,
+// keeping the directory layout under prefix + the directory's own name.
+func (s *S3Handler) UploadDirectory(bucket string, prefix string, localDir string, progress ProgressFunc) ([]string, error) {
+	type upload struct {
+		path string
+		key  string
+		size int64
+	}
+
+	root := filepath.Clean(localDir)
+	base := prefix + filepath.Base(root) + "/"
+	var uploads []upload
+	var total int64
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		uploads = append(uploads, upload{path: p, key: base + filepath.ToSlash(rel), size: info.Size()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, opError("upload", bucket, base, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	tracker := newProgressTracker(total, progress)
+	jobs := make(chan upload)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i := 0; i < uploadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				if err := s.uploadFile(ctx, bucket, u.key, u.path, u.size, tracker); err != nil {
+					once.Do(func() {
+						firstErr = opError("upload", bucket, u.key, err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	var keys []string
+	for _, u := range uploads {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- u
+		keys = append(keys, u.key)
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return keys, nil
+}
+
+func (s *S3Handler) uploadFile(ctx context.Context, bucket string, key string, localPath string, size int64, tracker *progressTracker) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if size >= multipartThreshold {
+		return s.multipartUpload(ctx, bucket, key, file, size, tracker)
+	}
+
+	_, err = s.client(bucket).PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          &progressReader{r: file, tracker: tracker},
+		ContentLength: size,
+		ContentType:   contentType(key),
+	})
+	return err
+}
+
+func (s *S3Handler) multipartUpload(ctx context.Context, bucket string, key string, file *os.File, size int64, tracker *progressTracker) error {
+	partSize := minPartSize
+	if size/maxParts >= partSize {
+		partSize = size/maxParts + 1
+	}
+	numParts := int((size + partSize - 1) / partSize)
+
+	create, err := s.client(bucket).CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: contentType(key),
+	})
+	if err != nil {
+		return err
+	}
+	uploadID := create.UploadId
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]types.CompletedPart, numParts)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for w := 0; w < uploadWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				offset := int64(i) * partSize
+				length := partSize
+				if offset+length > size {
+					length = size - offset
+				}
+				// *os.File supports concurrent ReadAt so the workers can share it
+				body := &progressReader{r: io.NewSectionReader(file, offset, length), tracker: tracker}
+				out, err := s.client(bucket).UploadPart(ctx, &s3.UploadPartInput{
+					Bucket:        aws.String(bucket),
+					Key:           aws.String(key),
+					UploadId:      uploadID,
+					PartNumber:    int32(i + 1),
+					ContentLength: length,
+					Body:          body,
+				})
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				parts[i] = types.CompletedPart{ETag: out.ETag, PartNumber: int32(i + 1)}
+			}
+		}()
+	}
+
+	for i := 0; i < numParts && ctx.Err() == nil; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		// don't leave orphaned parts around racking up storage costs
+		s.client(bucket).AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(bucket),
+			Key:      aws.String(key),
+			UploadId: uploadID,
+		})
+		return firstErr
+	}
+
+	_, err = s.client(bucket).CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+func contentType(key string) *string {
+	t := mime.TypeByExtension(path.Ext(key))
+	if t == "" {
+		return nil
+	}
+	return aws.String(t)
+}
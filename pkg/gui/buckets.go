@@ -0,0 +1,81 @@
+package gui
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// max number of bucket regions looked up at once
+const regionWorkers = 8
+
+// bucketLabel is what the buckets list shows, e.g. "my-bucket (eu-west-1)".
+// Bucket names can't contain spaces so the name is always the first field.
+func bucketLabel(name string, region string) string {
+	if region == "" {
+		return name
+	}
+	return name + " (" + region + ")"
+}
+
+func bucketFromLabel(label string) string {
+	name, _, _ := strings.Cut(label, " ")
+	return name
+}
+
+// loadBuckets fills the buckets list with names straight away and then looks up
+// each bucket's region in the background, adding it to the label as it arrives
+func loadBuckets(s awslib.ObjectStore, buckets *tview.List, names []string) {
+	buckets.Clear()
+	initialBuckets = nil
+	for _, name := range names {
+		buckets.AddItem(name, "", 0, nil)
+		initialBuckets = append(initialBuckets, name)
+	}
+
+	a := app
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < regionWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				region, err := s.BucketRegion(name)
+				if err != nil {
+					continue
+				}
+				label := bucketLabel(name, region)
+				a.QueueUpdateDraw(func() {
+					setBucketLabel(buckets, name, label)
+				})
+			}
+		}()
+	}
+	go func() {
+		for _, name := range names {
+			jobs <- name
+		}
+		close(jobs)
+		wg.Wait()
+	}()
+}
+
+// setBucketLabel relabels name in both the list (which may currently be
+// filtered by a search) and the unfiltered copy used for searching
+func setBucketLabel(buckets *tview.List, name string, label string) {
+	for i, val := range initialBuckets {
+		if bucketFromLabel(val) == name {
+			initialBuckets[i] = label
+		}
+	}
+	for i := 0; i < buckets.GetItemCount(); i++ {
+		text, _ := buckets.GetItemText(i)
+		if bucketFromLabel(text) == name {
+			buckets.SetItemText(i, label, "")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// clipboardItems is what was last yanked or cut. It holds on to the store it
+// came from so it survives changing bucket and swapping credentials.
+type clipboardItems struct {
+	store   awslib.ObjectStore
+	profile string
+	bucket  string
+	keys    []string
+	cut     bool
+}
+
+var clipboard *clipboardItems
+
+// yank puts the selection on the clipboard, to be copied (or moved if cut)
+// wherever it's pasted
+func yank(s awslib.ObjectStore, files *tview.List, cut bool) {
+	keys := selection(files)
+	if len(keys) == 0 || bucketName == "" {
+		return
+	}
+	clipboard = &clipboardItems{store: s, profile: envName, bucket: bucketName, keys: keys, cut: cut}
+	clearMarks()
+}
+
+func clipboardText() string {
+	if clipboard == nil {
+		return ""
+	}
+	verb := "Yanked"
+	if clipboard.cut {
+		verb = "Cut"
+	}
+	from := "s3://" + clipboard.bucket
+	if clipboard.profile != envName {
+		from = clipboard.profile + " " + from
+	}
+	return fmt.Sprintf("[green]%s %s from %s[white] - ", verb, describe(clipboard.keys), from)
+}
+
+// transferJobs is copyJobs for pasting, possibly into another bucket
+func (e expandedSelection) transferJobs(dest string) []awslib.TransferJob {
+	var jobs []awslib.TransferJob
+	for _, item := range sortedItems(e.items) {
+		parent := parentPrefix(item)
+		for _, key := range e.items[item] {
+			jobs = append(jobs, awslib.TransferJob{From: key, To: dest + key[len(parent):], Size: e.sizes[key]})
+		}
+	}
+	return jobs
+}
+
+// paste copies whatever is on the clipboard into the folder being browsed.
+// Pasting something that was cut moves it and empties the clipboard.
+func paste(s awslib.ObjectStore, files *tview.List) {
+	clip := clipboard
+	if clip == nil || bucketName == "" {
+		return
+	}
+	bucket, dest := bucketName, currentPrefix()
+	verb := "Pasting"
+	if clip.cut {
+		verb = "Moving"
+	}
+	name := describe(clip.keys)
+	originalTitle := files.GetTitle()
+	files.SetTitle(fmt.Sprintf("Counting %s...", name))
+	progress := progressTitle(files, verb, name)
+
+	a := app
+	go func() {
+		e, err := expandSelection(clip.store, clip.bucket, clip.keys)
+		var jobs []awslib.TransferJob
+		for _, j := range e.transferJobs(dest) {
+			// pasting something back where it came from is a no-op
+			if clip.store != s || clip.bucket != bucket || j.From != j.To {
+				jobs = append(jobs, j)
+			}
+		}
+		var summary awslib.BatchSummary
+		if err == nil && len(jobs) > 0 {
+			summary, err = awslib.TransferKeys(clip.store, clip.bucket, s, bucket, jobs, clip.cut, progress)
+		}
+
+		a.QueueUpdateDraw(func() {
+			files.SetTitle(originalTitle)
+			if err == nil && len(summary.Failed) == 0 && clip.cut && clipboard == clip {
+				clipboard = nil
+				updateMarkCount()
+			}
+			if bucket == bucketName {
+				refreshFiles(s, files)
+			}
+			if err != nil {
+				reportError(err)
+				return
+			}
+			if len(jobs) == 0 {
+				showMessage(fmt.Sprintf("%s is already in s3://%s/%s", name, bucket, dest))
+				return
+			}
+			if len(summary.Failed) > 0 {
+				showBatchSummary(fmt.Sprintf("%s %s into s3://%s/%s", verb, name, bucket, dest), summary)
+			}
+		})
+	}()
+}
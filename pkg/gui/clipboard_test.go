@@ -0,0 +1,92 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func TestYankAndPasteIntoOtherBucket(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown)
+	typeText("y")
+	waitFor(t, screen, "Yanked top.txt from s3://alpha")
+
+	// back to the buckets and into beta
+	press(tcell.KeyEscape, tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "other.txt")
+	typeText("p")
+	eventually(t, "top.txt to be pasted", func() bool {
+		_, ok := store.Get("beta", "top.txt")
+		return ok
+	})
+
+	if data, ok := store.Get("beta", "top.txt"); !ok || string(data) != "hello from the top" {
+		t.Errorf("beta/top.txt = %q, %v", data, ok)
+	}
+	if _, ok := store.Get("alpha", "top.txt"); !ok {
+		t.Error("yanking and pasting removed the original")
+	}
+	// yanked things can be pasted again
+	waitFor(t, screen, "Yanked top.txt")
+}
+
+func TestCutAndPasteAcrossProfiles(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	content := "[default]\naws_access_key_id = a\naws_secret_access_key = b\n\n[work]\naws_access_key_id = c\naws_secret_access_key = d\n"
+	if err := os.WriteFile(credentials, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	work := awslib.NewMemoryStore()
+	work.Put("gamma", "archive/", nil)
+	connect := func(profile string) (awslib.ObjectStore, error) {
+		if profile != "work" {
+			return nil, fmt.Errorf("unexpected profile %s", profile)
+		}
+		return work, nil
+	}
+	store := newTestStore()
+	screen := startGuiWithConnector(t, store, connect)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/")
+	typeText("x")
+	waitFor(t, screen, "Cut docs/ from s3://alpha")
+
+	press(tcell.KeyCtrlS)
+	waitFor(t, screen, "Swap credentials")
+	typeText("work")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "gamma (us-east-1)")
+	waitFor(t, screen, "Cut docs/ from test s3://alpha")
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "archive/")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "..")
+	typeText("p")
+	waitFor(t, screen, "archive/docs/")
+	waitForGone(t, screen, "Cut docs/")
+
+	for _, key := range []string{"archive/docs/readme.md", "archive/docs/deep/nested.txt"} {
+		if _, ok := work.Get("gamma", key); !ok {
+			t.Errorf("%s is missing", key)
+		}
+	}
+	if _, ok := store.Get("alpha", "docs/readme.md"); ok {
+		t.Error("cutting and pasting left the original behind")
+	}
+}
@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// fileColumn is one of the columns drawn in front of each key in the files
+// pane, ls -l style, so the names line up without knowing the pane's width
+type fileColumn struct {
+	name     string // as given to -columns
+	title    string
+	shortcut rune
+	width    int
+	right    bool
+	value    func(e awslib.DirectoryEntry) string
+	color    func(e awslib.DirectoryEntry) string
+}
+
+var fileColumns = []fileColumn{
+	{name: "size", title: "Size", shortcut: 's', width: 10, right: true, value: func(e awslib.DirectoryEntry) string {
+		return utils.HumanBytes(e.Size)
+	}},
+	{name: "modified", title: "Last modified", shortcut: 'm', width: 10, value: func(e awslib.DirectoryEntry) string {
+		return utils.HumanTime(e.LastModified, time.Now())
+	}},
+	{name: "class", title: "Storage class", shortcut: 'c', width: 4, value: func(e awslib.DirectoryEntry) string {
+		return classBadges[e.StorageClass].text
+	}, color: func(e awslib.DirectoryEntry) string {
+		return classBadges[e.StorageClass].color
+	}},
+	{name: "etag", title: "ETag", shortcut: 'e', width: 8, value: func(e awslib.DirectoryEntry) string {
+		return e.ETag
+	}},
+}
+
+// classBadges are short names for storage classes, coloured by how long a
+// read takes. Anything missing is shown as the first few letters.
+var classBadges = map[string]struct{ text, color string }{
+	"STANDARD":            {"STD", "gray"},
+	"REDUCED_REDUNDANCY":  {"RRS", "gray"},
+	"EXPRESS_ONEZONE":     {"EXP", "green"},
+	"INTELLIGENT_TIERING": {"INT", "green"},
+	"STANDARD_IA":         {"IA", "teal"},
+	"ONEZONE_IA":          {"OZIA", "teal"},
+	"GLACIER_IR":          {"GIR", "teal"},
+	"GLACIER":             {"GLAC", "blue"},
+	"DEEP_ARCHIVE":        {"DEEP", "purple"},
+}
+
+// shownColumns are the columns switched on, from -columns or the 'c' picker
+var shownColumns = map[string]bool{"size": true, "modified": true, "class": true}
+
+// fileInfo is what the listing said about each key in the files pane
+var fileInfo = map[string]awslib.DirectoryEntry{}
+
+// rowKeys maps each label handed out by fileLabel back to its key, since with
+// columns in front the label can't be taken apart again reliably
+var rowKeys = map[string]string{}
+
+// SetColumns picks the files pane columns from a comma separated list, e.g.
+// "size,class". An empty list shows just the names.
+func SetColumns(list string) error {
+	shown := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if columnNamed(name) == nil {
+			var names []string
+			for _, c := range fileColumns {
+				names = append(names, c.name)
+			}
+			return fmt.Errorf("unknown column %q, pick from %s", name, strings.Join(names, ","))
+		}
+		shown[name] = true
+	}
+	shownColumns = shown
+	return nil
+}
+
+func columnNamed(name string) *fileColumn {
+	for i := range fileColumns {
+		if fileColumns[i].name == name {
+			return &fileColumns[i]
+		}
+	}
+	return nil
+}
+
+// columnText is the columns for key, blank for folders and ".."
+func columnText(key string) string {
+	var b strings.Builder
+	entry, ok := fileInfo[key]
+	for _, c := range fileColumns {
+		if !shownColumns[c.name] {
+			continue
+		}
+		var text string
+		if ok {
+			text = c.value(entry)
+			if c.name == "class" && text == "" {
+				text = entry.StorageClass
+			}
+		}
+		if len(text) > c.width {
+			text = text[:c.width]
+		}
+		if c.right {
+			text = fmt.Sprintf("%*s", c.width, text)
+		} else {
+			text = fmt.Sprintf("%-*s", c.width, text)
+		}
+		if c.color != nil && ok {
+			if color := c.color(entry); color != "" {
+				text = "[" + color + "]" + text + "[-]"
+			}
+		}
+		b.WriteString(text + " ")
+	}
+	return b.String()
+}
+
+// forgetRows drops the labels and listing details of whatever was shown
+func forgetRows() {
+	fileInfo = map[string]awslib.DirectoryEntry{}
+	rowKeys = map[string]string{}
+}
+
+// relabelFiles redraws every row in the files pane, e.g. after the columns change
+func relabelFiles(files *tview.List) {
+	keys := make([]string, files.GetItemCount())
+	for i := range keys {
+		text, _ := files.GetItemText(i)
+		keys[i] = keyFromLabel(text)
+	}
+	for i, label := range initialFiles {
+		initialFiles[i] = keyFromLabel(label)
+	}
+	rowKeys = map[string]string{}
+	for i, key := range keys {
+		files.SetItemText(i, fileLabel(key), "")
+	}
+	for i, key := range initialFiles {
+		initialFiles[i] = fileLabel(key)
+	}
+}
+
+// showColumnPicker switches files pane columns on and off. It stays up until
+// escape so several can be flipped at once.
+func showColumnPicker(files *tview.List) {
+	focus := app.GetFocus()
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle("Columns").SetTitleAlign(tview.AlignLeft)
+	list.SetDoneFunc(func() {
+		restoreDefaultGrid(focus)
+	})
+	itemText := func(c fileColumn) string {
+		if shownColumns[c.name] {
+			return "[x] " + c.title
+		}
+		return "[ ] " + c.title
+	}
+	for i, c := range fileColumns {
+		i, c := i, c
+		list.AddItem(tview.Escape(itemText(c)), "", c.shortcut, func() {
+			shownColumns[c.name] = !shownColumns[c.name]
+			list.SetItemText(i, tview.Escape(itemText(c)), "")
+			relabelFiles(files)
+		})
+	}
+	app.SetRoot(centered(list, 40, len(fileColumns)+2), true).SetFocus(list)
+}
@@ -0,0 +1,63 @@
+package gui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestFileColumns(t *testing.T) {
+	store := newTestStore()
+	store.SetModified("alpha", "top.txt", time.Now().Add(-72*time.Hour))
+	screen := startGui(t, store)
+	t.Cleanup(func() { SetColumns("size,modified,class") })
+
+	press(tcell.KeyEnter)
+	waitUntil(t, screen, "top.txt with its columns", func(text string) bool {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.Contains(line, "18 B 3d ago") && strings.Contains(line, "STD  top.txt") {
+				return true
+			}
+		}
+		return false
+	})
+	waitFor(t, screen, "GLAC frozen.bin")
+
+	// marking still finds the key behind the columns
+	press(tcell.KeyDown, tcell.KeyDown)
+	typeText(" ")
+	waitFor(t, screen, "✔ top.txt")
+	waitFor(t, screen, "1 marked")
+
+	typeText("c")
+	waitFor(t, screen, "[x] Size")
+	typeText("s")
+	waitFor(t, screen, "[ ] Size")
+	typeText("e")
+	press(tcell.KeyEscape)
+	waitForGone(t, screen, "18 B")
+	// md5 of "hello from the top"
+	waitFor(t, screen, "51e78201")
+	waitFor(t, screen, "✔ top.txt")
+
+	press(tcell.KeyCtrlD)
+	waitFor(t, screen, "Delete s3://alpha/top.txt?")
+}
+
+func TestSetColumns(t *testing.T) {
+	t.Cleanup(func() { SetColumns("size,modified,class") })
+	if err := SetColumns("size, etag"); err != nil {
+		t.Fatal(err)
+	}
+	if !shownColumns["size"] || !shownColumns["etag"] || shownColumns["class"] {
+		t.Errorf("shown = %v", shownColumns)
+	}
+	if err := SetColumns("size,owner"); err == nil {
+		t.Error("an unknown column was accepted")
+	}
+	if err := SetColumns(""); err != nil || len(shownColumns) != 0 {
+		t.Errorf("no columns = %v, %v", shownColumns, err)
+	}
+}
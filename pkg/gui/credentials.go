@@ -0,0 +1,187 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+const (
+	// credentials are refreshed this long before they expire, so MFA prompts
+	// happen here rather than in the middle of some S3 call
+	credentialRefreshWindow = 5 * time.Minute
+	// how long to wait for the ui to put the MFA form up before deciding it's
+	// stuck waiting on the very credentials we're prompting for
+	mfaFormTimeout = 2 * time.Second
+)
+
+const (
+	mfaPending int32 = iota
+	mfaShown
+	mfaAbandoned
+)
+
+var (
+	// running is set while S3Gui's app owns the terminal
+	running atomic.Bool
+	// bumped whenever credentials are swapped so old watchers know to stop
+	credGeneration atomic.Int64
+)
+
+// MFAPrompt asks for an MFA code in a form. It's called from whichever
+// goroutine triggered the credential refresh.
+func MFAPrompt(serial string) (string, error) {
+	if !running.Load() {
+		return standaloneMFAPrompt(serial)
+	}
+
+	type result struct {
+		code string
+		ok   bool
+	}
+	results := make(chan result, 1)
+	var state atomic.Int32
+
+	go app.QueueUpdateDraw(func() {
+		if !state.CompareAndSwap(mfaPending, mfaShown) {
+			return
+		}
+		focus := app.GetFocus()
+		form := mfaForm(serial, func(code string, ok bool) {
+			restoreDefaultGrid(focus)
+			results <- result{code, ok}
+		})
+		app.SetRoot(form, true).SetFocus(form)
+	})
+
+	select {
+	case r := <-results:
+		return mfaResult(r.code, r.ok)
+	case <-time.After(mfaFormTimeout):
+	}
+
+	if !state.CompareAndSwap(mfaPending, mfaAbandoned) {
+		// the form made it up in the end
+		r := <-results
+		return mfaResult(r.code, r.ok)
+	}
+	// the ui goroutine is blocked, so step out of the ui and ask on the terminal
+	var code string
+	var err error
+	app.Suspend(func() {
+		code, err = awslib.StdinMFAPrompt(serial)
+	})
+	return code, err
+}
+
+// standaloneMFAPrompt is used before the main app is running, e.g. when the
+// first ListBuckets call needs credentials
+func standaloneMFAPrompt(serial string) (string, error) {
+	a := tview.NewApplication()
+	var code string
+	var ok bool
+	form := mfaForm(serial, func(c string, o bool) {
+		code, ok = c, o
+		a.Stop()
+	})
+	if err := a.SetRoot(form, true).SetFocus(form).Run(); err != nil {
+		return "", err
+	}
+	return mfaResult(code, ok)
+}
+
+func mfaResult(code string, ok bool) (string, error) {
+	if !ok {
+		return "", fmt.Errorf("MFA prompt cancelled")
+	}
+	return code, nil
+}
+
+func mfaForm(serial string, done func(code string, ok bool)) *tview.Form {
+	form := tview.NewForm()
+	form.AddInputField("MFA code", "", 10, tview.InputFieldInteger, nil).
+		AddButton("OK", func() {
+			done(form.GetFormItem(0).(*tview.InputField).GetText(), true)
+		}).
+		AddButton("Cancel", func() {
+			done("", false)
+		})
+	form.GetFormItem(0).(*tview.InputField).SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			done(form.GetFormItem(0).(*tview.InputField).GetText(), true)
+		}
+	})
+	form.SetCancelFunc(func() {
+		done("", false)
+	})
+	form.SetBorder(true).SetTitle(fmt.Sprintf("MFA required for %s", serial)).SetTitleAlign(tview.AlignLeft)
+	return form
+}
+
+// watchCredentials shows who we are in the footer and refreshes expiring
+// credentials shortly before they run out
+func watchCredentials(s awslib.ObjectStore) {
+	a := app
+	gen := credGeneration.Add(1)
+	for {
+		id, err := s.Identity()
+		if err != nil && !id.CanExpire || credGeneration.Load() != gen {
+			// without STS there's nothing to add to the profile name, unless the
+			// credentials still said when they run out
+			return
+		}
+		a.QueueUpdateDraw(func() {
+			if credGeneration.Load() == gen {
+				setIdentity(id)
+			}
+		})
+		if !id.CanExpire {
+			return
+		}
+
+		wait := time.Until(id.Expires.Add(-credentialRefreshWindow))
+		if wait < time.Minute {
+			// short lived credentials, don't nag more than we have to
+			wait = time.Until(id.Expires)
+		}
+		if wait < time.Minute {
+			wait = time.Minute
+		}
+		time.Sleep(wait)
+
+		if credGeneration.Load() != gen {
+			return
+		}
+		if err := s.RefreshCredentials(); err != nil {
+			if credGeneration.Load() == gen {
+				reportError(err)
+			}
+			return
+		}
+	}
+}
+
+// setIdentity puts who the credentials belong to and when they expire after
+// the profile name, either of which may be missing
+func setIdentity(id awslib.Identity) {
+	var parts []string
+	if id.ARN != "" {
+		parts = append(parts, fmt.Sprintf("[gray]%s %s[white]", id.Account, id.Name()))
+	}
+	if id.CanExpire {
+		parts = append(parts, "expires "+id.Expires.Local().Format("15:04"))
+	}
+	identityText = ""
+	if len(parts) > 0 {
+		identityText = " (" + strings.Join(parts, ", ") + ")"
+	}
+	if footerView != nil {
+		footerView.SetText(footerText())
+	}
+}
@@ -0,0 +1,86 @@
+package gui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func TestFooterShowsIdentity(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	waitFor(t, screen, "Credentials: test (000000000000 user/memory)")
+}
+
+// noSTSStore has credentials that expire but no STS to say whose they are
+type noSTSStore struct {
+	*awslib.MemoryStore
+	expires time.Time
+}
+
+func (n noSTSStore) Identity() (awslib.Identity, error) {
+	return awslib.Identity{CanExpire: true, Expires: n.expires}, errors.New("no STS here")
+}
+
+func TestFooterShowsExpiryWithoutSTS(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	screen := startGui(t, noSTSStore{newTestStore(), expires})
+	waitFor(t, screen, "Credentials: test (expires "+expires.Format("15:04")+")")
+}
+
+func TestMFAPromptForm(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	running.Store(true)
+	defer running.Store(false)
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		code, err := MFAPrompt("arn:aws:iam::123456789012:mfa/me")
+		results <- result{code, err}
+	}()
+
+	waitFor(t, screen, "MFA required for arn:aws:iam::123456789012:mfa/me")
+	// the field only takes digits
+	typeText("12a3456")
+	press(tcell.KeyEnter)
+
+	select {
+	case r := <-results:
+		if r.err != nil || r.code != "123456" {
+			t.Errorf("MFAPrompt = %q, %v", r.code, r.err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("MFAPrompt never returned")
+	}
+	waitFor(t, screen, "Buckets <Ctrl+b>")
+}
+
+func TestMFAPromptCancel(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	running.Store(true)
+	defer running.Store(false)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := MFAPrompt("arn:aws:iam::123456789012:mfa/me")
+		errs <- err
+	}()
+
+	waitFor(t, screen, "MFA required")
+	press(tcell.KeyEscape)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected cancelling the prompt to fail")
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("MFAPrompt never returned")
+	}
+}
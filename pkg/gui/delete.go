@@ -0,0 +1,77 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// how many keys the confirmation lists, so you can see what's about to go
+const deletePreviewKeys = 5
+
+// confirmDelete asks before deleting keys. Folders are listed first so the
+// confirmation can say how much is about to disappear.
+func confirmDelete(s awslib.ObjectStore, files *tview.List, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	bucket := bucketName
+	name := describe(keys)
+	if len(keys) == 1 && !strings.HasSuffix(keys[0], "/") {
+		showDeleteConfirm(s, files, bucket, name, fmt.Sprintf("Delete s3://%s/%s?", bucket, tview.Escape(keys[0])), keys)
+		return
+	}
+
+	withExpandedSelection(s, files, keys, func(e expandedSelection) {
+		all := e.keys()
+		what := "everything under s3://" + bucket + "/" + tview.Escape(name)
+		if len(keys) > 1 {
+			what = fmt.Sprintf("%s from s3://%s", name, bucket)
+		}
+		text := fmt.Sprintf("Delete %s?\n\n%d objects, %s\n\n%s",
+			what, e.count, utils.HumanBytes(e.bytes), previewKeys(all))
+		showDeleteConfirm(s, files, bucket, name, text, all)
+	})
+}
+
+// parentPrefix is the folder key sits in, "" at the top of the bucket
+func parentPrefix(key string) string {
+	i := strings.LastIndex(strings.TrimSuffix(key, "/"), "/")
+	if i == -1 {
+		return ""
+	}
+	return key[:i+1]
+}
+
+// previewKeys is the first few keys, one per line, escaped for the modal
+func previewKeys(keys []string) string {
+	var shown []string
+	for _, key := range keys[:min(len(keys), deletePreviewKeys)] {
+		shown = append(shown, tview.Escape(key))
+	}
+	if len(keys) <= deletePreviewKeys {
+		return strings.Join(shown, "\n")
+	}
+	return strings.Join(shown, "\n") + fmt.Sprintf("\n... and %d more", len(keys)-deletePreviewKeys)
+}
+
+func showDeleteConfirm(s awslib.ObjectStore, files *tview.List, bucket string, name string, text string, keys []string) {
+	modal := tview.NewModal().
+		SetText(text).
+		// cancel first so a stray enter doesn't delete anything
+		AddButtons([]string{"Cancel", "Delete"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			restoreDefaultGrid(files)
+			if buttonLabel != "Delete" {
+				return
+			}
+			runBatchJob(s, files, "Deleting", name, func(progress awslib.ProgressFunc) (awslib.BatchSummary, error) {
+				return s.DeleteKeys(bucket, keys, progress)
+			})
+		})
+	app.SetRoot(modal, true)
+}
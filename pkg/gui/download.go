@@ -0,0 +1,94 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// max number of failed or skipped keys listed in a summary modal before we
+// give up
+const maxFailuresShown = 5
+
+// showDownloadInput asks where to put keys, folders come down with everything
+// in them
+func showDownloadInput(s awslib.ObjectStore, files *tview.List, keys []string) {
+	if len(keys) == 0 || bucketName == "" {
+		return
+	}
+	name := describe(keys)
+	cwd, _ := os.Getwd()
+	showPrompt(fmt.Sprintf("Download %s from s3://%s to: ", name, bucketName), cwd, func(dir string) {
+		if dir == "" {
+			return
+		}
+
+		localDir := expandHome(dir)
+		bucket := bucketName
+		originalTitle := files.GetTitle()
+		if len(keys) == 1 {
+			name = path.Base(keys[0])
+		}
+		progress := progressTitle(files, "Downloading", name)
+
+		a := app
+		var download func()
+		download = func() {
+			summary, err := s.DownloadKeys(bucket, keys, localDir, progress)
+			a.QueueUpdateDraw(func() {
+				files.SetTitle(originalTitle)
+				if err != nil {
+					reportErrorWithRetry(err, func() { go download() })
+					return
+				}
+				if len(marked) > 0 && bucket == bucketName {
+					clearMarks()
+					refreshFiles(s, files)
+				}
+				showDownloadSummary(files, describe(keys), localDir, summary)
+			})
+		}
+		go download()
+	})
+}
+
+func showDownloadSummary(files *tview.List, key string, localDir string, summary awslib.DownloadSummary) {
+	text := fmt.Sprintf("Downloaded %s to %s\n%s", key, localDir, summary)
+	if len(summary.Failed) > 0 {
+		var failed []string
+		for k, err := range summary.Failed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", tview.Escape(k), awslib.ErrorKindOf(err)))
+		}
+		sort.Strings(failed)
+		if len(failed) > maxFailuresShown {
+			failed = append(failed[:maxFailuresShown], "...")
+		}
+		text += "\n\nFailed:\n" + strings.Join(failed, "\n")
+	}
+	if len(summary.Skipped) > 0 {
+		var skipped []string
+		// sorted already
+		for _, k := range summary.Skipped {
+			if len(skipped) == maxFailuresShown {
+				skipped = append(skipped, "...")
+				break
+			}
+			skipped = append(skipped, tview.Escape(k))
+		}
+		text += "\n\nAlready downloaded and unchanged, skipped:\n" + strings.Join(skipped, "\n")
+	}
+
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			restoreDefaultGrid(files)
+		})
+	app.SetRoot(modal, true)
+}
@@ -0,0 +1,104 @@
+package gui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// errors waiting for the user, past this they're dropped rather than blocking
+// whoever hit them
+const errorQueueSize = 32
+
+// reportedError is an error on its way to the screen. retry, when set, runs the
+// failed operation again and is offered if the error looks temporary.
+type reportedError struct {
+	err   error
+	retry func()
+}
+
+// errorQueue is where every failure ends up, watchErrors shows them one at a time
+var errorQueue chan reportedError
+
+// reportError shows err to the user. It's safe to call from any goroutine and
+// never blocks, so it can be used from inside ui callbacks.
+func reportError(err error) {
+	reportErrorWithRetry(err, nil)
+}
+
+// reportErrorWithRetry is reportError with a way to have another go
+func reportErrorWithRetry(err error, retry func()) {
+	if err == nil || errorQueue == nil {
+		return
+	}
+	select {
+	case errorQueue <- reportedError{err: err, retry: retry}:
+	default:
+	}
+}
+
+// watchErrors pops up each reported error in turn, waiting for the last one to
+// be dismissed before showing the next
+func watchErrors(a *tview.Application, queue chan reportedError) {
+	for r := range queue {
+		dismissed := make(chan struct{})
+		r := r
+		a.QueueUpdateDraw(func() {
+			showError(r, func() { close(dismissed) })
+		})
+		<-dismissed
+	}
+}
+
+func showError(r reportedError, dismissed func()) {
+	focus := app.GetFocus()
+	buttons := []string{"OK"}
+	canRetry := r.retry != nil && awslib.ActionFor(r.err) == awslib.Retry
+	if canRetry {
+		buttons = []string{"Retry", "Dismiss"}
+	}
+
+	modal := tview.NewModal().
+		SetText(errorText(r.err)).
+		AddButtons(buttons).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			restoreDefaultGrid(focus)
+			dismissed()
+			if buttonLabel == "Retry" {
+				r.retry()
+			}
+		})
+	app.SetRoot(modal, true)
+}
+
+// errorText spells out what failed and on what, plus a hint for the usual suspects
+func errorText(err error) string {
+	var opErr *awslib.OpError
+	if !errors.As(err, &opErr) {
+		return "Something went wrong\n\n" + tview.Escape(err.Error())
+	}
+
+	lines := []string{"Couldn't " + opErr.Op}
+	if opErr.Bucket != "" {
+		lines = append(lines, "Bucket: "+tview.Escape(opErr.Bucket))
+	}
+	if opErr.Key != "" {
+		lines = append(lines, "Key: "+tview.Escape(opErr.Key))
+	}
+	lines = append(lines, "", tview.Escape(opErr.Message()))
+
+	switch opErr.Kind {
+	case awslib.KindAccessDenied:
+		lines = append(lines, "", "These credentials aren't allowed to do that")
+	case awslib.KindExpired:
+		lines = append(lines, "", "The credentials have expired or are invalid, swap them with Ctrl+S")
+	case awslib.KindThrottled:
+		lines = append(lines, "", "S3 is asking us to slow down, try again in a moment")
+	case awslib.KindNetwork:
+		lines = append(lines, "", "Check your connection and endpoint settings")
+	}
+	return strings.Join(lines, "\n")
+}
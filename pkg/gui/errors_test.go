@@ -0,0 +1,98 @@
+package gui
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// throttledStore fails the first few listings the way S3 does when it's busy
+type throttledStore struct {
+	*awslib.MemoryStore
+	mu       sync.Mutex
+	failures int
+}
+
+func (t *throttledStore) ListDirectory(bucket string, delimiter string, prefix string) awslib.DirectoryLister {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures > 0 {
+		t.failures--
+		return failingLister{&awslib.OpError{
+			Op:     "list",
+			Bucket: bucket,
+			Key:    prefix,
+			Kind:   awslib.KindThrottled,
+			Code:   "SlowDown",
+			Err:    &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."},
+		}}
+	}
+	return t.MemoryStore.ListDirectory(bucket, delimiter, prefix)
+}
+
+type failingLister struct{ err error }
+
+func (f failingLister) HasMore() bool { return true }
+
+func (f failingLister) NextPage() (awslib.DirectoryPage, error) {
+	return awslib.DirectoryPage{}, f.err
+}
+
+func TestErrorModalRetry(t *testing.T) {
+	screen := startGui(t, &throttledStore{MemoryStore: newTestStore(), failures: 1})
+	waitFor(t, screen, "alpha (us-east-1)")
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "Couldn't list")
+	waitFor(t, screen, "Bucket: alpha")
+	waitFor(t, screen, "SlowDown: Please reduce your request rate.")
+	waitFor(t, screen, "Retry")
+
+	press(tcell.KeyEnter)
+	waitForGone(t, screen, "Couldn't list")
+	waitFor(t, screen, "top.txt")
+}
+
+func TestErrorModalForMissingObject(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+
+	// someone else deletes it while we're looking
+	store.DeleteObject("alpha", "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+
+	waitFor(t, screen, "Couldn't check storage class")
+	waitFor(t, screen, "Key: top.txt")
+	waitFor(t, screen, "NoSuchKey")
+	// not worth retrying, so there's only an OK
+	if text := screenText(screen); strings.Contains(text, "Retry") {
+		t.Errorf("missing objects shouldn't offer a retry:\n%s", text)
+	}
+
+	press(tcell.KeyEnter)
+	waitForGone(t, screen, "Couldn't check storage class")
+	waitFor(t, screen, "Files <Ctrl+f>")
+}
+
+func TestErrorTextEscapesKeys(t *testing.T) {
+	err := &awslib.OpError{
+		Op:     "rename",
+		Bucket: "alpha",
+		Key:    "logs/[red]x.txt",
+		Err:    &smithy.GenericAPIError{Code: "NoSuchKey", Message: "no such key [red]x.txt"},
+	}
+	// a tag left as it is would be swallowed by the modal
+	text := errorText(err)
+	for _, want := range []string{"Key: logs/[red[]x.txt", "no such key [red[]x.txt"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("%q not in:\n%s", want, text)
+		}
+	}
+}
@@ -1,66 +1,236 @@
 package gui
 
 import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/sahilm/fuzzy"
 )
 
-func FuzzyFind(inputField *tview.InputField, focusedList *tview.List, listItems []string, b *tview.List, f *tview.List, p *tview.TextView) {
-	inputField.SetChangedFunc(func(text string) {
-		results := fuzzy.Find(text, listItems)
+// searchModes are the ways a search can match, Tab in the search input cycles
+// through them
+var searchModes = []string{"fuzzy", "substring", "glob", "regex"}
+
+// searchMode is the index into searchModes, kept between searches
+var searchMode = 0
+
+// searchable picks out the part of a list label a search looks at, and the byte
+// offset it starts at, so the rest of the label (columns, ticks) is left alone.
+// The label has the text escaped for tview.
+type searchable func(label string) (text string, at int)
+
+func searchFileLabel(label string) (string, int) {
+	key := keyFromLabel(label)
+	return key, strings.LastIndex(label, tview.Escape(key))
+}
+
+func searchBucketLabel(label string) (string, int) {
+	name := bucketFromLabel(label)
+	return name, strings.Index(label, name)
+}
+
+// searchMatch is an item that matched and the byte offsets of what matched
+type searchMatch struct {
+	index   int
+	matched []int
+}
+
+// findMatches runs pattern over texts in the given mode. Fuzzy matches come back
+// best first, the rest in list order.
+func findMatches(mode string, pattern string, texts []string) ([]searchMatch, error) {
+	var re *regexp.Regexp
+	var err error
+	switch mode {
+	case "fuzzy":
+		var matches []searchMatch
+		for _, m := range fuzzy.Find(pattern, texts) {
+			matches = append(matches, searchMatch{index: m.Index, matched: m.MatchedIndexes})
+		}
+		return matches, nil
+	case "substring":
+		re, err = smartcaseRegexp(pattern)
+	case "glob":
+		re, err = globRegexp(pattern)
+	case "regex":
+		re, err = regexp.Compile(pattern)
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []searchMatch
+	for i, text := range texts {
+		loc := re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			continue
+		}
+		// globs match the name in group 1, everything else the whole match
+		start, end := loc[0], loc[1]
+		if mode == "glob" {
+			start, end = loc[2], loc[3]
+		}
+		matches = append(matches, searchMatch{index: i, matched: runeStarts(text, start, end)})
+	}
+	return matches, nil
+}
+
+// smartcaseRegexp matches pattern as it's written. All lower case matches
+// either case, like vim's smartcase.
+func smartcaseRegexp(pattern string) (*regexp.Regexp, error) {
+	expr := regexp.QuoteMeta(pattern)
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// globRegexp turns a glob like *.parquet into a regexp that matches the last
+// part of a key with it, the way markGlob does
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, path.ErrBadPattern
+			}
+			end += i + 1
+			b.WriteString(glob[i : end+1])
+			i = end
+		case '\\':
+			if i++; i == len(glob) {
+				return nil, path.ErrBadPattern
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return regexp.Compile(`(?:^|/)(` + b.String() + `)/?$`)
+}
+
+// runeStarts is the offset of every rune in text[start:end]
+func runeStarts(text string, start int, end int) []int {
+	var starts []int
+	for i := start; i < end; {
+		starts = append(starts, i)
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return starts
+}
+
+// highlight draws s with the runes starting at matched picked out
+func highlight(s string, matched []int) string {
+	var b strings.Builder
+	start := 0
+	for n := 0; n < len(matched); {
+		i := matched[n]
+		if i < start || i >= len(s) {
+			n++
+			continue
+		}
+		// one tag for each run of matched runes
+		end := i
+		for n < len(matched) && matched[n] == end && end < len(s) {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+			n++
+		}
+		b.WriteString(tview.Escape(s[start:i]))
+		b.WriteString("[yellow::b]" + tview.Escape(s[i:end]) + "[-::-]")
+		start = end
+	}
+	b.WriteString(tview.Escape(s[start:]))
+	return b.String()
+}
+
+func searchLabel(err error) string {
+	if err != nil {
+		return fmt.Sprintf("Search ([red]%s, bad pattern[-]): ", searchModes[searchMode])
+	}
+	return fmt.Sprintf("Search (%s, Tab to change): ", searchModes[searchMode])
+}
+
+func FuzzyFind(inputField *tview.InputField, focusedList *tview.List, listItems []string, text searchable, b *tview.List, f *tview.List, p *tview.TextView) {
+	texts := make([]string, len(listItems))
+	for i, label := range listItems {
+		texts[i], _ = text(label)
+	}
+	// shown maps the rows in the list back to listItems
+	var shown []int
+	showAll := func() {
+		focusedList.Clear()
+		shown = shown[:0]
+		for i, val := range listItems {
+			focusedList.AddItem(val, "", 0, nil)
+			shown = append(shown, i)
+		}
+	}
+	filter := func() {
+		pattern := inputField.GetText()
+		if pattern == "" {
+			inputField.SetLabel(searchLabel(nil))
+			showAll()
+			return
+		}
+		matches, err := findMatches(searchModes[searchMode], pattern, texts)
+		inputField.SetLabel(searchLabel(err))
+		if err != nil {
+			// half typed patterns leave the last results up
+			return
+		}
 		focusedList.Clear()
-		for _, val := range results {
-			focusedList.AddItem(val.Str, "", 0, nil)
+		shown = shown[:0]
+		for _, m := range matches {
+			label := listItems[m.index]
+			t, at := text(label)
+			focusedList.AddItem(label[:at]+highlight(t, m.matched)+label[at+len(tview.Escape(t)):], "", 0, nil)
+			shown = append(shown, m.index)
 		}
+	}
+	for i := range listItems {
+		shown = append(shown, i)
+	}
+
+	inputField.SetLabel(searchLabel(nil))
+	inputField.SetChangedFunc(func(text string) {
+		filter()
 	})
 	inputField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyBackspace || event.Key() == tcell.KeyBackspace2 {
-			text := inputField.GetText()
-			if len(text) < 2 {
-				focusedList.Clear()
-				for _, val := range listItems {
-					focusedList.AddItem(val, "", 0, nil)
-				}
-			} else {
-				results := fuzzy.Find(text, listItems)
-				focusedList.Clear()
-				for _, val := range results {
-					focusedList.AddItem(val.Str, "", 0, nil)
-				}
-			}
+		if event.Key() == tcell.KeyTab {
+			searchMode = (searchMode + 1) % len(searchModes)
+			filter()
+			return nil
 		} else if event.Key() == tcell.KeyDown {
 			ScrollDown(focusedList)
 		} else if event.Key() == tcell.KeyUp {
 			ScrollUp(focusedList)
 		} else if event.Key() == tcell.KeyEnter {
-			if focusedList.GetItemCount() == 0 {
-				for _, val := range listItems {
-					focusedList.AddItem(val, "", 0, nil)
-				}
-				footer := createDefaultFooter(envName)
-				grid := CreateDefaultGrid(b, f, p, footer)
-				app.SetRoot(grid, true).SetFocus(focusedList)
-				return event
-			}
-			text, _ := focusedList.GetItemText(focusedList.GetCurrentItem())
-			focusedList.Clear()
-			for _, val := range listItems {
-				focusedList.AddItem(val, "", 0, nil)
-			}
 			targetIndex = -1
-			for i := 0; i < focusedList.GetItemCount(); i++ {
-				mainText, _ := focusedList.GetItemText(i)
-				if mainText == text {
-					targetIndex = i
-					focusedList.SetCurrentItem(targetIndex)
-					break
-				}
+			if focusedList.GetItemCount() > 0 {
+				targetIndex = shown[focusedList.GetCurrentItem()]
 			}
+			showAll()
 
 			footer := createDefaultFooter(envName)
 			grid := CreateDefaultGrid(b, f, p, footer)
-
 			app.SetRoot(grid, true).SetFocus(focusedList)
 
 			if targetIndex != -1 {
@@ -68,11 +238,7 @@ func FuzzyFind(inputField *tview.InputField, focusedList *tview.List, listItems
 				app.QueueEvent(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
 			}
 		} else if event.Key() == tcell.KeyEscape {
-
-			focusedList.Clear()
-			for _, val := range listItems {
-				focusedList.AddItem(val, "", 0, nil)
-			}
+			showAll()
 
 			footer := createDefaultFooter(envName)
 			grid := CreateDefaultGrid(b, f, p, footer)
@@ -85,6 +251,9 @@ func FuzzyFind(inputField *tview.InputField, focusedList *tview.List, listItems
 
 func ScrollDown(l *tview.List) {
 	count := l.GetItemCount()
+	if count == 0 {
+		return
+	}
 	index := l.GetCurrentItem()
 	index += 1
 	l.SetCurrentItem(index % count)
@@ -92,6 +261,9 @@ func ScrollDown(l *tview.List) {
 
 func ScrollUp(l *tview.List) {
 	count := l.GetItemCount()
+	if count == 0 {
+		return
+	}
 	index := l.GetCurrentItem()
 	index -= 1
 	l.SetCurrentItem(index % count)
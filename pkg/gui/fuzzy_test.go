@@ -0,0 +1,128 @@
+package gui
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// colourOf is the foreground colour the first rune of want is drawn in
+func colourOf(screen tcell.SimulationScreen, want string) tcell.Color {
+	cells, width, height := screen.GetContents()
+	locker := screen.(sync.Locker)
+	locker.Lock()
+	defer locker.Unlock()
+	runes := []rune(want)
+	for y := 0; y < height; y++ {
+		for x := 0; x+len(runes) <= width; x++ {
+			found := true
+			for i, r := range runes {
+				c := cells[y*width+x+i].Runes
+				if len(c) == 0 || c[0] != r {
+					found = false
+					break
+				}
+			}
+			if found {
+				fg, _, _ := cells[y*width+x].Style.Decompose()
+				return fg
+			}
+		}
+	}
+	return tcell.ColorDefault
+}
+
+func TestSearchModes(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+
+	typeText("/")
+	waitFor(t, screen, "Search (fuzzy, Tab to change):")
+	typeText("tpt")
+	waitForGone(t, screen, "frozen.bin")
+	eventually(t, "the match to be highlighted", func() bool {
+		return colourOf(screen, "top.txt") == tcell.ColorYellow
+	})
+
+	press(tcell.KeyTab)
+	waitFor(t, screen, "Search (substring, Tab to change):")
+	waitForGone(t, screen, "top.txt")
+
+	press(tcell.KeyTab)
+	waitFor(t, screen, "Search (glob, Tab to change):")
+	press(tcell.KeyBackspace2, tcell.KeyBackspace2, tcell.KeyBackspace2)
+	typeText("*.bin")
+	waitFor(t, screen, "frozen.bin")
+	waitForGone(t, screen, "top.txt")
+
+	// "*.bin" isn't a regexp, the last good results stay up
+	press(tcell.KeyTab)
+	waitFor(t, screen, "Search (regex, bad pattern):")
+	waitFor(t, screen, "frozen.bin")
+
+	for range "*.bin" {
+		press(tcell.KeyBackspace2)
+	}
+	typeText(`\.bin$`)
+	waitFor(t, screen, "Search (regex, Tab to change):")
+	waitFor(t, screen, "frozen.bin")
+	press(tcell.KeyEnter)
+	waitForGone(t, screen, "Search (")
+	waitFor(t, screen, "Cannot view a file stored in Glacier")
+}
+
+func TestFindMatches(t *testing.T) {
+	texts := []string{"docs/readme.md", "docs/deep/", "Top.txt", "data/part-0001.parquet", "héllo.txt"}
+	for _, tc := range []struct {
+		mode, pattern string
+		want          map[int][]int
+	}{
+		{"substring", "top", map[int][]int{2: {0, 1, 2}}},
+		{"substring", "Top", map[int][]int{2: {0, 1, 2}}},
+		{"substring", "TOP", map[int][]int{}},
+		{"glob", "*.parquet", map[int][]int{3: {5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}}},
+		{"glob", "deep", map[int][]int{1: {5, 6, 7, 8}}},
+		{"glob", "*.md", map[int][]int{0: {5, 6, 7, 8, 9, 10, 11, 12, 13}}},
+		{"regex", `l+o\.`, map[int][]int{4: {3, 4, 5, 6}}},
+		{"regex", `é`, map[int][]int{4: {1}}},
+	} {
+		matches, err := findMatches(tc.mode, tc.pattern, texts)
+		if err != nil {
+			t.Errorf("%s %q: %v", tc.mode, tc.pattern, err)
+			continue
+		}
+		got := map[int][]int{}
+		for _, m := range matches {
+			got[m.index] = m.matched
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %q = %v, want %v", tc.mode, tc.pattern, got, tc.want)
+		}
+	}
+
+	for _, bad := range []struct{ mode, pattern string }{{"regex", "("}, {"glob", "[a"}, {"glob", `x\`}} {
+		if _, err := findMatches(bad.mode, bad.pattern, texts); err == nil {
+			t.Errorf("%s %q was accepted", bad.mode, bad.pattern)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	for _, tc := range []struct {
+		s       string
+		matched []int
+		want    string
+	}{
+		{"abc", []int{0, 2}, "[yellow::b]a[-::-]b[yellow::b]c[-::-]"},
+		{"abcd", []int{1, 2}, "a[yellow::b]bc[-::-]d"},
+		{"é/x", []int{0, 2}, "[yellow::b]é/[-::-]x"},
+		{"[red]", []int{1}, "[[yellow::b]r[-::-]ed]"},
+	} {
+		if got := highlight(tc.s, tc.matched); got != tc.want {
+			t.Errorf("highlight(%q, %v) = %q, want %q", tc.s, tc.matched, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// keyHelp is a key and what it does, shown by showHelp
+type keyHelp struct {
+	key  string
+	does string
+}
+
+type helpSection struct {
+	name string
+	keys []keyHelp
+}
+
+// helpColumns is every key, by where it works, in the two columns showHelp
+// lays them out in. The footer only has room for a few of them.
+var helpColumns = [][]helpSection{{
+	{"Anywhere", []keyHelp{
+		{"Ctrl+b/f/p", "buckets, files, preview"},
+		{"?", "this help"},
+		{"Ctrl+t", "create a bucket"},
+		{"Ctrl+u", "upload"},
+		{"Ctrl+e", "add credentials"},
+		{"Ctrl+s", "swap credentials"},
+		{"Ctrl+q", "quit"},
+		{"Esc", "back out of a prompt"},
+	}},
+	{"Marks", []keyHelp{
+		{"Space", "mark or unmark"},
+		{"Ctrl+a", "mark all"},
+		{"*", "invert marks"},
+		{"+", "mark matching a pattern"},
+	}},
+}, {
+	{"Buckets and files", []keyHelp{
+		{"/", "search what's listed"},
+		{"F", "find in the whole bucket"},
+		{"Ctrl+w", "download"},
+		{"Ctrl+d", "delete"},
+		{"Ctrl+r", "rename"},
+		{"Ctrl+o", "operations on the selection"},
+		{"y x p", "copy, cut, paste"},
+		{"c s f", "columns, sort, filter"},
+	}},
+	{"Preview", []keyHelp{
+		{"/ n", "find, next match"},
+		{"g G", "start, end"},
+		{"w", "wrap lines"},
+		{"x", "hex"},
+		{"r", "raw, not as a table or formatted"},
+	}},
+}}
+
+// helpText lays out one column of sections, and how many lines that took
+func helpText(sections []helpSection) (string, int) {
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[yellow]%s[white]\n", section.name)
+		for _, k := range section.keys {
+			fmt.Fprintf(&b, " [green]%-11s[white]%s\n", k.key, k.does)
+		}
+	}
+	return b.String(), strings.Count(b.String(), "\n")
+}
+
+// showHelp lists every key, esc, q or ? again puts the panes back
+func showHelp() {
+	focus := app.GetFocus()
+	keys := tview.NewFlex()
+	height := 0
+	for _, column := range helpColumns {
+		text, lines := helpText(column)
+		keys.AddItem(tview.NewTextView().SetDynamicColors(true).SetText(text), 0, 1, false)
+		height = max(height, lines)
+	}
+	keys.SetBorder(true).SetTitle("Keys - esc to close").SetTitleAlign(tview.AlignLeft)
+	keys.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' || event.Rune() == '?' {
+			restoreDefaultGrid(focus)
+			return nil
+		}
+		return event
+	})
+	app.SetRoot(centered(keys, 94, height+2), true).SetFocus(keys)
+}
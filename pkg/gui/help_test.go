@@ -0,0 +1,42 @@
+package gui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestFooterFitsAndHelpHasTheRest(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	// the whole footer fits on one line of a 180 column screen
+	var footer string
+	for _, line := range strings.Split(screenText(screen), "\n") {
+		if strings.HasPrefix(line, "Credentials:") {
+			footer = line
+		}
+	}
+	if !strings.Contains(footer, "(?) all keys") || !strings.Contains(footer, "(Ctrl+o) operations") {
+		t.Errorf("footer = %q", footer)
+	}
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	typeText("?")
+	waitFor(t, screen, "Keys - esc to close")
+	waitFor(t, screen, "swap credentials")
+	waitFor(t, screen, "raw, not as a table or formatted")
+	press(tcell.KeyEscape)
+	waitForGone(t, screen, "Keys - esc to close")
+	waitFor(t, screen, "top.txt")
+
+	// in an input it's just a ?
+	typeText("/")
+	waitFor(t, screen, "Tab to change):")
+	typeText("?")
+	waitFor(t, screen, "Tab to change): ?")
+	if strings.Contains(screenText(screen), "Keys - esc to close") {
+		t.Error("? in the search input showed the help")
+	}
+}
@@ -0,0 +1,226 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// prefetchMargin is how close to the bottom of the files pane the cursor gets
+// before the next page is listed
+var prefetchMargin = 50
+
+// filesListing is the folder being paged into the files pane
+type filesListing struct {
+	lister   awslib.DirectoryLister
+	bucket   string
+	prefix   string
+	folders  []string
+	keys     []string // details are in fileInfo
+	started  bool
+	fetching bool
+	waiting  []func(error) // told when the page being fetched is in
+}
+
+// rowsAbove is how many rows come before the keys: ".." and the folders
+func (l *filesListing) rowsAbove() int {
+	if l.prefix != "" {
+		return len(l.folders) + 1
+	}
+	return len(l.folders)
+}
+
+// listing is nil once the files pane is cleared, so pages still in flight
+// from an old listing get dropped
+var listing *filesListing
+
+// loadFiles lists prefix in the current bucket into the files pane a page at a
+// time. done (if not nil) gets the first page's error back on the ui goroutine,
+// otherwise errors are reported as they are.
+func loadFiles(s awslib.ObjectStore, files *tview.List, prefix string, done func(error)) {
+	// relisting the same folder keeps what's shown until the new page is in
+	if listing == nil || listing.bucket != bucketName || listing.prefix != prefix {
+		files.Clear()
+		initialFiles = nil
+	}
+	l := &filesListing{
+		lister: s.ListDirectory(bucketName, "/", prefix),
+		bucket: bucketName,
+		prefix: prefix,
+	}
+	listing = l
+	if done == nil {
+		done = func(err error) {
+			if err != nil {
+				reportError(err)
+			}
+		}
+	}
+	fetchPage(files, l, done)
+}
+
+// stopListing forgets the listing so nothing more gets added to the pane
+func stopListing() {
+	listing = nil
+}
+
+// fetchMore lists the next page when the cursor is near the bottom of the pane
+func fetchMore(files *tview.List) {
+	l := listing
+	if l == nil || !l.started || l.fetching || !l.lister.HasMore() {
+		return
+	}
+	if files.GetItemCount()-files.GetCurrentItem() > prefetchMargin {
+		return
+	}
+	var retry func(err error)
+	retry = func(err error) {
+		if err != nil {
+			reportErrorWithRetry(err, func() { fetchPage(files, l, retry) })
+		}
+	}
+	fetchPage(files, l, retry)
+}
+
+// fetchPage lists the next page of l off the ui goroutine and adds it. If a
+// page is already on its way done hears about that one instead.
+func fetchPage(files *tview.List, l *filesListing, done func(error)) {
+	l.waiting = append(l.waiting, done)
+	if l.fetching {
+		return
+	}
+	l.fetching = true
+	a := app
+	go func() {
+		page, err := l.lister.NextPage()
+		a.QueueUpdateDraw(func() {
+			l.fetching = false
+			waiting := l.waiting
+			l.waiting = nil
+			if listing != l {
+				return
+			}
+			if err == nil {
+				addPage(files, l, page)
+			}
+			for _, done := range waiting {
+				done(err)
+			}
+			if err == nil {
+				fetchMore(files)
+			}
+		})
+	}()
+}
+
+// selectListed moves the cursor to key, listing more pages until it turns up
+func selectListed(files *tview.List, key string) {
+	for i := 0; i < files.GetItemCount(); i++ {
+		text, _ := files.GetItemText(i)
+		if keyFromLabel(text) == key {
+			files.SetCurrentItem(i)
+			return
+		}
+	}
+	l := listing
+	if l == nil {
+		return
+	}
+	// the lister isn't to be touched while a page is on its way
+	if l.fetching || l.lister.HasMore() {
+		fetchPage(files, l, func(err error) {
+			if err != nil {
+				reportError(err)
+				return
+			}
+			selectListed(files, key)
+		})
+		return
+	}
+	if listView.filter.text != "" {
+		showMessage(fmt.Sprintf("%s is hidden by the filter %s", key, listView.filter.text))
+		return
+	}
+	showMessage(fmt.Sprintf("%s isn't in s3://%s any more", key, l.bucket))
+}
+
+// addPage puts a page into the files pane. Listed in plain name order the
+// folders go after the folders already there and keys at the end, otherwise
+// the whole pane is sorted and filtered again. The first page replaces
+// whatever was shown.
+func addPage(files *tview.List, l *filesListing, page awslib.DirectoryPage) {
+	if !l.started {
+		l.started = true
+		files.Clear()
+		initialFiles = nil
+		forgetRows()
+		if l.prefix != "" {
+			addFileRow(files, 0, "..")
+		}
+	}
+	plain := listView.plain()
+	for _, folder := range page.Folders {
+		if plain {
+			addFileRow(files, l.rowsAbove(), folder)
+		}
+		l.folders = append(l.folders, folder)
+	}
+	for _, entry := range page.Keys {
+		fileInfo[entry.Key] = entry
+		l.keys = append(l.keys, entry.Key)
+		if plain {
+			addFileRow(files, len(initialFiles), entry.Key)
+		}
+	}
+	if !plain {
+		showListing(files, l)
+	}
+}
+
+// showListing fills the files pane from everything listed so far, sorted and
+// filtered, keeping the cursor on the same key
+func showListing(files *tview.List, l *filesListing) {
+	filtered := files.GetItemCount() != len(initialFiles)
+	var current string
+	if files.GetItemCount() > 0 {
+		text, _ := files.GetItemText(files.GetCurrentItem())
+		current = keyFromLabel(text)
+	}
+
+	var rows []string
+	if l.prefix != "" {
+		rows = append(rows, "..")
+	}
+	rows = append(rows, listView.sortFolders(l.folders)...)
+	rows = append(rows, listView.sortKeys(l.keys)...)
+
+	initialFiles = make([]string, len(rows))
+	for i, key := range rows {
+		initialFiles[i] = fileLabel(key)
+	}
+	if filtered {
+		return
+	}
+	files.Clear()
+	for i, label := range initialFiles {
+		files.AddItem(label, "", 0, nil)
+		if rows[i] == current {
+			files.SetCurrentItem(i)
+		}
+	}
+}
+
+// addFileRow inserts key at index, leaving the pane alone while a search has
+// it filtered down
+func addFileRow(files *tview.List, index int, key string) {
+	filtered := files.GetItemCount() != len(initialFiles)
+	label := fileLabel(key)
+	initialFiles = append(initialFiles, "")
+	copy(initialFiles[index+1:], initialFiles[index:])
+	initialFiles[index] = label
+	if !filtered {
+		files.InsertItem(index, label, "", 0, nil)
+	}
+}
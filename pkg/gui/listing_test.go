@@ -0,0 +1,69 @@
+package gui
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// countingStore counts listing pages handed out
+type countingStore struct {
+	*awslib.MemoryStore
+	pages atomic.Int32
+}
+
+func (c *countingStore) ListDirectory(bucket string, delimiter string, prefix string) awslib.DirectoryLister {
+	return countingLister{c.MemoryStore.ListDirectory(bucket, delimiter, prefix), &c.pages}
+}
+
+type countingLister struct {
+	awslib.DirectoryLister
+	pages *atomic.Int32
+}
+
+func (c countingLister) NextPage() (awslib.DirectoryPage, error) {
+	c.pages.Add(1)
+	return c.DirectoryLister.NextPage()
+}
+
+func TestFilesListedPageByPage(t *testing.T) {
+	old := prefetchMargin
+	prefetchMargin = 15
+	t.Cleanup(func() { prefetchMargin = old })
+
+	mem := awslib.NewMemoryStore()
+	mem.SetPageSize(10)
+	for i := 0; i < 100; i++ {
+		mem.Put("huge", fmt.Sprintf("key-%03d", i), nil)
+	}
+	mem.Put("huge", "zz/last.txt", nil)
+	store := &countingStore{MemoryStore: mem}
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "key-000")
+	// enough to fill past the margin, then it waits for scrolling
+	eventually(t, "two pages", func() bool { return store.pages.Load() == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if n := store.pages.Load(); n != 2 {
+		t.Fatalf("listed %d pages without scrolling", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		press(tcell.KeyDown)
+	}
+	eventually(t, "a third page", func() bool { return store.pages.Load() >= 3 })
+
+	// the folder from the last page goes at the top with the other folders
+	eventually(t, "every page", func() bool {
+		press(tcell.KeyEnd)
+		return store.pages.Load() == 11
+	})
+	press(tcell.KeyHome)
+	waitFor(t, screen, "zz/")
+}
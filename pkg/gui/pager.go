@@ -0,0 +1,642 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/parquet"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// pageBytes is how much of an object the preview reads at a time
+var pageBytes int64 = 64 << 10
+
+// windowPages is how many pages the preview keeps. Paging on past them lets go
+// of the far end, so a big object isn't all held and redrawn.
+var windowPages = 16
+
+// searchBytes is how far a search in the preview reads past what's already
+// loaded before giving up
+var searchBytes int64 = 4 << 20
+
+// previewWrap wraps long lines in the preview, w toggles it. Off by default so
+// the position in the title, which counts lines, is exact.
+var previewWrap = false
+
+const previewTitle = "Preview <Ctrl+p>"
+
+// filePager is the part of an object showing in the preview. It's one run of
+// bytes, at most windowPages long, that moves a page at a time as the preview
+// is scrolled, or jumps to the end with a suffix range.
+type filePager struct {
+	store    awslib.ObjectStore
+	bucket   string
+	key      string
+	size     int64
+	start    int64 // where data starts in the object
+	data     []byte
+	fetching bool
+	// ctx is cancelled once something else is opened or the app stops, and
+	// stopRead drops the read on its way, for End and Home to go ahead of it
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopRead context.CancelFunc
+
+	// data as text, without partial runes at the edges, and where in data it
+	// starts
+	text     string
+	textFrom int
+	// where each line shown ends, as an offset in data, and where the first
+	// one starts. In the table the lines are rows, after the header.
+	lineEnds  []int
+	linesFrom int
+
+	// showing a hex dump, which binary objects get unless x says otherwise
+	hex    bool
+	chosen bool // x was pressed, so leave hex alone
+	perRow int  // bytes in each row of the dump
+	// the file type going by its magic number, "" if it's not one we know
+	kind string
+	// how to show text more readably, nil for as it is. raw is r asking for it
+	// as it is anyway.
+	format *previewFormat
+	raw    bool
+	// for the table, what fields are split on and the first line, which is kept
+	// for when the start is no longer loaded
+	delim      rune
+	header     []string
+	headerSize int
+	// a parquet file's metadata, shown in place of its bytes, and its first
+	// rows once t asks for them
+	meta     *parquet.File
+	rows     [][]string
+	rowsErr  error
+	showRows bool
+
+	// the last search, highlighted wherever it shows
+	pattern *regexp.Regexp
+	query   string
+	match   int // offset in text of the current match, -1 for none
+	// said after the position in the title, e.g. that a search found nothing
+	note string
+}
+
+var pager *filePager
+
+// openPreview shows the start of key in the preview. More is read as the
+// preview is scrolled.
+func openPreview(s awslib.ObjectStore, preview *tview.TextView, bucket string, key string) {
+	clearPreview()
+	p := &filePager{store: s, bucket: bucket, key: key, match: -1}
+	p.ctx, p.cancel = context.WithCancel(appCtx)
+	pager = p
+	preview.SetTitle(previewTitle + " - loading")
+	p.read(0, pageBytes, func(r awslib.ObjectRange) {
+		p.kind = utils.DetectFileType(r.Data)
+		p.format = formatFor(key, r.ContentType, r.Data)
+		p.replace(preview, r)
+		p.scrollToEdge(preview, false)
+		p.showTitle(preview)
+		if p.kind == "parquet" {
+			p.openParquet(preview)
+		}
+	})
+}
+
+// clearPreview empties the preview and forgets what was in it
+func clearPreview() {
+	if pager != nil {
+		pager.cancel()
+	}
+	pager = nil
+	previewPane.Clear()
+	previewPane.SetWrap(true).SetTitle(previewTitle)
+	previewTable.Clear().Select(1, 0).SetTitle(previewTitle)
+	showPreviewPage("text")
+}
+
+func (p *filePager) end() int64 {
+	return p.start + int64(len(p.data))
+}
+
+// read fetches a range off the ui goroutine, in place of any read already on
+// its way. then is dropped if something else has been opened in the preview
+// since.
+func (p *filePager) read(offset int64, length int64, then func(r awslib.ObjectRange)) {
+	if p.stopRead != nil {
+		p.stopRead()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.fetching, p.stopRead = true, cancel
+	a := app
+	go func() {
+		r, err := p.store.ReadRange(ctx, p.bucket, p.key, offset, length)
+		if ctx.Err() != nil {
+			return
+		}
+		a.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			cancel()
+			p.fetching, p.stopRead = false, nil
+			if err != nil {
+				if len(p.data) == 0 {
+					clearPreview()
+				}
+				reportError(err)
+				return
+			}
+			p.size = r.Size
+			then(r)
+		})
+	}()
+}
+
+// replace swaps what's loaded for r
+func (p *filePager) replace(preview *tview.TextView, r awslib.ObjectRange) {
+	p.start, p.data = r.Offset, r.Data
+	p.match = -1
+	p.show(preview)
+}
+
+// add puts r on the end of what's loaded, if it carries on from there. Past
+// windowPages the start is let go of, keeping the same lines on screen, and
+// dropped is how many bytes that was.
+func (p *filePager) add(preview *tview.TextView, r awslib.ObjectRange) (dropped int) {
+	if r.Offset != p.end() || len(r.Data) == 0 {
+		return 0
+	}
+	p.data = append(p.data, r.Data...)
+	if extra := len(p.data) - windowPages*int(pageBytes); extra > 0 {
+		row, _ := p.position(preview)
+		top := p.lineStart(row)
+		p.start += int64(extra)
+		// copied so what's let go of can be freed
+		p.data = append([]byte(nil), p.data[extra:]...)
+		p.match = -1
+		p.show(preview)
+		p.scrollTo(preview, p.lineOf(top-extra))
+		return extra
+	}
+	p.show(preview)
+	return 0
+}
+
+// show redraws the preview from data, leaving it scrolled where it was
+func (p *filePager) show(preview *tview.TextView) {
+	from, to := utils.RuneBounds(p.data, p.start > 0, p.end() < p.size)
+	p.text, p.textFrom = string(p.data[from:to]), from
+	if !p.chosen {
+		// a parquet file shows its metadata instead
+		p.hex = p.meta == nil && utils.IsBinary(p.data[from:to])
+	}
+	p.lineEnds, p.linesFrom = p.lineEnds[:0], 0
+	var text string
+	switch {
+	case p.summarising():
+		p.showParquet(preview)
+		return
+	case p.tabled():
+		p.showTable()
+		return
+	case p.hex:
+		p.perRow = 8
+		if _, _, width, _ := preview.GetInnerRect(); width >= utils.HexRowWidth(16) {
+			p.perRow = 16
+		}
+		p.lineEnds = utils.HexRowEnds(len(p.data), p.start, p.perRow)
+		rows := utils.HexDump(p.data, p.start, p.perRow)
+		for i, row := range rows {
+			rows[i] = tview.Escape(row)
+		}
+		text = p.markMatchRow(rows)
+		preview.SetWrap(false)
+	case p.format != nil && !p.raw:
+		rows, ends := p.format.render(p.text)
+		for _, end := range ends {
+			p.lineEnds = append(p.lineEnds, from+end)
+		}
+		text = p.markMatchRow(rows)
+		preview.SetWrap(previewWrap)
+	default:
+		for i := 0; i < len(p.text); {
+			n := strings.IndexByte(p.text[i:], '\n')
+			if n < 0 {
+				i = len(p.text)
+			} else {
+				i += n + 1
+			}
+			p.lineEnds = append(p.lineEnds, from+i)
+		}
+		text = p.render()
+		preview.SetWrap(previewWrap)
+	}
+	preview.SetDynamicColors(true).SetRegions(true)
+	preview.SetText(text)
+	showPreviewPage("text")
+}
+
+// plain is whether the text is being shown just as it is
+func (p *filePager) plain() bool {
+	return !p.hex && (p.format == nil || p.raw)
+}
+
+// markMatchRow joins up rows, picking out the one the current match starts in,
+// for layouts where the matched text itself can't be found again
+func (p *filePager) markMatchRow(rows []string) string {
+	if p.match >= 0 {
+		if i := p.lineOf(p.textFrom + p.match); i < len(rows) {
+			rows[i] = `["match"][black:yellow]` + rows[i] + `[-:-][""]`
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+// render is the plain text with the last search picked out
+func (p *filePager) render() string {
+	if p.pattern == nil {
+		return tview.Escape(p.text)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range p.pattern.FindAllStringIndex(p.text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(tview.Escape(p.text[last:loc[0]]))
+		found := "[black:yellow]" + tview.Escape(p.text[loc[0]:loc[1]]) + "[-:-]"
+		if loc[0] == p.match {
+			// the current one is a region so tview can scroll to it
+			found = `["match"]` + found + `[""]`
+		}
+		b.WriteString(found)
+		last = loc[1]
+	}
+	b.WriteString(tview.Escape(p.text[last:]))
+	return b.String()
+}
+
+// lineStart is the offset in data of line
+func (p *filePager) lineStart(line int) int {
+	if line <= 0 || len(p.lineEnds) == 0 {
+		return p.linesFrom
+	}
+	if line > len(p.lineEnds) {
+		line = len(p.lineEnds)
+	}
+	return p.lineEnds[line-1]
+}
+
+// lineOf is the line showing the byte at offset in data
+func (p *filePager) lineOf(offset int) int {
+	return sort.Search(len(p.lineEnds), func(i int) bool { return p.lineEnds[i] > offset })
+}
+
+// textAt turns an offset in data into one in text
+func (p *filePager) textAt(offset int) int {
+	offset -= p.textFrom
+	if offset < 0 {
+		return 0
+	}
+	if offset > len(p.text) {
+		return len(p.text)
+	}
+	return offset
+}
+
+// showTitle puts how far through the object the bottom of the preview is in
+// its title. With wrapping on it's only roughly right.
+func (p *filePager) showTitle(preview *tview.TextView) {
+	row, height := p.position(preview)
+	seen := p.start + int64(p.lineStart(row+height))
+	title := previewTitle
+	var labels []string
+	if p.kind != "" {
+		labels = append(labels, p.kind)
+	}
+	switch {
+	case p.hex:
+		labels = append(labels, "hex")
+	case p.tabled():
+		labels = append(labels, p.delimName())
+	case p.format != nil && p.raw:
+		labels = append(labels, p.format.name+" raw")
+	case p.format != nil:
+		labels = append(labels, p.format.name)
+	}
+	if len(labels) > 0 {
+		title += " - " + strings.Join(labels, " ")
+	}
+	switch {
+	case p.summarising():
+		title += " - " + p.parquetTitle()
+	case p.tabled():
+		title += " - " + p.rowPosition()
+	default:
+		title += fmt.Sprintf(" - %d%% of %s", utils.Percent(seen, p.size), utils.HumanBytes(p.size))
+		if p.start > 0 {
+			title += fmt.Sprintf(" from %s", utils.HumanBytes(p.start))
+		}
+	}
+	if p.note != "" {
+		title += " - " + p.note
+	}
+	preview.SetTitle(title)
+	previewTable.SetTitle(title)
+}
+
+// scrolled runs after the preview has been drawn at its new position, reading
+// the next page once the bottom is in sight
+func (p *filePager) scrolled(preview *tview.TextView) {
+	if pager != p {
+		return
+	}
+	p.showTitle(preview)
+	if p.summarising() {
+		return
+	}
+	row, height := p.position(preview)
+	if last := len(p.lineEnds) - height; row > last && !p.tabled() && (p.hex || !previewWrap) {
+		// tview lets paging down run on past the last line, and unwrapped each
+		// line is a row so it can be kept to what's loaded
+		row = max(last, 0)
+		p.scrollTo(preview, row)
+	}
+	if row+2*height < len(p.lineEnds) || p.fetching || p.end() >= p.size {
+		return
+	}
+	p.read(p.end(), pageBytes, func(r awslib.ObjectRange) {
+		p.add(preview, r)
+		// and on again if paging down has kept going past it
+		p.afterDraw(preview)
+	})
+}
+
+// handleKey is the preview's input capture while something is open in it
+func (p *filePager) handleKey(preview *tview.TextView, event *tcell.EventKey) *tcell.EventKey {
+	if p.summarising() {
+		return p.handleParquetKey(preview, event)
+	}
+	row, _ := p.position(preview)
+	key, ch := event.Key(), event.Rune()
+	if key != tcell.KeyRune {
+		ch = 0
+	}
+	switch {
+	case key == tcell.KeyEnd || ch == 'G':
+		if p.end() < p.size && p.canJump() {
+			// straight to the end with a suffix range, skipping everything between
+			p.note = ""
+			p.read(-1, pageBytes, func(r awslib.ObjectRange) {
+				p.replace(preview, r)
+				p.scrollToEdge(preview, true)
+				p.afterDraw(preview)
+			})
+			return nil
+		}
+	case key == tcell.KeyHome || ch == 'g':
+		if p.start > 0 && p.canJump() {
+			p.note = ""
+			p.read(0, pageBytes, func(r awslib.ObjectRange) {
+				p.replace(preview, r)
+				p.scrollToEdge(preview, false)
+				p.afterDraw(preview)
+			})
+			return nil
+		}
+	case key == tcell.KeyUp || key == tcell.KeyPgUp || ch == 'k':
+		if row == 0 && p.start > 0 && !p.fetching {
+			p.readEarlier(preview)
+		}
+	case ch == 'n':
+		from := p.match + 1
+		if p.match < 0 {
+			from = p.textAt(p.lineStart(row))
+		}
+		p.find(preview, from, p.start+int64(p.lineStart(row)), searchBytes)
+		return nil
+	case ch == 'w':
+		previewWrap = !previewWrap
+		if !p.hex && !p.tabled() {
+			preview.SetWrap(previewWrap)
+		}
+		return nil
+	case ch == 'x':
+		p.hex, p.chosen = !p.hex, true
+		p.reshow(preview, row)
+		return nil
+	case ch == 'r' && p.format != nil:
+		p.raw = !p.raw
+		p.reshow(preview, row)
+		return nil
+	}
+	p.afterDraw(preview)
+	return event
+}
+
+// canJump is whether End or Home can go ahead, which they can over a page
+// being read but not while the object is being opened
+func (p *filePager) canJump() bool {
+	return !p.fetching || p.stopRead != nil && len(p.data) > 0
+}
+
+// reshow redraws the preview after it's been switched to another layout,
+// keeping the part of the object that was at the top at the top
+func (p *filePager) reshow(preview *tview.TextView, row int) {
+	top := p.lineStart(row)
+	p.show(preview)
+	p.scrollTo(preview, p.lineOf(top))
+	p.afterDraw(preview)
+}
+
+// position is the line at the top of the preview and how many fit, or in the
+// table the row that's selected and how many rows fit
+func (p *filePager) position(preview *tview.TextView) (int, int) {
+	if p.tabled() {
+		row, _ := previewTable.GetSelection()
+		_, _, _, height := previewTable.GetInnerRect()
+		return row - 1, height - 1
+	}
+	row, _ := preview.GetScrollOffset()
+	_, _, _, height := preview.GetInnerRect()
+	return row, height
+}
+
+// scrollTo puts line at the top of the preview, or selects it in the table
+func (p *filePager) scrollTo(preview *tview.TextView, line int) {
+	if p.tabled() {
+		previewTable.Select(line+1, 0)
+		return
+	}
+	_, column := preview.GetScrollOffset()
+	preview.ScrollTo(line, column)
+}
+
+// scrollToEdge goes to the end of what's loaded, or the beginning
+func (p *filePager) scrollToEdge(preview *tview.TextView, end bool) {
+	switch {
+	case p.tabled() && end:
+		previewTable.Select(previewTable.GetRowCount()-1, 0)
+	case p.tabled():
+		previewTable.Select(1, 0)
+	case end:
+		preview.ScrollToEnd()
+	default:
+		preview.ScrollToBeginning()
+	}
+}
+
+// afterDraw calls scrolled once the preview has been drawn, which is when
+// tview has worked out where it is scrolled to
+func (p *filePager) afterDraw(preview *tview.TextView) {
+	a, ctx := app, p.ctx
+	go func() {
+		if ctx.Err() == nil {
+			a.QueueUpdateDraw(func() {
+				p.scrolled(preview)
+			})
+		}
+	}()
+}
+
+// readEarlier puts the page before what's loaded on the front, keeping the
+// same lines on screen
+func (p *filePager) readEarlier(preview *tview.TextView) {
+	from := p.start - pageBytes
+	if from < 0 {
+		from = 0
+	}
+	p.read(from, p.start-from, func(r awslib.ObjectRange) {
+		if r.End() != p.start {
+			return
+		}
+		lines := len(p.lineEnds)
+		p.start, p.data = r.Offset, append(r.Data, p.data...)
+		if keep := windowPages * int(pageBytes); len(p.data) > keep {
+			// the end goes, as the start does paging down
+			p.data = append([]byte(nil), p.data[:keep]...)
+		}
+		p.match = -1
+		row, _ := p.position(preview)
+		p.show(preview)
+		p.scrollTo(preview, row+len(p.lineEnds)-lines)
+		p.afterDraw(preview)
+	})
+}
+
+// search looks for query from the top of the preview down, reading on through
+// the object if it's not in what's loaded
+func (p *filePager) search(preview *tview.TextView, query string) {
+	p.query, p.match = query, -1
+	if query == "" {
+		p.pattern, p.note = nil, ""
+		p.show(preview)
+		p.showTitle(preview)
+		return
+	}
+	re, err := smartcaseRegexp(query)
+	if err != nil {
+		showMessage(err.Error())
+		return
+	}
+	p.pattern = re
+	row, _ := p.position(preview)
+	p.find(preview, p.textAt(p.lineStart(row)), p.start+int64(p.lineStart(row)), searchBytes)
+}
+
+// find scrolls to the first match at or after from, an offset in text. budget
+// is how much more of the object it can read looking for one, and top is where
+// in the object the preview was, to go back to if there isn't one.
+func (p *filePager) find(preview *tview.TextView, from int, top int64, budget int64) {
+	if p.pattern == nil {
+		return
+	}
+	if from > len(p.text) {
+		from = len(p.text)
+	}
+	if loc := p.pattern.FindStringIndex(p.text[from:]); loc != nil {
+		p.match = from + loc[0]
+		p.note = ""
+		p.show(preview)
+		if p.tabled() {
+			p.scrollTo(preview, p.lineOf(p.textFrom+p.match))
+			p.afterDraw(preview)
+			return
+		}
+		// tview finds the row, wrapped or not, but leaves the column to us
+		line := p.lineOf(p.textFrom + p.match)
+		column := utf8.RuneCountInString(p.text[p.textAt(p.lineStart(line)):p.match])
+		_, _, width, _ := preview.GetInnerRect()
+		if column < width || previewWrap || !p.plain() {
+			column = 0
+		} else {
+			column -= width / 2
+		}
+		preview.ScrollTo(line, column)
+		preview.Highlight("match").ScrollToHighlight()
+		p.afterDraw(preview)
+		return
+	}
+	if p.end() >= p.size {
+		p.note = fmt.Sprintf("no more %q", p.query)
+		p.returnTo(preview, top)
+		return
+	}
+	if budget <= 0 {
+		// n carries on from the end of what's loaded
+		p.note = fmt.Sprintf("%q not in next %s", p.query, utils.HumanBytes(searchBytes))
+		p.returnTo(preview, top)
+		return
+	}
+	p.note = fmt.Sprintf("finding %q...", p.query)
+	p.showTitle(preview)
+	at := p.textFrom + from
+	p.read(p.end(), pageBytes, func(r awslib.ObjectRange) {
+		dropped := p.add(preview, r)
+		p.find(preview, p.textAt(at-dropped), top, budget-int64(len(r.Data)))
+	})
+}
+
+// returnTo scrolls back to offset in the object after a search that found
+// nothing, reading it again if the search went on so far it was let go of
+func (p *filePager) returnTo(preview *tview.TextView, offset int64) {
+	p.showTitle(preview)
+	if offset >= p.start {
+		return
+	}
+	from := offset - pageBytes
+	if from < 0 {
+		from = 0
+	}
+	p.read(from, 2*pageBytes, func(r awslib.ObjectRange) {
+		p.replace(preview, r)
+		p.scrollTo(preview, p.lineOf(int(offset-p.start)))
+		p.afterDraw(preview)
+	})
+}
+
+// showPreviewSearch asks what to look for in the preview
+func showPreviewSearch(preview *tview.TextView) {
+	p := pager
+	if p == nil {
+		return
+	}
+	if p.summarising() {
+		showMessage("Search the bytes of a parquet file with x first")
+		return
+	}
+	showPrompt("Find in preview (n for the next one): ", p.query, func(text string) {
+		if pager == p {
+			p.search(preview, text)
+		}
+	})
+}
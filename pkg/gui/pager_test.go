@@ -0,0 +1,166 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// rangeStore counts the bytes the preview reads
+type rangeStore struct {
+	*awslib.MemoryStore
+	read atomic.Int64
+}
+
+func (r *rangeStore) ReadRange(ctx context.Context, bucket string, key string, offset int64, length int64) (awslib.ObjectRange, error) {
+	got, err := r.MemoryStore.ReadRange(ctx, bucket, key, offset, length)
+	r.read.Add(int64(len(got.Data)))
+	return got, err
+}
+
+// startPaging opens pages.txt, 2000 lines of 10 bytes, in the preview a small
+// page at a time
+func startPaging(t *testing.T) (tcell.SimulationScreen, *rangeStore) {
+	old := pageBytes
+	pageBytes = 500
+	t.Cleanup(func() { pageBytes = old })
+
+	store := &rangeStore{MemoryStore: newTestStore()}
+	var b strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, "line %04d\n", i)
+	}
+	store.Put("alpha", "pages.txt", []byte(b.String()))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "pages.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "line 0000")
+	waitFor(t, screen, "% of 19.5 KiB")
+	press(tcell.KeyCtrlP)
+	return screen, store
+}
+
+func TestPreviewReadsMoreOnScroll(t *testing.T) {
+	screen, store := startPaging(t)
+	if n := store.read.Load(); n != 500 {
+		t.Errorf("read %d bytes to open it, want one page", n)
+	}
+
+	pressUntil(t, screen, "line 0120", tcell.KeyPgDn, func(text string) bool {
+		return numberAfter(text, "line ") >= 120
+	})
+	if n := store.read.Load(); n >= 20000 {
+		t.Errorf("read the whole object (%d bytes) to scroll a little way", n)
+	}
+}
+
+func TestPreviewJumpsToEnd(t *testing.T) {
+	screen, store := startPaging(t)
+
+	press(tcell.KeyEnd)
+	waitFor(t, screen, "line 1999")
+	waitFor(t, screen, "100% of 19.5 KiB from 19.0 KiB")
+	// the suffix range skips the middle, End going ahead of the second page
+	// if that was still being read
+	if n := store.read.Load(); n > 1500 {
+		t.Errorf("read %d bytes, want at most three pages", n)
+	}
+
+	// going up past the top reads the page before
+	pressUntil(t, screen, "line 1940", tcell.KeyPgUp, func(text string) bool {
+		return numberAfter(text, "line ") <= 1940
+	})
+
+	press(tcell.KeyHome)
+	waitFor(t, screen, "line 0000")
+	waitForGone(t, screen, "line 1999")
+}
+
+func TestPreviewLetsGoOfPagesScrolledPast(t *testing.T) {
+	old := windowPages
+	windowPages = 4
+	t.Cleanup(func() { windowPages = old })
+	screen, _ := startPaging(t)
+
+	// well past the 200 lines four pages hold
+	pressUntil(t, screen, "line 0300", tcell.KeyPgDn, func(text string) bool {
+		return numberAfter(text, "line ") >= 300
+	})
+	var start int64
+	var loaded int
+	onApp(func() {
+		start, loaded = pager.start, len(pager.data)
+	})
+	if start == 0 || loaded > windowPages*int(pageBytes) {
+		t.Errorf("holding %d bytes from %d, want at most %d pages", loaded, start, windowPages)
+	}
+
+	// and back up reads them again
+	pressUntil(t, screen, "line 0000", tcell.KeyPgUp, func(text string) bool {
+		return strings.Contains(text, "line 0000")
+	})
+}
+
+func TestPreviewSearch(t *testing.T) {
+	screen, store := startPaging(t)
+
+	typeText("/")
+	waitFor(t, screen, "Find in preview")
+	typeText("0700")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "line 0700")
+	// the current match is drawn inverted
+	eventually(t, "line 0700 highlighted", func() bool { return colourOf(screen, "0700") == tcell.ColorYellow })
+	if n := store.read.Load(); n >= 20000 {
+		t.Errorf("read the whole object (%d bytes) to find line 700", n)
+	}
+
+	// and back to where it was, which the search read on so far past it let go
+	typeText("n")
+	waitFor(t, screen, `no more "0`)
+	waitFor(t, screen, "line 0700")
+
+	typeText("/")
+	waitFor(t, screen, "Find in preview")
+	for range "0700" {
+		press(tcell.KeyBackspace2)
+	}
+	typeText("missing")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, `no more "mi`)
+}
+
+func TestPreviewHexDump(t *testing.T) {
+	store := newTestStore()
+	blob := []byte{0x1f, 0x8b, 0x08, 0x00, 'h', 'i', 0xff, 0x00, '[', 'x', ']'}
+	store.Put("alpha", "blob.gz", blob)
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "blob.gz")
+	press(tcell.KeyDown, tcell.KeyEnter)
+	// binary gets a dump straight away, eight bytes a row in a narrow pane
+	waitFor(t, screen, "00000000  1f 8b 08 00 68 69 ff 00  |....hi..|")
+	waitFor(t, screen, "00000008  5b 78 5d")
+	waitFor(t, screen, "|[x]     |")
+	waitFor(t, screen, "Preview <Ctrl+p> - gzip hex - 100% of 11 B")
+
+	// and text can be dumped too
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "hello from the top")
+	press(tcell.KeyCtrlP)
+	typeText("x")
+	waitFor(t, screen, "00000000  68 65 6c 6c 6f 20 66 72  |hello fr|")
+	waitFor(t, screen, "Preview <Ctrl+p> - hex")
+	typeText("x")
+	waitFor(t, screen, "hello from the top")
+	waitForGone(t, screen, "00000000  68")
+}
@@ -0,0 +1,249 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/parquet"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// parquetRows is how many rows of a parquet file t shows
+var parquetRows = 50
+
+// parquetGroups is how many row groups the summary lists before it stops
+const parquetGroups = 20
+
+// objectReader reads an object with range requests, so the parquet reader
+// fetches just the footer and the pages it needs
+type objectReader struct {
+	ctx    context.Context
+	store  awslib.ObjectStore
+	bucket string
+	key    string
+}
+
+func (o objectReader) ReadAt(b []byte, offset int64) (int, error) {
+	r, err := o.store.ReadRange(o.ctx, o.bucket, o.key, offset, int64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(b, r.Data)
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// summarising is whether the preview is showing a parquet file's metadata, or
+// its rows, rather than its bytes
+func (p *filePager) summarising() bool {
+	return p.meta != nil && !p.hex
+}
+
+// openParquet reads a parquet file's footer and shows what's in it in place of
+// the hex dump
+func (p *filePager) openParquet(preview *tview.TextView) {
+	p.fetching = true
+	p.note = "reading the footer..."
+	p.showTitle(preview)
+	r := objectReader{p.ctx, p.store, p.bucket, p.key}
+	size := p.size
+	a := app
+	go func() {
+		f, err := parquet.Open(r, size)
+		if p.ctx.Err() != nil {
+			return
+		}
+		a.QueueUpdateDraw(func() {
+			p.fetching = false
+			if pager != p {
+				return
+			}
+			p.note = ""
+			if err != nil {
+				if !reportStoreError(err) {
+					// not much of a parquet file, so leave it as bytes
+					p.note = err.Error()
+				}
+				p.showTitle(preview)
+				return
+			}
+			p.meta = f
+			p.show(preview)
+			preview.ScrollToBeginning()
+			p.showTitle(preview)
+		})
+	}()
+}
+
+// readRows reads the first rows of the parquet file for the table
+func (p *filePager) readRows(preview *tview.TextView) {
+	p.fetching = true
+	p.note = "reading rows..."
+	p.showTitle(preview)
+	f := p.meta
+	a := app
+	go func() {
+		rows, err := f.Rows(parquetRows)
+		if p.ctx.Err() != nil {
+			return
+		}
+		a.QueueUpdateDraw(func() {
+			p.fetching = false
+			if pager != p {
+				return
+			}
+			p.note = ""
+			if rows == nil {
+				// so they aren't read again
+				rows = [][]string{}
+			}
+			p.rows, p.rowsErr = rows, err
+			reportStoreError(err)
+			p.show(preview)
+			p.showTitle(preview)
+		})
+	}()
+}
+
+// reportStoreError reports err if it came from S3 rather than from what's in
+// the file, returning whether it did
+func reportStoreError(err error) bool {
+	var opErr *awslib.OpError
+	if errors.As(err, &opErr) {
+		reportError(opErr)
+		return true
+	}
+	return false
+}
+
+// showParquet is show for a parquet file, the summary or the rows
+func (p *filePager) showParquet(preview *tview.TextView) {
+	if p.showRows && p.rows != nil {
+		header := make([]string, len(p.meta.Columns))
+		for i, c := range p.meta.Columns {
+			header[i] = c.Name()
+		}
+		fillTable(header, p.rows, p.highlight)
+		showPreviewPage("table")
+		return
+	}
+	preview.SetWrap(true).SetDynamicColors(true)
+	preview.SetText(parquetSummary(p.meta, p.rowsErr))
+	showPreviewPage("text")
+}
+
+// parquetTitle is what goes after the labels in the title of a parquet file
+func (p *filePager) parquetTitle() string {
+	if p.showRows && p.rows != nil {
+		title := fmt.Sprintf("first %d of %d rows", len(p.rows), p.meta.NumRows)
+		if p.rowsErr != nil {
+			title += " - some columns unreadable"
+		}
+		return title
+	}
+	return fmt.Sprintf("%d rows, groups: %d", p.meta.NumRows, len(p.meta.RowGroups))
+}
+
+// handleParquetKey is handleKey while the summary or rows are showing. The
+// text and table scroll themselves, there's nothing more to read.
+func (p *filePager) handleParquetKey(preview *tview.TextView, event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+	switch event.Rune() {
+	case 't':
+		p.showRows = !p.showRows
+		if p.showRows && p.rows == nil {
+			if !p.fetching {
+				p.readRows(preview)
+			}
+			return nil
+		}
+		p.show(preview)
+		p.showTitle(preview)
+		return nil
+	case 'x':
+		p.hex, p.chosen = true, true
+		p.show(preview)
+		preview.ScrollToBeginning()
+		p.afterDraw(preview)
+		return nil
+	}
+	return event
+}
+
+// parquetSummary describes a parquet file from its metadata. rowsErr is why
+// some columns couldn't be shown in the table, if they couldn't.
+func parquetSummary(f *parquet.File, rowsErr error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]%d rows[-], %d columns, row groups: %d\n", f.NumRows, len(f.Columns), len(f.RowGroups))
+	if f.CreatedBy != "" {
+		fmt.Fprintf(&b, "Written by %s\n", tview.Escape(f.CreatedBy))
+	}
+	fmt.Fprintf(&b, "Compression: %s\n", strings.Join(f.Codecs(), ", "))
+	fmt.Fprintf(&b, "Metadata: %s of %s\n", utils.HumanBytes(f.MetadataSize), utils.HumanBytes(f.Size))
+	fmt.Fprintf(&b, "[gray]t shows the first %d rows, x the bytes[-]\n", parquetRows)
+	if rowsErr != nil {
+		b.WriteString("\n[red]Some columns couldn't be read[-]\n")
+		for _, line := range strings.Split(rowsErr.Error(), "\n") {
+			fmt.Fprintf(&b, "  %s\n", tview.Escape(line))
+		}
+	}
+
+	b.WriteString("\n[yellow]Columns[-]\n")
+	for _, c := range f.Columns {
+		fmt.Fprintf(&b, "[aqua]%s[-] %s", tview.Escape(c.Name()), c.Type)
+		if c.Logical != "" {
+			fmt.Fprintf(&b, " %s", c.Logical)
+		}
+		fmt.Fprintf(&b, " %s\n", c.Repetition)
+		fmt.Fprintf(&b, "  %s, %s (%s uncompressed), %s\n", strings.Join(c.Codecs, " "),
+			utils.HumanBytes(c.Compressed), utils.HumanBytes(c.Uncompressed), strings.Join(c.Encodings, " "))
+		fmt.Fprintf(&b, "  %s\n", statsText(c.Stats()))
+	}
+
+	b.WriteString("\n[yellow]Row groups[-]\n")
+	for i, g := range f.RowGroups {
+		if i == parquetGroups {
+			fmt.Fprintf(&b, "  ...and %d more\n", len(f.RowGroups)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %d: %d rows, %s\n", i, g.NumRows, utils.HumanBytes(g.TotalBytes))
+	}
+	return b.String()
+}
+
+// statsText is a column's statistics in a line
+func statsText(s parquet.Stats) string {
+	var parts []string
+	if s.HasRange {
+		parts = append(parts, fmt.Sprintf("min %s, max %s", statValue(s.Min), statValue(s.Max)))
+	}
+	if s.HasNulls {
+		parts = append(parts, fmt.Sprintf("%d nulls", s.Nulls))
+	}
+	if s.HasDistinct {
+		parts = append(parts, fmt.Sprintf("%d distinct", s.Distinct))
+	}
+	if len(parts) == 0 {
+		return "[gray]no statistics[-]"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// statValue shortens long strings in the statistics
+func statValue(v string) string {
+	if r := []rune(v); len(r) > cellWidth {
+		v = string(r[:cellWidth-1]) + "…"
+	}
+	return tview.Escape(v)
+}
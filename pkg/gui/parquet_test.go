@@ -0,0 +1,52 @@
+package gui
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPreviewParquet(t *testing.T) {
+	// three rows of id, name, price and at, the last compressed with zstd
+	data, err := os.ReadFile("../parquet/testdata/people.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newTestStore()
+	store.Put("alpha", "people.parquet", data)
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "people.parquet")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+	// the footer is shown rather than the bytes
+	waitFor(t, screen, "3 rows, 4 columns, row groups: 1")
+	waitFor(t, screen, "Preview <Ctrl+p> - parquet - 3 rows, groups: 1")
+	waitFor(t, screen, "Compression: UNCOMPRESSED, SNAPPY, ZSTD")
+	waitFor(t, screen, "name BYTE_ARRAY STRING optional")
+	waitFor(t, screen, "min ann, max bob, 1 nulls, 2 distinct")
+	waitFor(t, screen, "price INT32 DECIMAL(9,2) required")
+	waitFor(t, screen, "no statistics")
+	waitFor(t, screen, "min 1970-01-01 00:00:00, max 1970-01-02 00:00:00")
+	waitFor(t, screen, "0: 3 rows")
+
+	// t reads the first rows into a table
+	press(tcell.KeyCtrlP)
+	typeText("t")
+	waitFor(t, screen, "first 3 of 3 rows")
+	waitFor(t, screen, "1000.00")
+	waitFor(t, screen, "null")
+	if colourOf(screen, "price") != tcell.ColorYellow {
+		t.Errorf("header isn't picked out")
+	}
+
+	// and back, where it says what couldn't be read
+	typeText("t")
+	waitFor(t, screen, "at: ZSTD compression isn't supported")
+
+	typeText("x")
+	waitFor(t, screen, "00000000  50 41 52 31")
+	typeText("x")
+	waitFor(t, screen, "3 rows, 4 columns")
+}
@@ -0,0 +1,266 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sahilm/fuzzy"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// Connector builds a store for a named profile, used when swapping credentials
+type Connector func(profile string) (awslib.ObjectStore, error)
+
+// swapFunc replaces the store every handler uses, called on the ui goroutine
+type swapFunc func(store awslib.ObjectStore, profile string, buckets []string)
+
+// centered puts p in the middle of the screen at the given size
+func centered(p tview.Primitive, width int, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+// showMessage pops up a modal with text and puts the panes back when dismissed
+func showMessage(text string) {
+	focus := app.GetFocus()
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			restoreDefaultGrid(focus)
+		})
+	app.SetRoot(modal, true)
+}
+
+// showProfilePicker lists every profile in the aws files with a fuzzy filter
+// on top. connect is called off the ui goroutine, as building credentials can
+// mean MFA or SSO prompts. swap is then called on the ui goroutine.
+func showProfilePicker(connect Connector, swap swapFunc) {
+	if connect == nil {
+		return
+	}
+	focus := app.GetFocus()
+	profiles, err := awslib.LoadProfiles()
+	if err != nil {
+		showMessage(fmt.Sprintf("Can't read AWS profiles:\n%v", err))
+		return
+	}
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	fill := func(items []string) {
+		list.Clear()
+		for _, name := range items {
+			list.AddItem(name, "", 0, nil)
+		}
+	}
+	fill(names)
+
+	filter := tview.NewInputField().SetLabel("Profile: ")
+	filter.SetChangedFunc(func(text string) {
+		if text == "" {
+			fill(names)
+			return
+		}
+		var matches []string
+		for _, m := range fuzzy.Find(text, names) {
+			matches = append(matches, m.Str)
+		}
+		fill(matches)
+	})
+
+	pick := func() {
+		if list.GetItemCount() == 0 {
+			return
+		}
+		profile, _ := list.GetItemText(list.GetCurrentItem())
+		restoreDefaultGrid(focus)
+		switchProfile(connect, profile, swap)
+	}
+
+	list.SetSelectedFunc(func(int, string, string, rune) {
+		pick()
+	})
+	filter.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown:
+			ScrollDown(list)
+			return nil
+		case tcell.KeyUp:
+			ScrollUp(list)
+			return nil
+		case tcell.KeyEnter:
+			pick()
+			return nil
+		case tcell.KeyEscape:
+			restoreDefaultGrid(focus)
+			return nil
+		}
+		return event
+	})
+
+	box := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(filter, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	box.SetBorder(true).SetTitle("Swap credentials").SetTitleAlign(tview.AlignLeft)
+
+	app.SetRoot(centered(box, 60, 20), true).SetFocus(filter)
+}
+
+// switchProfile connects to profile in the background and swaps over to it
+// once the buckets are listed, so a bad profile leaves the current one alone
+func switchProfile(connect Connector, profile string, swap swapFunc) {
+	originalTitle := bucketList.GetTitle()
+	bucketList.SetTitle(fmt.Sprintf("Connecting to %s...", profile))
+
+	a := app
+	go func() {
+		store, err := connect(profile)
+		var buckets []string
+		if err == nil {
+			buckets, err = store.GetBuckets()
+		}
+		a.QueueUpdateDraw(func() {
+			bucketList.SetTitle(originalTitle)
+			if err != nil {
+				showMessage(fmt.Sprintf("Can't switch to %s:\n%v", profile, err))
+				return
+			}
+			swap(store, profile, buckets)
+		})
+	}()
+}
+
+// swapped out in tests so saving a profile doesn't need to reach STS
+var validateCredentials = awslib.ValidateCredentials
+
+// showAddProfileForm asks for a set of keys, checks them with STS and writes
+// them to the credentials file. The new profile shows up in the picker straight
+// away, and we offer to switch to it.
+func showAddProfileForm(connect Connector, swap swapFunc) {
+	focus := app.GetFocus()
+	const title = "AWS Credentials Configuration"
+
+	form := tview.NewForm().
+		AddInputField("Name", "", 50, nil, nil).
+		AddInputField("Access Key", "", 50, nil, nil).
+		AddPasswordField("Secret Access Key", "", 50, '*', nil).
+		AddPasswordField("Session Token (Optional)", "", 50, '*', nil).
+		// or instead of keys, sign in through an sso-session in the config file
+		AddInputField("SSO Session (Optional)", "", 50, nil, nil).
+		AddInputField("SSO Account ID", "", 50, nil, nil).
+		AddInputField("SSO Role Name", "", 50, nil, nil).
+		AddTextView("Note", "Keys will be stored inside\n"+awslib.CredentialsFile()+
+			"\nand SSO settings inside\n"+awslib.ConfigFile(), 50, 4, true, false)
+	form.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+	root := centered(form, 82, 25)
+
+	text := func(label string) string {
+		return strings.TrimSpace(form.GetFormItemByLabel(label).(*tview.InputField).GetText())
+	}
+	backToForm := func(field string) {
+		form.SetTitle(title)
+		form.SetFocus(form.GetFormItemIndex(field))
+		app.SetRoot(root, true).SetFocus(form)
+	}
+	ask := func(message string, buttons []string, done func(label string)) {
+		modal := tview.NewModal().
+			SetText(message).
+			AddButtons(buttons).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				done(buttonLabel)
+			})
+		app.SetRoot(modal, true)
+	}
+
+	saved := func(name string, who string, err error) {
+		if err != nil {
+			ask(fmt.Sprintf("Couldn't save %s:\n%v", name, err), []string{"OK"}, func(string) {
+				backToForm("Name")
+			})
+			return
+		}
+		if connect == nil {
+			showMessage(fmt.Sprintf("Saved profile %s%s", name, who))
+			return
+		}
+		ask(fmt.Sprintf("Saved profile %s%s\n\nSwitch to it now?", name, who), []string{"Switch", "Stay"}, func(label string) {
+			restoreDefaultGrid(focus)
+			if label == "Switch" {
+				switchProfile(connect, name, swap)
+			}
+		})
+	}
+	save := func(name, accessKey, secretKey, token, who string) {
+		saved(name, who, awslib.SaveProfile(name, accessKey, secretKey, token))
+	}
+
+	checking := false
+	form.AddButton("Save", func() {
+		if checking {
+			return
+		}
+		name, accessKey, secretKey := text("Name"), text("Access Key"), text("Secret Access Key")
+		token := text("Session Token (Optional)")
+		if session := text("SSO Session (Optional)"); session != "" {
+			account, role := text("SSO Account ID"), text("SSO Role Name")
+			if name == "" || account == "" || role == "" || accessKey != "" || secretKey != "" {
+				ask("An SSO profile needs a Name, SSO Account ID and SSO Role Name, and no keys", []string{"OK"}, func(string) {
+					backToForm("Name")
+				})
+				return
+			}
+			// there's nothing to check before "aws sso login", switching to it
+			// goes through the SDK's SSO credentials and says if that's needed
+			saved(name, "", awslib.SaveSSOProfile(name, session, account, role))
+			return
+		}
+		if name == "" || accessKey == "" || secretKey == "" {
+			ask("Name, Access Key and Secret Access Key are required", []string{"OK"}, func(string) {
+				backToForm("Name")
+			})
+			return
+		}
+
+		form.SetTitle("Checking credentials...")
+		checking = true
+		a := app
+		go func() {
+			id, err := validateCredentials(accessKey, secretKey, token)
+			a.QueueUpdateDraw(func() {
+				checking = false
+				if err != nil {
+					// custom endpoints (MinIO etc) often have no STS, so let people save anyway
+					ask(fmt.Sprintf("Couldn't verify these credentials:\n%v", err), []string{"Save anyway", "Back"}, func(label string) {
+						if label == "Save anyway" {
+							save(name, accessKey, secretKey, token, "")
+							return
+						}
+						backToForm("Access Key")
+					})
+					return
+				}
+				save(name, accessKey, secretKey, token, fmt.Sprintf(" (%s %s)", id.Account, id.Name()))
+			})
+		}()
+	})
+	form.AddButton("Cancel", func() {
+		restoreDefaultGrid(focus)
+	})
+	form.SetCancelFunc(func() {
+		restoreDefaultGrid(focus)
+	})
+
+	app.SetRoot(root, true).SetFocus(form)
+}
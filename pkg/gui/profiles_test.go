@@ -0,0 +1,175 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func TestSwapProfile(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	content := "[default]\naws_access_key_id = a\naws_secret_access_key = b\n\n[work]\naws_access_key_id = c\naws_secret_access_key = d\n"
+	if err := os.WriteFile(credentials, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	work := awslib.NewMemoryStore()
+	work.Put("gamma", "work.txt", []byte("work"))
+	connect := func(profile string) (awslib.ObjectStore, error) {
+		if profile != "work" {
+			return nil, fmt.Errorf("unexpected profile %s", profile)
+		}
+		return work, nil
+	}
+
+	screen := startGuiWithConnector(t, newTestStore(), connect)
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+
+	press(tcell.KeyCtrlS)
+	waitFor(t, screen, "Swap credentials")
+	waitFor(t, screen, "default")
+	typeText("wrk")
+	waitForGone(t, screen, "default")
+	press(tcell.KeyEnter)
+
+	waitFor(t, screen, "gamma (us-east-1)")
+	waitFor(t, screen, "Credentials: work")
+	waitForGone(t, screen, "alpha")
+	// the old bucket's files are cleared out
+	waitForGone(t, screen, "top.txt")
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "work.txt")
+}
+
+func TestAddProfile(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(credentials, []byte("# keep me\n[default]\naws_access_key_id = a\naws_secret_access_key = b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	validateCredentials = func(accessKey, secretKey, token string) (awslib.Identity, error) {
+		if accessKey != "AKIANEW" || secretKey != "newSecret" {
+			return awslib.Identity{}, fmt.Errorf("InvalidClientTokenId")
+		}
+		return awslib.Identity{Account: "123456789012", ARN: "arn:aws:iam::123456789012:user/new"}, nil
+	}
+	defer func() { validateCredentials = awslib.ValidateCredentials }()
+
+	fresh := awslib.NewMemoryStore()
+	fresh.Put("delta", "new.txt", []byte("new"))
+	connect := func(profile string) (awslib.ObjectStore, error) {
+		if profile != "new" {
+			return nil, fmt.Errorf("unexpected profile %s", profile)
+		}
+		return fresh, nil
+	}
+
+	screen := startGuiWithConnector(t, newTestStore(), connect)
+	waitFor(t, screen, "alpha (us-east-1)")
+
+	press(tcell.KeyCtrlE)
+	waitFor(t, screen, "AWS Credentials Configuration")
+	typeText("new")
+	press(tcell.KeyTab)
+	typeText("AKIANEW")
+	press(tcell.KeyTab)
+	typeText("wrong")
+	// tab past the token and the SSO fields to the Save button
+	toSave := []tcell.Key{tcell.KeyTab, tcell.KeyTab, tcell.KeyTab, tcell.KeyTab, tcell.KeyTab, tcell.KeyEnter}
+	press(toSave...)
+
+	waitFor(t, screen, "Couldn't verify these credentials")
+	press(tcell.KeyRight, tcell.KeyEnter)
+	waitFor(t, screen, "AWS Credentials Configuration")
+
+	// back on the form at the keys, fix the secret
+	press(tcell.KeyTab)
+	for range "wrong" {
+		press(tcell.KeyBackspace2)
+	}
+	typeText("newSecret")
+	press(toSave...)
+
+	waitFor(t, screen, "Saved profile new (123456789012 user/new)")
+	content, err := os.ReadFile(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# keep me\n[default]\naws_access_key_id = a\naws_secret_access_key = b\n\n[new]\naws_access_key_id = AKIANEW\naws_secret_access_key = newSecret\n"
+	if string(content) != want {
+		t.Errorf("credentials file =\n%s", content)
+	}
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "delta (us-east-1)")
+	waitFor(t, screen, "Credentials: new")
+}
+
+func TestAddSSOProfile(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	if err := os.WriteFile(config, []byte("[sso-session corp]\nsso_region = us-east-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", config)
+
+	validateCredentials = func(accessKey, secretKey, token string) (awslib.Identity, error) {
+		t.Error("an SSO profile's keys were checked")
+		return awslib.Identity{}, nil
+	}
+	defer func() { validateCredentials = awslib.ValidateCredentials }()
+
+	fresh := awslib.NewMemoryStore()
+	fresh.Put("delta", "new.txt", []byte("new"))
+	var connected string
+	connect := func(profile string) (awslib.ObjectStore, error) {
+		connected = profile
+		return fresh, nil
+	}
+
+	screen := startGuiWithConnector(t, newTestStore(), connect)
+	waitFor(t, screen, "alpha (us-east-1)")
+
+	press(tcell.KeyCtrlE)
+	waitFor(t, screen, "AWS Credentials Configuration")
+	typeText("sso")
+	// past the keys and the token
+	press(tcell.KeyTab, tcell.KeyTab, tcell.KeyTab, tcell.KeyTab)
+	typeText("corp")
+	press(tcell.KeyTab)
+	typeText("123456789012")
+	press(tcell.KeyTab)
+	typeText("ReadOnly")
+	press(tcell.KeyTab, tcell.KeyEnter)
+
+	waitFor(t, screen, "Saved profile sso")
+	content, err := os.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[sso-session corp]\nsso_region = us-east-1\n\n[profile sso]\nsso_session = corp\nsso_account_id = 123456789012\nsso_role_name = ReadOnly\n"
+	if string(content) != want {
+		t.Errorf("config file =\n%s", content)
+	}
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "delta (us-east-1)")
+	waitFor(t, screen, "Credentials: sso")
+	if connected != "sso" {
+		t.Errorf("connected to %q", connected)
+	}
+}
@@ -0,0 +1,100 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// showRenameFolder asks where to move folder to, the new name can include
+// slashes to move it somewhere else in the bucket
+func showRenameFolder(s awslib.ObjectStore, files *tview.List, folder string) {
+	showPrompt(fmt.Sprintf("Rename s3://%s/%s to: ", bucketName, folder), folder, func(to string) {
+		if to == "" || to == folder {
+			return
+		}
+		renameFolder(s, files, bucketName, folder, to)
+	})
+}
+
+// renameFolder moves a folder in the background with progress in the files
+// title. If it fails part way the journal keeps track of it and retrying
+// picks up where it stopped.
+func renameFolder(s awslib.ObjectStore, files *tview.List, bucket string, from string, to string) {
+	originalTitle := files.GetTitle()
+	progress := progressTitle(files, "Renaming", from)
+
+	a := app
+	var rename func()
+	rename = func() {
+		summary, err := s.RenamePrefix(bucket, from, to, progress)
+		a.QueueUpdateDraw(func() {
+			files.SetTitle(originalTitle)
+			if bucket == bucketName {
+				clearPreview()
+				refreshFiles(s, files)
+			}
+			if err != nil {
+				reportErrorWithRetry(err, func() { go rename() })
+				return
+			}
+			if len(summary.Failed) > 0 {
+				showRenameSummary(bucket, from, to, summary)
+			}
+		})
+	}
+	go rename()
+}
+
+func showRenameSummary(bucket string, from string, to string, summary awslib.RenameSummary) {
+	var failed []string
+	for key, err := range summary.Failed {
+		reason := err.Error()
+		var opErr *awslib.OpError
+		if errors.As(err, &opErr) {
+			reason = opErr.Message()
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", tview.Escape(key), tview.Escape(reason)))
+	}
+	sort.Strings(failed)
+	if len(failed) > maxFailuresShown {
+		failed = append(failed[:maxFailuresShown], "...")
+	}
+	showMessage(fmt.Sprintf("Renaming s3://%s/%s to %s: %s\n\nFailed:\n%s\n\nThe originals were kept, rename it again to finish off",
+		bucket, tview.Escape(from), tview.Escape(to), summary, strings.Join(failed, "\n")))
+}
+
+// checkPendingRenames offers to finish a folder rename that was cut short last
+// time this bucket was open
+func checkPendingRenames(s awslib.ObjectStore, files *tview.List) {
+	pending, err := awslib.PendingRenames(bucketName)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	r := pending[0]
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Renaming s3://%s/%s to %s didn't finish (started %s).\n\nPick it up where it left off?",
+			r.Bucket, tview.Escape(r.From), tview.Escape(r.To), r.Started.Local().Format("2006-01-02 15:04"))).
+		AddButtons([]string{"Resume", "Later", "Forget"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			restoreDefaultGrid(files)
+			switch buttonLabel {
+			case "Resume":
+				renameFolder(s, files, r.Bucket, r.From, r.To)
+			case "Forget":
+				if err := awslib.ForgetRename(r); err != nil {
+					reportError(err)
+				}
+			}
+		})
+	app.SetRoot(modal, true)
+}
@@ -0,0 +1,66 @@
+package gui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func TestRenameFolder(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/")
+	press(tcell.KeyCtrlR)
+	waitFor(t, screen, "Rename s3://alpha/docs/ to:")
+	for range "docs/" {
+		press(tcell.KeyBackspace2)
+	}
+	typeText("manuals")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "manuals/")
+	waitForGone(t, screen, "docs/")
+
+	for _, key := range []string{"manuals/readme.md", "manuals/deep/nested.txt"} {
+		if _, ok := store.Get("alpha", key); !ok {
+			t.Errorf("%s is missing", key)
+		}
+	}
+	if _, ok := store.Get("alpha", "docs/readme.md"); ok {
+		t.Error("docs/readme.md is still there")
+	}
+}
+
+func TestResumePendingRename(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	journal := awslib.RenameJournalFile()
+	if err := os.MkdirAll(filepath.Dir(journal), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	data := `[{"bucket": "alpha", "from": "docs/", "to": "archive/docs/", "started": "2024-01-02T03:04:05Z"}]`
+	if err := os.WriteFile(journal, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "Renaming s3://alpha/docs/ to archive/docs/ didn't finish")
+	// resume is the default
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "archive/")
+	waitForGone(t, screen, "docs/")
+
+	if _, ok := store.Get("alpha", "archive/docs/readme.md"); !ok {
+		t.Error("docs/ wasn't moved")
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal is still there: %v", err)
+	}
+}
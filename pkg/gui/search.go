@@ -0,0 +1,279 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sahilm/fuzzy"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+// maxResults is how many matches a bucket search shows
+const maxResults = 200
+
+// searchDelay is how long a bucket search waits for typing to stop before
+// matching, as matching a big bucket's keys takes a while
+var searchDelay = 150 * time.Millisecond
+
+// matchChunk is how many keys are matched between checks that the search is
+// still wanted
+const matchChunk = 10000
+
+// keyIndex is every key under prefix in a bucket, listed in the background a
+// page at a time. It's kept between searches until something changes.
+type keyIndex struct {
+	store   awslib.ObjectStore
+	bucket  string
+	prefix  string
+	keys    []string
+	done    bool
+	err     error
+	stopped bool
+	// called on the ui goroutine as pages come in
+	updated func()
+}
+
+var searchIndex *keyIndex
+
+// indexFor reuses the index of bucket under prefix or starts a new one
+func indexFor(s awslib.ObjectStore, bucket string, prefix string) *keyIndex {
+	if i := searchIndex; i != nil && i.store == s && i.bucket == bucket && i.prefix == prefix && i.err == nil {
+		return i
+	}
+	forgetIndex()
+	i := &keyIndex{store: s, bucket: bucket, prefix: prefix}
+	searchIndex = i
+	go i.build(app)
+	return i
+}
+
+// forgetIndex stops the index being built, e.g. after keys were changed
+func forgetIndex() {
+	if searchIndex != nil {
+		searchIndex.stopped = true
+	}
+	searchIndex = nil
+}
+
+func (i *keyIndex) build(a *tview.Application) {
+	// no delimiter lists everything under the prefix, however deep
+	lister := i.store.ListDirectory(i.bucket, "", i.prefix)
+	for {
+		more := lister.HasMore()
+		var page awslib.DirectoryPage
+		var err error
+		if more {
+			page, err = lister.NextPage()
+		}
+		stopped := false
+		a.QueueUpdateDraw(func() {
+			stopped = i.stopped
+			if stopped {
+				return
+			}
+			for _, entry := range page.Keys {
+				i.keys = append(i.keys, entry.Key)
+			}
+			i.err = err
+			i.done = !more
+			if i.updated != nil {
+				i.updated()
+			}
+		})
+		if !more || err != nil || stopped {
+			return
+		}
+	}
+}
+
+func (i *keyIndex) status() string {
+	switch {
+	case i.err != nil:
+		return fmt.Sprintf("listing failed after %d keys: %v", len(i.keys), i.err)
+	case !i.done:
+		return fmt.Sprintf("indexing, %d keys so far...", len(i.keys))
+	}
+	return fmt.Sprintf("%d keys", len(i.keys))
+}
+
+// showBucketSearch fuzzy finds keys anywhere under prefix in the current
+// bucket. Tab widens it to the whole bucket, enter opens the key's folder with
+// it selected.
+func showBucketSearch(s awslib.ObjectStore, files *tview.List, prefix string) {
+	if bucketName == "" {
+		return
+	}
+	focus := app.GetFocus()
+	bucket := bucketName
+	results := tview.NewList().ShowSecondaryText(false)
+	results.SetBorder(true).SetBorderColor(tcell.ColorYellow)
+	input := tview.NewInputField().SetFieldWidth(100)
+
+	var idx *keyIndex
+	var shown []string
+	// stops the match on its way, when there's a newer one or the search is left
+	stopMatch := func() {}
+	update := func() {
+		stopMatch()
+		ctx, cancel := context.WithCancel(appCtx)
+		stopMatch = cancel
+		text := input.GetText()
+		// the index only ever has keys appended, so these won't change under the match
+		keys := idx.keys[:len(idx.keys):len(idx.keys)]
+		title := fmt.Sprintf("Search s3://%s/%s - %s", bucket, idx.prefix, idx.status())
+		a := app
+		go func() {
+			if text != "" {
+				select {
+				case <-time.After(searchDelay):
+				case <-ctx.Done():
+					return
+				}
+			}
+			matched, labels := matchKeys(ctx, text, keys)
+			if ctx.Err() != nil {
+				return
+			}
+			a.QueueUpdateDraw(func() {
+				if ctx.Err() != nil {
+					return
+				}
+				shown = matched
+				results.Clear()
+				for _, label := range labels {
+					results.AddItem(label, "", 0, nil)
+				}
+				results.SetTitle(title)
+			})
+		}()
+	}
+	scope := func(prefix string) {
+		if idx != nil {
+			idx.updated = nil
+		}
+		idx = indexFor(s, bucket, prefix)
+		idx.updated = update
+		label := fmt.Sprintf("Search s3://%s/%s", bucket, prefix)
+		if other := otherScope(prefix, currentPrefix()); other != "" {
+			label += fmt.Sprintf(" (Tab for %s)", other)
+		}
+		input.SetLabel(label + ": ")
+		update()
+	}
+	leave := func() {
+		stopMatch()
+		idx.updated = nil
+		restoreDefaultGrid(focus)
+	}
+
+	input.SetChangedFunc(func(text string) {
+		update()
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown:
+			ScrollDown(results)
+			return nil
+		case tcell.KeyUp:
+			ScrollUp(results)
+			return nil
+		case tcell.KeyTab:
+			if idx.prefix != "" {
+				scope("")
+			} else if here := currentPrefix(); here != "" {
+				scope(here)
+			}
+			return nil
+		case tcell.KeyEscape:
+			leave()
+			return nil
+		case tcell.KeyEnter:
+			if results.GetItemCount() == 0 {
+				return nil
+			}
+			key := shown[results.GetCurrentItem()]
+			leave()
+			jumpToKey(s, files, key)
+			return nil
+		}
+		return event
+	})
+
+	grid := CreateGridWithSearch(bucketList, results, previewPane, input)
+	app.SetRoot(grid, true).SetFocus(input)
+	scope(prefix)
+}
+
+// matchKeys fuzzy finds text in keys, best first, and the labels to show them
+// with. It's run off the ui goroutine and gives up, returning nothing, once ctx
+// is cancelled.
+func matchKeys(ctx context.Context, text string, keys []string) ([]string, []string) {
+	var matched, labels []string
+	if text == "" {
+		for _, key := range keys {
+			if len(matched) == maxResults {
+				break
+			}
+			matched = append(matched, key)
+			labels = append(labels, tview.Escape(key))
+		}
+		return matched, labels
+	}
+	var matches fuzzy.Matches
+	for from := 0; from < len(keys); from += matchChunk {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		to := min(from+matchChunk, len(keys))
+		for _, m := range fuzzy.Find(text, keys[from:to]) {
+			m.Index += from
+			matches = append(matches, m)
+		}
+	}
+	// best first, and in key order between equally good ones
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Index < matches[j].Index
+	})
+	for _, m := range matches {
+		if len(matched) == maxResults {
+			break
+		}
+		matched = append(matched, m.Str)
+		labels = append(labels, highlight(m.Str, m.MatchedIndexes))
+	}
+	return matched, labels
+}
+
+// otherScope is what Tab switches a search to, "" if there's nowhere to go
+func otherScope(prefix string, here string) string {
+	if prefix != "" {
+		return "the whole bucket"
+	}
+	return here
+}
+
+// jumpToKey opens the folder key is in with key selected
+func jumpToKey(s awslib.ObjectStore, files *tview.List, key string) {
+	folder := key[:strings.LastIndex(key, "/")+1]
+	selectedFile = folder
+	currentFocus = "files"
+	app.SetFocus(files)
+	files.SetBorderColor(tcell.ColorYellow)
+	bucketList.SetBorderColor(tcell.ColorWhite)
+	loadFiles(s, files, folder, func(err error) {
+		if err != nil {
+			reportError(err)
+			return
+		}
+		selectListed(files, key)
+	})
+}
@@ -0,0 +1,83 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSearchBucketAndJump(t *testing.T) {
+	store := newTestStore()
+	store.SetPageSize(2)
+	store.Put("alpha", "docs/deep/nested.txt", []byte("found me"))
+	for i := 0; i < 20; i++ {
+		store.Put("alpha", fmt.Sprintf("docs/deep/filler-%02d.txt", i), nil)
+	}
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	typeText("F")
+	waitFor(t, screen, "Search s3://alpha/ - 24 keys")
+	typeText("nestd")
+	waitFor(t, screen, "docs/deep/nested.txt")
+	waitForGone(t, screen, "filler-")
+
+	// nested.txt sorts after all the filler, pages later than the first
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "Files <Ctrl+f>")
+	waitFor(t, screen, "docs/deep/filler-00.txt")
+	waitFor(t, screen, "docs/deep/nested.txt")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "found me")
+	waitForGone(t, screen, "Search s3://")
+}
+
+func TestSearchWidensToBucket(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "readme.md")
+	typeText("F")
+	waitFor(t, screen, "Search s3://alpha/docs/ (Tab for the whole bucket)")
+	waitFor(t, screen, "2 keys")
+	typeText("top")
+	waitForGone(t, screen, "top.txt")
+
+	press(tcell.KeyTab)
+	waitFor(t, screen, "Search s3://alpha/ (Tab for docs/)")
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyEscape)
+	waitForGone(t, screen, "Search s3://")
+	waitFor(t, screen, "readme.md")
+}
+
+func TestMatchKeysAcrossChunks(t *testing.T) {
+	var keys []string
+	for i := 0; i < 2*matchChunk+500; i++ {
+		keys = append(keys, fmt.Sprintf("filler-%05d", i))
+	}
+	// two as good as each other in different chunks, and a worse one between them
+	keys[5] = "a/nested.txt"
+	keys[2*matchChunk] = "z/nested.txt"
+	keys[matchChunk+1] = "b/nest-ed.txt"
+
+	matched, labels := matchKeys(context.Background(), "nested", keys)
+	want := []string{"a/nested.txt", "z/nested.txt", "b/nest-ed.txt"}
+	if fmt.Sprint(matched) != fmt.Sprint(want) {
+		t.Errorf("matched %q, want %q", matched, want)
+	}
+	if len(labels) != len(matched) || labels[0] != "a/[yellow::b]nested[-::-].txt" {
+		t.Errorf("labels = %q", labels)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if matched, _ := matchKeys(ctx, "nested", keys); matched != nil {
+		t.Errorf("a stale match gave %q", matched)
+	}
+}
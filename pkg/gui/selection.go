@@ -0,0 +1,473 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// marked items are drawn with a tick in yellow. The list item text carries the
+// markup so keyFromLabel is needed to get back to the key.
+const (
+	markedPrefix = "[yellow]✔ "
+	markedSuffix = "[-]"
+)
+
+// marked is every key picked out in the files pane. It spans folders but not
+// buckets, changing bucket or credentials clears it.
+var marked = map[string]bool{}
+
+func fileLabel(key string) string {
+	// keys like "logs[2024].txt" would otherwise be taken for colour tags
+	label := tview.Escape(key)
+	if marked[key] {
+		label = markedPrefix + label + markedSuffix
+	}
+	label = columnText(key) + label
+	rowKeys[label] = key
+	return label
+}
+
+func keyFromLabel(label string) string {
+	if key, ok := rowKeys[label]; ok {
+		return key
+	}
+	if strings.HasPrefix(label, markedPrefix) && strings.HasSuffix(label, markedSuffix) {
+		return label[len(markedPrefix) : len(label)-len(markedSuffix)]
+	}
+	return label
+}
+
+// setMarked marks or unmarks key and redraws its label wherever it's listed
+func setMarked(files *tview.List, key string, mark bool) {
+	if key == ".." || marked[key] == mark {
+		return
+	}
+	if mark {
+		marked[key] = true
+	} else {
+		delete(marked, key)
+	}
+	for i := 0; i < files.GetItemCount(); i++ {
+		text, _ := files.GetItemText(i)
+		if keyFromLabel(text) == key {
+			files.SetItemText(i, fileLabel(key), "")
+		}
+	}
+	for i, label := range initialFiles {
+		if keyFromLabel(label) == key {
+			initialFiles[i] = fileLabel(key)
+		}
+	}
+}
+
+// listedKeys is every key in the files pane, skipping ".."
+func listedKeys(files *tview.List) []string {
+	var keys []string
+	for i := 0; i < files.GetItemCount(); i++ {
+		text, _ := files.GetItemText(i)
+		if key := keyFromLabel(text); key != ".." {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func toggleMark(files *tview.List) {
+	text, _ := files.GetItemText(files.GetCurrentItem())
+	key := keyFromLabel(text)
+	setMarked(files, key, !marked[key])
+	if next := files.GetCurrentItem() + 1; next < files.GetItemCount() {
+		files.SetCurrentItem(next)
+	}
+	updateMarkCount()
+}
+
+// markAll marks everything listed, or unmarks it all if it's already marked
+func markAll(files *tview.List) {
+	keys := listedKeys(files)
+	all := true
+	for _, key := range keys {
+		all = all && marked[key]
+	}
+	for _, key := range keys {
+		setMarked(files, key, !all)
+	}
+	updateMarkCount()
+}
+
+func invertMarks(files *tview.List) {
+	for _, key := range listedKeys(files) {
+		setMarked(files, key, !marked[key])
+	}
+	updateMarkCount()
+}
+
+// markGlob marks listed items whose name matches pattern, e.g. *.csv. Folders
+// match on their name without the trailing slash.
+func markGlob(files *tview.List, pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	for _, key := range listedKeys(files) {
+		name := strings.TrimSuffix(key[len(parentPrefix(key)):], "/")
+		if ok, _ := path.Match(pattern, name); ok {
+			setMarked(files, key, true)
+		}
+	}
+	updateMarkCount()
+	return nil
+}
+
+// clearMarks unmarks everything, putting the plain labels back
+func clearMarks() {
+	old := marked
+	marked = map[string]bool{}
+	if fileList != nil {
+		for i := 0; i < fileList.GetItemCount(); i++ {
+			text, _ := fileList.GetItemText(i)
+			if key := keyFromLabel(text); old[key] {
+				fileList.SetItemText(i, fileLabel(key), "")
+			}
+		}
+	}
+	for i, label := range initialFiles {
+		if key := keyFromLabel(label); old[key] {
+			initialFiles[i] = fileLabel(key)
+		}
+	}
+	updateMarkCount()
+}
+
+func updateMarkCount() {
+	if footerView != nil {
+		footerView.SetText(footerText())
+	}
+}
+
+// selection is what a bulk action works on: the marked keys, or the current
+// item if nothing is marked
+func selection(files *tview.List) []string {
+	if len(marked) > 0 {
+		keys := make([]string, 0, len(marked))
+		for key := range marked {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	if files.GetItemCount() == 0 {
+		return nil
+	}
+	text, _ := files.GetItemText(files.GetCurrentItem())
+	if key := keyFromLabel(text); key != ".." {
+		return []string{key}
+	}
+	return nil
+}
+
+// describe names a selection for titles and prompts
+func describe(keys []string) string {
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	return fmt.Sprintf("%d items", len(keys))
+}
+
+// expandedSelection is a selection with folders swapped for everything in
+// them, grouped by the selected item they came from
+type expandedSelection struct {
+	items map[string][]string
+	sizes map[string]int64
+	count int
+	bytes int64
+}
+
+func (e expandedSelection) keys() []string {
+	var keys []string
+	for _, item := range sortedItems(e.items) {
+		keys = append(keys, e.items[item]...)
+	}
+	return keys
+}
+
+// copyJobs places everything under dest, keeping each selected item's name
+// and whatever is below it
+func (e expandedSelection) copyJobs(dest string) []awslib.CopyJob {
+	var jobs []awslib.CopyJob
+	for _, item := range sortedItems(e.items) {
+		parent := parentPrefix(item)
+		for _, key := range e.items[item] {
+			to := dest + key[len(parent):]
+			if to != key {
+				jobs = append(jobs, awslib.CopyJob{From: key, To: to, Size: e.sizes[key]})
+			}
+		}
+	}
+	return jobs
+}
+
+func sortedItems(items map[string][]string) []string {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// expandSelection lists what's under each selected item. Off the ui goroutine.
+func expandSelection(s awslib.ObjectStore, bucket string, keys []string) (expandedSelection, error) {
+	e := expandedSelection{items: map[string][]string{}, sizes: map[string]int64{}}
+	for _, item := range keys {
+		listing, err := s.ListPrefix(bucket, item)
+		if err != nil {
+			return e, err
+		}
+		for i, key := range listing.Keys {
+			e.sizes[key] = listing.Sizes[i]
+		}
+		if strings.HasSuffix(item, "/") {
+			e.items[item] = listing.Keys
+			e.count += len(listing.Keys)
+			e.bytes += listing.Bytes
+			continue
+		}
+		// a file prefix also matches a.txt.bak and friends, only count the file
+		e.items[item] = []string{item}
+		e.count++
+		e.bytes += e.sizes[item]
+	}
+	return e, nil
+}
+
+// countProgress shows how many objects a batch job has got through in the
+// files title
+func countProgress(box *tview.List, verb string, name string) awslib.ProgressFunc {
+	last := int64(-1)
+	a := app
+	return func(done int64, total int64) {
+		pct := int64(utils.Percent(done, total))
+		if atomic.SwapInt64(&last, pct) == pct {
+			return
+		}
+		title := fmt.Sprintf("%s %s %d%% (%d/%d objects)", verb, name, pct, done, total)
+		a.QueueUpdateDraw(func() {
+			box.SetTitle(title)
+		})
+	}
+}
+
+// runBatchJob runs a bulk action in the background with progress in the files
+// title, then clears the marks, relists the folder and reports what failed
+func runBatchJob(s awslib.ObjectStore, files *tview.List, verb string, name string, run func(progress awslib.ProgressFunc) (awslib.BatchSummary, error)) {
+	bucket := bucketName
+	originalTitle := files.GetTitle()
+	progress := countProgress(files, verb, name)
+	a := app
+	go func() {
+		summary, err := run(progress)
+		a.QueueUpdateDraw(func() {
+			files.SetTitle(originalTitle)
+			if bucket == bucketName {
+				clearMarks()
+				clearPreview()
+				refreshFiles(s, files)
+			}
+			if err != nil {
+				reportError(err)
+				return
+			}
+			if len(summary.Failed) > 0 {
+				showBatchSummary(fmt.Sprintf("%s %s in s3://%s", verb, name, bucket), summary)
+			}
+		})
+	}()
+}
+
+// refreshFiles relists the folder being browsed
+func refreshFiles(s awslib.ObjectStore, files *tview.List) {
+	// keys have changed under the search index too
+	forgetIndex()
+	loadFiles(s, files, currentPrefix(), nil)
+}
+
+// showBatchSummary lists the keys that failed and why. title can have keys in
+// it too, so it's escaped along with them.
+func showBatchSummary(title string, summary awslib.BatchSummary) {
+	var failed []string
+	for key, err := range summary.Failed {
+		reason := err.Error()
+		var opErr *awslib.OpError
+		if errors.As(err, &opErr) {
+			reason = opErr.Message()
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", tview.Escape(key), tview.Escape(reason)))
+	}
+	sort.Strings(failed)
+	if len(failed) > maxFailuresShown {
+		failed = append(failed[:maxFailuresShown], "...")
+	}
+	showMessage(fmt.Sprintf("%s: %s\n\nFailed:\n%s", tview.Escape(title), summary, strings.Join(failed, "\n")))
+}
+
+// withExpandedSelection lists the selection in the background then hands it
+// to next on the ui goroutine
+func withExpandedSelection(s awslib.ObjectStore, files *tview.List, keys []string, next func(e expandedSelection)) {
+	bucket := bucketName
+	originalTitle := files.GetTitle()
+	files.SetTitle(fmt.Sprintf("Counting %s...", describe(keys)))
+	a := app
+	go func() {
+		e, err := expandSelection(s, bucket, keys)
+		a.QueueUpdateDraw(func() {
+			files.SetTitle(originalTitle)
+			if err != nil {
+				reportError(err)
+				return
+			}
+			if bucket != bucketName {
+				return
+			}
+			if e.count == 0 {
+				showMessage(fmt.Sprintf("Nothing under s3://%s/%s", bucket, describe(keys)))
+				return
+			}
+			next(e)
+		})
+	}()
+}
+
+// showPrompt puts an input where the footer goes and calls done with the text
+// if enter was pressed
+func showPrompt(label string, text string, done func(text string)) {
+	focus := app.GetFocus()
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetText(text).
+		SetFieldWidth(100)
+	input.SetDoneFunc(func(key tcell.Key) {
+		restoreDefaultGrid(focus)
+		if key == tcell.KeyEnter {
+			done(input.GetText())
+		}
+	})
+	grid := CreateGridWithSearch(bucketList, fileList, previewPane, input)
+	app.SetRoot(grid, true).SetFocus(input)
+}
+
+// showSelectionMenu lists everything that can be done to the selection
+func showSelectionMenu(s awslib.ObjectStore, files *tview.List) {
+	keys := selection(files)
+	if len(keys) == 0 || bucketName == "" {
+		return
+	}
+	focus := app.GetFocus()
+	bucket := bucketName
+	name := describe(keys)
+
+	menu := tview.NewList().ShowSecondaryText(false)
+	menu.SetBorder(true).SetTitle(fmt.Sprintf("%s in s3://%s", name, bucketName)).SetTitleAlign(tview.AlignLeft)
+	menu.SetDoneFunc(func() {
+		restoreDefaultGrid(focus)
+	})
+	action := func(label string, shortcut rune, fn func()) {
+		menu.AddItem(label, "", shortcut, func() {
+			restoreDefaultGrid(focus)
+			fn()
+		})
+	}
+
+	action("Delete", 'd', func() {
+		confirmDelete(s, files, keys)
+	})
+	action("Download to...", 'w', func() {
+		showDownloadInput(s, files, keys)
+	})
+	action("Copy to...", 'c', func() {
+		showCopyPrompt(s, files, keys, false)
+	})
+	action("Move to...", 'm', func() {
+		showCopyPrompt(s, files, keys, true)
+	})
+	action("Set tags...", 't', func() {
+		showPrompt(fmt.Sprintf("Tags for %s (key=value, ...): ", name), "", func(text string) {
+			tags, err := awslib.ParseTags(text)
+			if err != nil {
+				showMessage(err.Error())
+				return
+			}
+			withExpandedSelection(s, files, keys, func(e expandedSelection) {
+				runBatchJob(s, files, "Tagging", name, func(progress awslib.ProgressFunc) (awslib.BatchSummary, error) {
+					return s.TagKeys(bucket, e.keys(), tags, progress)
+				})
+			})
+		})
+	})
+	action("Change storage class...", 's', func() {
+		showStorageClassPicker(s, files, keys)
+	})
+	if len(marked) > 0 {
+		action("Clear marks", 'x', clearMarks)
+	}
+
+	app.SetRoot(centered(menu, 50, menu.GetItemCount()+2), true).SetFocus(menu)
+}
+
+func showCopyPrompt(s awslib.ObjectStore, files *tview.List, keys []string, move bool) {
+	verb, doing := "Copy", "Copying"
+	if move {
+		verb, doing = "Move", "Moving"
+	}
+	bucket := bucketName
+	label := fmt.Sprintf("%s %s to s3://%s/", verb, describe(keys), bucket)
+	showPrompt(label, currentPrefix(), func(dest string) {
+		if dest != "" && !strings.HasSuffix(dest, "/") {
+			dest += "/"
+		}
+		withExpandedSelection(s, files, keys, func(e expandedSelection) {
+			jobs := e.copyJobs(dest)
+			if len(jobs) == 0 {
+				showMessage(fmt.Sprintf("%s is already in s3://%s/%s", describe(keys), bucket, dest))
+				return
+			}
+			runBatchJob(s, files, doing, describe(keys), func(progress awslib.ProgressFunc) (awslib.BatchSummary, error) {
+				if move {
+					return s.MoveKeys(bucket, jobs, progress)
+				}
+				return s.CopyKeys(bucket, jobs, progress)
+			})
+		})
+	})
+}
+
+func showStorageClassPicker(s awslib.ObjectStore, files *tview.List, keys []string) {
+	focus := app.GetFocus()
+	bucket := bucketName
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(fmt.Sprintf("Storage class for %s", describe(keys))).SetTitleAlign(tview.AlignLeft)
+	list.SetDoneFunc(func() {
+		restoreDefaultGrid(focus)
+	})
+	for _, class := range awslib.StorageClasses {
+		class := class
+		list.AddItem(class, "", 0, func() {
+			restoreDefaultGrid(focus)
+			withExpandedSelection(s, files, keys, func(e expandedSelection) {
+				runBatchJob(s, files, "Changing storage class of", describe(keys), func(progress awslib.ProgressFunc) (awslib.BatchSummary, error) {
+					return s.ChangeStorageClass(bucket, e.keys(), e.sizes, class, progress)
+				})
+			})
+		})
+	}
+	app.SetRoot(centered(list, 50, len(awslib.StorageClasses)+2), true).SetFocus(list)
+}
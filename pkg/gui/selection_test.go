@@ -0,0 +1,169 @@
+package gui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func TestMarkAndDeleteSelection(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	// space marks and moves on, so this marks frozen.bin then top.txt
+	press(tcell.KeyDown)
+	typeText("  ")
+	waitFor(t, screen, "✔ top.txt")
+	waitFor(t, screen, "✔ frozen.bin")
+	waitFor(t, screen, "2 marked")
+
+	press(tcell.KeyCtrlD)
+	waitFor(t, screen, "Delete 2 items from s3://alpha?")
+	// "cold" and "hello from the top"
+	waitFor(t, screen, "2 objects, 22 B")
+	press(tcell.KeyTab, tcell.KeyEnter)
+	waitForGone(t, screen, "top.txt")
+	waitForGone(t, screen, "2 marked")
+
+	for _, key := range []string{"top.txt", "frozen.bin"} {
+		if _, ok := store.Get("alpha", key); ok {
+			t.Errorf("%s still exists", key)
+		}
+	}
+	if _, ok := store.Get("alpha", "docs/readme.md"); !ok {
+		t.Error("docs/ wasn't marked but was deleted")
+	}
+}
+
+func TestMarkAllAndInvert(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyCtrlA)
+	waitFor(t, screen, "3 marked")
+	waitFor(t, screen, "✔ docs/")
+
+	// again unmarks
+	press(tcell.KeyCtrlA)
+	waitForGone(t, screen, "marked")
+
+	typeText(" *")
+	waitFor(t, screen, "2 marked")
+	waitFor(t, screen, "✔ frozen.bin")
+	waitForGone(t, screen, "✔ docs/")
+}
+
+func TestMarkMatchingAndCopy(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	typeText("+")
+	waitFor(t, screen, "Mark matching:")
+	typeText("*.txt")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "✔ top.txt")
+	waitFor(t, screen, "1 marked")
+
+	press(tcell.KeyCtrlO)
+	waitFor(t, screen, "Copy to...")
+	typeText("c")
+	waitFor(t, screen, "Copy top.txt to s3://alpha/")
+	typeText("backup")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "backup/")
+	waitForGone(t, screen, "1 marked")
+
+	if data, ok := store.Get("alpha", "backup/top.txt"); !ok || string(data) != "hello from the top" {
+		t.Errorf("backup/top.txt = %q, %v", data, ok)
+	}
+	if _, ok := store.Get("alpha", "top.txt"); !ok {
+		t.Error("copying removed the original")
+	}
+}
+
+func TestChangeStorageClassOfFolder(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/")
+	// nothing marked, so the menu works on the current item
+	press(tcell.KeyCtrlO)
+	waitFor(t, screen, "docs/ in s3://alpha")
+	typeText("s")
+	waitFor(t, screen, "Storage class for docs/")
+	press(tcell.KeyDown, tcell.KeyEnter)
+
+	eventually(t, "docs/deep/nested.txt to change class", func() bool {
+		return store.StorageClass("alpha", "docs/deep/nested.txt") == "STANDARD_IA"
+	})
+	if got := store.StorageClass("alpha", "docs/readme.md"); got != "STANDARD_IA" {
+		t.Errorf("docs/readme.md is %q", got)
+	}
+	if got := store.StorageClass("alpha", "top.txt"); got == "STANDARD_IA" {
+		t.Error("top.txt wasn't selected")
+	}
+}
+
+func TestKeysWithBracketsShowAsTheyAre(t *testing.T) {
+	store := newTestStore()
+	store.Put("alpha", "logs[2024].txt", []byte("twenty twenty four"))
+	store.Put("alpha", "[red]alert.txt", []byte("not red"))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "logs[2024].txt")
+	waitFor(t, screen, "[red]alert.txt")
+
+	// marked they still show as they are
+	press(tcell.KeyCtrlA)
+	waitFor(t, screen, "✔ logs[2024].txt")
+	waitFor(t, screen, "✔ [red]alert.txt")
+	press(tcell.KeyCtrlA)
+	waitForGone(t, screen, "marked")
+
+	// and can be searched for and opened
+	typeText("/")
+	waitFor(t, screen, "Tab to change):")
+	typeText("2024")
+	waitForGone(t, screen, "top.txt")
+	waitFor(t, screen, "logs[2024].txt")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "twenty twenty four")
+}
+
+func TestPreviewKeysEscapesTags(t *testing.T) {
+	keys := []string{"logs/[red]a.txt", "logs/b.txt"}
+	if got, want := previewKeys(keys), "logs/[red[]a.txt\nlogs/b.txt"; got != want {
+		t.Errorf("previewKeys = %q, want %q", got, want)
+	}
+	var many []string
+	for i := 0; i < deletePreviewKeys+2; i++ {
+		many = append(many, "[x]")
+	}
+	if got := previewKeys(many); !strings.HasSuffix(got, "[x[]\n... and 2 more") {
+		t.Errorf("previewKeys = %q", got)
+	}
+}
+
+func TestBatchSummaryShowsKeysAsTheyAre(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	waitFor(t, screen, "alpha (us-east-1)")
+
+	app.QueueUpdateDraw(func() {
+		showBatchSummary("Deleting [red]a.txt in s3://alpha", awslib.BatchSummary{
+			Failed: map[string]error{"logs/[red]a.txt": errors.New("access [denied]")},
+		})
+	})
+	waitFor(t, screen, "Deleting [red]a.txt in s3://alpha")
+	waitFor(t, screen, "logs/[red]a.txt: access [denied]")
+}
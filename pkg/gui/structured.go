@@ -0,0 +1,448 @@
+package gui
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// previewFormat shows text in the preview as something easier to read than
+// how it's stored, r flips back to the raw text
+type previewFormat struct {
+	name string
+	// render turns text into tview markup a line at a time, along with where in
+	// text each line ends
+	render func(text string) ([]string, []int)
+	// table shows it in the table instead, with no render
+	table bool
+}
+
+const (
+	keyColour     = "[aqua]"
+	stringColour  = "[green]"
+	numberColour  = "[orange]"
+	keywordColour = "[fuchsia]"
+	attrColour    = "[yellow]"
+	commentColour = "[gray]"
+)
+
+// paint escapes s and colours it in
+func paint(colour string, s string) string {
+	if s == "" {
+		return ""
+	}
+	return colour + tview.Escape(s) + "[-]"
+}
+
+// byLine renders text a line at a time, for formats that don't move lines around
+func byLine(text string, colour func(line string) string) ([]string, []int) {
+	var lines []string
+	var ends []int
+	for i := 0; i < len(text); {
+		end := strings.IndexByte(text[i:], '\n')
+		next := i + end + 1
+		if end < 0 {
+			end = len(text) - i
+			next = len(text)
+		}
+		lines = append(lines, colour(strings.TrimSuffix(text[i:i+end], "\r")))
+		ends = append(ends, next)
+		i = next
+	}
+	return lines, ends
+}
+
+var jsonFormat = &previewFormat{name: "json", render: func(text string) ([]string, []int) {
+	return formatJSON(text, true)
+}}
+
+// ndjsonFormat is a record a line, each coloured in like json
+var ndjsonFormat = &previewFormat{name: "ndjson", render: func(text string) ([]string, []int) {
+	return byLine(text, func(line string) string {
+		lines, _ := formatJSON(line, false)
+		return strings.Join(lines, "")
+	})
+}}
+
+// formatJSON colours JSON in, indenting it two spaces a level when pretty. It
+// works a token at a time rather than parsing, so a document that's been cut
+// off part way, which it usually is when only the start has been read, shows
+// as far as it goes.
+func formatJSON(text string, pretty bool) ([]string, []int) {
+	var lines []string
+	var ends []int
+	var b strings.Builder
+	depth := 0
+	// nothing but indenting on the current line yet
+	fresh := true
+	newline := func(at int) {
+		if !pretty {
+			return
+		}
+		if !fresh {
+			lines = append(lines, b.String())
+			ends = append(ends, at)
+		}
+		b.Reset()
+		b.WriteString(strings.Repeat("  ", depth))
+		fresh = true
+	}
+	write := func(s string) {
+		b.WriteString(s)
+		fresh = false
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '{' || c == '[':
+			i++
+			// empty objects and arrays stay on one line
+			j := i
+			for j < len(text) && strings.IndexByte(" \t\r\n", text[j]) >= 0 {
+				j++
+			}
+			if j < len(text) && (text[j] == '}' || text[j] == ']') {
+				write(tview.Escape(string(c) + string(text[j])))
+				i = j + 1
+				continue
+			}
+			write(tview.Escape(string(c)))
+			depth++
+			newline(i)
+		case c == '}' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+			newline(i)
+			write(tview.Escape(string(c)))
+			i++
+		case c == ',':
+			i++
+			if pretty {
+				write(",")
+				newline(i)
+			} else {
+				write(", ")
+			}
+		case c == ':':
+			write(": ")
+			i++
+		case c == '"':
+			j := endOfQuote(text, i)
+			// a string followed by a colon is a key
+			k := j
+			for k < len(text) && (text[k] == ' ' || text[k] == '\t') {
+				k++
+			}
+			colour := stringColour
+			if k < len(text) && text[k] == ':' {
+				colour = keyColour
+			}
+			write(paint(colour, text[i:j]))
+			i = j
+		default:
+			j := i
+			for j < len(text) && strings.IndexByte(" \t\r\n,:[]{}\"", text[j]) < 0 {
+				j++
+			}
+			word := text[i:j]
+			switch {
+			case word == "true" || word == "false" || word == "null":
+				write(paint(keywordColour, word))
+			case c == '-' || ('0' <= c && c <= '9'):
+				write(paint(numberColour, word))
+			default:
+				write(tview.Escape(word))
+			}
+			i = j
+		}
+	}
+	if !fresh || len(lines) == 0 {
+		lines = append(lines, b.String())
+		ends = append(ends, len(text))
+	} else {
+		ends[len(ends)-1] = len(text)
+	}
+	return lines, ends
+}
+
+// endOfQuote is just past the quote closing the string that starts at i, or the
+// end of the line if it isn't closed
+func endOfQuote(text string, i int) int {
+	quote := text[i]
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			return j
+		}
+	}
+	return len(text)
+}
+
+// syntax is enough about a language to colour it in a line at a time
+type syntax struct {
+	name         string
+	lineComments []string
+	blockComment [2]string // how they open and close, empty for none
+	keywords     map[string]bool
+	// config files colour in the key at the start of a line...
+	key *regexp.Regexp
+	// ...and the [section] headers in toml
+	section *regexp.Regexp
+}
+
+func (s *syntax) format() *previewFormat {
+	return &previewFormat{name: s.name, render: func(text string) ([]string, []int) {
+		inComment := false
+		return byLine(text, func(line string) string {
+			return s.colour(line, &inComment)
+		})
+	}}
+}
+
+// colour colours in a line. inComment is whether a block comment is still open
+// from an earlier line, and is updated for the next one.
+func (s *syntax) colour(line string, inComment *bool) string {
+	var b strings.Builder
+	i := 0
+	if !*inComment {
+		if s.section != nil && s.section.MatchString(line) {
+			return paint(keyColour, line)
+		}
+		if s.key != nil {
+			if m := s.key.FindStringSubmatchIndex(line); m != nil {
+				b.WriteString(tview.Escape(line[:m[2]]))
+				b.WriteString(paint(keyColour, line[m[2]:m[3]]))
+				i = m[3]
+			}
+		}
+	}
+	// plain text waiting to be written, from here up to i
+	plain := i
+	for i < len(line) {
+		c := line[i]
+		j, colour := i+1, ""
+		switch {
+		case *inComment:
+			colour, j = commentColour, len(line)
+			if end := strings.Index(line[i:], s.blockComment[1]); end >= 0 {
+				j = i + end + len(s.blockComment[1])
+				*inComment = false
+			}
+		case s.blockComment[0] != "" && strings.HasPrefix(line[i:], s.blockComment[0]):
+			*inComment = true
+			colour, j = commentColour, len(line)
+			rest := i + len(s.blockComment[0])
+			if end := strings.Index(line[rest:], s.blockComment[1]); end >= 0 {
+				j = rest + end + len(s.blockComment[1])
+				*inComment = false
+			}
+		case s.lineComment(line, i):
+			colour, j = commentColour, len(line)
+		case c == '"' || c == '`' || (c == '\'' && (i == 0 || !isWordByte(line[i-1]))):
+			colour, j = stringColour, endOfQuote(line, i)
+		case '0' <= c && c <= '9' && (i == 0 || !isWordByte(line[i-1])):
+			for j < len(line) && (isWordByte(line[j]) || line[j] == '.') {
+				j++
+			}
+			colour = numberColour
+		case isWordByte(c):
+			for j < len(line) && isWordByte(line[j]) {
+				j++
+			}
+			if s.keywords[line[i:j]] {
+				colour = keywordColour
+			}
+		}
+		if colour != "" {
+			b.WriteString(tview.Escape(line[plain:i]))
+			b.WriteString(paint(colour, line[i:j]))
+			plain = j
+		}
+		i = j
+	}
+	b.WriteString(tview.Escape(line[plain:]))
+	return b.String()
+}
+
+// lineComment says whether a comment starts at i. # only counts at the start of
+// a word, so it's not mistaken for part of a url or a colour.
+func (s *syntax) lineComment(line string, i int) bool {
+	for _, start := range s.lineComments {
+		if !strings.HasPrefix(line[i:], start) {
+			continue
+		}
+		if start != "#" || i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func keywords(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// sourceKeywords is a bit of everything, it's only for colouring in
+var sourceKeywords = keywords(`break case catch class const continue def default defer delete do elif else
+	enum except export extends false finally fn for from func function go if impl import in interface
+	lambda let loop match module mut new nil none None null package pass private protected pub public
+	raise return self static struct super switch this throw true True False try type undefined use var
+	void while with yield async await select chan map range fallthrough goto`)
+
+var (
+	cSyntax     = &syntax{name: "source", lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, keywords: sourceKeywords}
+	hashSyntax  = &syntax{name: "source", lineComments: []string{"#"}, keywords: sourceKeywords}
+	dashSyntax  = &syntax{name: "source", lineComments: []string{"--"}, blockComment: [2]string{"/*", "*/"}, keywords: keywords(sqlKeywords)}
+	yamlSyntax  = &syntax{name: "yaml", lineComments: []string{"#"}, keywords: keywords("true false yes no on off null"), key: regexp.MustCompile(`^\s*(?:- +)?([^\s#:'"][^#:]*?|"[^"]*"|'[^']*')\s*:(?:\s|$)`)}
+	tomlSyntax  = &syntax{name: "toml", lineComments: []string{"#"}, keywords: keywords("true false"), key: regexp.MustCompile(`^\s*([A-Za-z0-9_.\-"']+)\s*=`), section: regexp.MustCompile(`^\s*\[.*\]\s*(#.*)?$`)}
+	sqlKeywords = `select from where and or not insert into values update set delete create table drop alter
+		join left right inner outer on group by order having limit as distinct union all null is in like
+		SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER JOIN LEFT
+		RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS DISTINCT UNION ALL NULL IS IN LIKE`
+)
+
+// xmlFormat colours in tags, attributes and comments
+var xmlFormat = &previewFormat{name: "xml", render: func(text string) ([]string, []int) {
+	inTag, inComment := false, false
+	return byLine(text, func(line string) string {
+		return colourXML(line, &inTag, &inComment)
+	})
+}}
+
+func colourXML(line string, inTag *bool, inComment *bool) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		j := i + 1
+		switch {
+		case *inComment:
+			j = len(line)
+			if end := strings.Index(line[i:], "-->"); end >= 0 {
+				j = i + end + 3
+				*inComment = false
+			}
+			b.WriteString(paint(commentColour, line[i:j]))
+		case strings.HasPrefix(line[i:], "<!--"):
+			*inComment = true
+			continue
+		case !*inTag && line[i] == '<':
+			// the name, with any / ? or ! in front of it
+			if j < len(line) && strings.IndexByte("/?!", line[j]) >= 0 {
+				j++
+			}
+			for j < len(line) && strings.IndexByte(" \t>/?", line[j]) < 0 {
+				j++
+			}
+			b.WriteString(paint(keyColour, line[i:j]))
+			*inTag = true
+		case !*inTag:
+			if next := strings.IndexByte(line[i:], '<'); next >= 0 {
+				j = i + next
+			} else {
+				j = len(line)
+			}
+			b.WriteString(tview.Escape(line[i:j]))
+		case line[i] == '>' || strings.HasPrefix(line[i:], "/>") || strings.HasPrefix(line[i:], "?>"):
+			if line[i] != '>' {
+				j++
+			}
+			b.WriteString(paint(keyColour, line[i:j]))
+			*inTag = false
+		case line[i] == '"' || line[i] == '\'':
+			j = endOfQuote(line, i)
+			b.WriteString(paint(stringColour, line[i:j]))
+		case isWordByte(line[i]):
+			for j < len(line) && (isWordByte(line[j]) || strings.IndexByte(":-.", line[j]) >= 0) {
+				j++
+			}
+			b.WriteString(paint(attrColour, line[i:j]))
+		default:
+			b.WriteString(tview.Escape(line[i:j]))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// formatsByExt picks a format from a key's extension
+var formatsByExt = map[string]*previewFormat{
+	".json": jsonFormat, ".geojson": jsonFormat, ".ipynb": jsonFormat,
+	".ndjson": ndjsonFormat, ".jsonl": ndjsonFormat,
+	".yaml": yamlSyntax.format(), ".yml": yamlSyntax.format(),
+	".toml": tomlSyntax.format(),
+	".xml":  xmlFormat, ".html": xmlFormat, ".htm": xmlFormat, ".svg": xmlFormat, ".xsd": xmlFormat, ".pom": xmlFormat,
+	".sql": dashSyntax.format(), ".lua": dashSyntax.format(),
+	".csv": csvFormat, ".tsv": csvFormat, ".tab": csvFormat, ".psv": csvFormat,
+}
+
+func init() {
+	for _, ext := range strings.Fields(".go .js .mjs .ts .tsx .jsx .java .kt .scala .c .h .cc .cpp .hpp .cs .rs .swift .php .tf .hcl .proto .css .scss") {
+		formatsByExt[ext] = cSyntax.format()
+	}
+	for _, ext := range strings.Fields(".py .rb .sh .bash .zsh .pl .r .conf .ini .cfg .properties .env .dockerfile") {
+		formatsByExt[ext] = hashSyntax.format()
+	}
+}
+
+// formatsByType picks a format from a Content-Type, for keys without a useful
+// extension
+var formatsByType = map[string]*previewFormat{
+	"application/json": jsonFormat, "text/json": jsonFormat,
+	"application/x-ndjson": ndjsonFormat, "application/jsonl": ndjsonFormat, "application/x-jsonlines": ndjsonFormat,
+	"application/yaml": yamlSyntax.format(), "application/x-yaml": yamlSyntax.format(), "text/yaml": yamlSyntax.format(),
+	"application/toml": tomlSyntax.format(),
+	"application/xml":  xmlFormat, "text/xml": xmlFormat, "text/html": xmlFormat,
+	"text/csv": csvFormat, "application/csv": csvFormat, "text/tab-separated-values": csvFormat,
+}
+
+// formatFor works out how to show key, going by its extension, then its
+// Content-Type, then what head, the start of it, looks like. It's nil for
+// plain text.
+func formatFor(key string, contentType string, head []byte) *previewFormat {
+	if f, ok := formatsByExt[strings.ToLower(path.Ext(key))]; ok {
+		return f
+	}
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		if f, ok := formatsByType[t]; ok {
+			return f
+		}
+		if strings.HasSuffix(t, "+json") {
+			return jsonFormat
+		}
+		if strings.HasSuffix(t, "+xml") {
+			return xmlFormat
+		}
+	}
+	text := strings.TrimSpace(string(head))
+	switch {
+	case strings.HasPrefix(text, "<?xml"):
+		return xmlFormat
+	case strings.HasPrefix(text, "{") || strings.HasPrefix(text, "["):
+		// a whole object on the first line and another after it is ndjson
+		first, rest, more := strings.Cut(text, "\n")
+		if more && json.Valid([]byte(first)) && strings.HasPrefix(strings.TrimSpace(rest), "{") {
+			return ndjsonFormat
+		}
+		return jsonFormat
+	}
+	return nil
+}
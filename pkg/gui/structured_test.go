@@ -0,0 +1,121 @@
+package gui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestFormatJSON(t *testing.T) {
+	// cut off part way through, as it is when only the start has been read
+	text := `{"name": "a[b]", "tags": [], "n": -1.5, "ok": true, "deep": {"x": [1, 2`
+	lines, ends := formatJSON(text, true)
+	want := []string{
+		`{`,
+		`  [aqua]"name"[-]: [green]"a[b[]"[-],`,
+		`  [aqua]"tags"[-]: [],`,
+		`  [aqua]"n"[-]: [orange]-1.5[-],`,
+		`  [aqua]"ok"[-]: [fuchsia]true[-],`,
+		`  [aqua]"deep"[-]: {`,
+		`    [aqua]"x"[-]: [`,
+		`      [orange]1[-],`,
+		`      [orange]2[-]`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	// each line ends where the next one's text starts
+	if ends[0] != 1 || ends[1] != len(`{"name": "a[b]",`) || ends[len(ends)-1] != len(text) {
+		t.Errorf("ends = %v", ends)
+	}
+
+	lines, _ = formatJSON(`{"a":1,"b":[null,"x"]}`, false)
+	if len(lines) != 1 || lines[0] != `{[aqua]"a"[-]: [orange]1[-], [aqua]"b"[-]: [[fuchsia]null[-], [green]"x"[-]]}` {
+		t.Errorf("compact = %q", lines)
+	}
+}
+
+func TestSyntaxColours(t *testing.T) {
+	tests := []struct {
+		format *previewFormat
+		text   string
+		want   []string
+	}{
+		{yamlSyntax.format(), "# top\nname: web # the app\nports:\n  - 80\n  - name: 'x'\n", []string{
+			"[gray]# top[-]", "[aqua]name[-]: web [gray]# the app[-]", "[aqua]ports[-]:", "  - [orange]80[-]", "  - [aqua]name[-]: [green]'x'[-]",
+		}},
+		{tomlSyntax.format(), "[server]\nport = 8080 # http\nurl = \"http://x/#top\"", []string{
+			"[aqua][server[][-]", "[aqua]port[-] = [orange]8080[-] [gray]# http[-]", `[aqua]url[-] = [green]"http://x/#top"[-]`,
+		}},
+		{cSyntax.format(), "/* a\nb */ func main() { return 42 } // done", []string{
+			"[gray]/* a[-]", "[gray]b */[-] [fuchsia]func[-] main() { [fuchsia]return[-] [orange]42[-] } [gray]// done[-]",
+		}},
+		{xmlFormat, "<?xml version=\"1.0\"?>\n<a href=\"x\">text<!-- c\nmore --></a>", []string{
+			`[aqua]<?xml[-] [yellow]version[-]=[green]"1.0"[-][aqua]?>[-]`,
+			`[aqua]<a[-] [yellow]href[-]=[green]"x"[-][aqua]>[-]text[gray]<!-- c[-]`,
+			`[gray]more -->[-][aqua]</a[-][aqua]>[-]`,
+		}},
+	}
+	for _, tt := range tests {
+		lines, ends := tt.format.render(tt.text)
+		if !reflect.DeepEqual(lines, tt.want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.format.name, strings.Join(lines, "\n"), strings.Join(tt.want, "\n"))
+		}
+		if len(ends) != len(lines) || ends[len(ends)-1] != len(tt.text) {
+			t.Errorf("%s: ends = %v", tt.format.name, ends)
+		}
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := []struct {
+		key, contentType, head string
+		want                   string
+	}{
+		{"a.JSON", "", "", "json"},
+		{"events.jsonl", "application/json", "", "ndjson"},
+		{"values.yml", "", "", "yaml"},
+		{"main.go", "", "", "source"},
+		{"query.sql", "", "", "source"},
+		{"blob", "application/ld+json; charset=utf-8", "", "json"},
+		{"feed", "application/rss+xml", "", "xml"},
+		{"noext", "binary/octet-stream", "  [1, 2]", "json"},
+		{"noext", "", "{\"a\": 1}\n{\"a\": 2}\n", "ndjson"},
+		{"noext", "", "<?xml version=\"1.0\"?><a/>", "xml"},
+		{"notes.txt", "text/plain", "just text", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if f := formatFor(tt.key, tt.contentType, []byte(tt.head)); f != nil {
+			got = f.name
+		}
+		if got != tt.want {
+			t.Errorf("formatFor(%q, %q, %q) = %q, want %q", tt.key, tt.contentType, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewPrettyJSON(t *testing.T) {
+	store := newTestStore()
+	store.Put("alpha", "config.json", []byte(`{"name":"web","ports":[80,443],"empty":[]}`))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "config.json")
+	press(tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, `"name": "web",`)
+	waitFor(t, screen, `"empty": []`)
+	waitFor(t, screen, "Preview <Ctrl+p> - json - 100%")
+	if c := colourOf(screen, `"name"`); c != tcell.ColorAqua {
+		t.Errorf("key is %v, want aqua", c)
+	}
+
+	press(tcell.KeyCtrlP)
+	typeText("r")
+	waitFor(t, screen, `{"name":"web","ports":[80,443],"empty":[]}`)
+	waitFor(t, screen, "Preview <Ctrl+p> - json raw")
+	typeText("r")
+	waitFor(t, screen, `"name": "web",`)
+}
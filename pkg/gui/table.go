@@ -0,0 +1,266 @@
+package gui
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// csvFormat shows delimited text as a table rather than as text. What it's
+// delimited by is worked out from the first lines.
+var csvFormat = &previewFormat{name: "csv", table: true}
+
+// cellWidth is as wide as a column in the table gets, longer values are cut
+// short and the whole thing is there with r
+const cellWidth = 32
+
+var (
+	// previewTable takes the preview's place for csv, previewSlot is what's in
+	// the grid and swaps one for the other
+	previewTable *tview.Table
+	previewSlot  *tview.Pages
+)
+
+// delimiters are what sniffDelimiter picks from, and what the title calls a
+// table split on each
+var delimiters = []struct {
+	comma rune
+	name  string
+}{
+	{',', "csv"},
+	{'\t', "tsv"},
+	{';', "csv ;"},
+	{'|', "csv |"},
+}
+
+// previewArea is what goes in the grid where preview does
+func previewArea(preview *tview.TextView) tview.Primitive {
+	if preview == previewPane && previewSlot != nil {
+		return previewSlot
+	}
+	return preview
+}
+
+// showPreviewPage brings "text" or "table" to the front of the preview, taking
+// focus along if the other one had it
+func showPreviewPage(name string) {
+	if front, _ := previewSlot.GetFrontPage(); front == name {
+		return
+	}
+	focused := app.GetFocus()
+	previewSlot.SwitchToPage(name)
+	switch {
+	case focused != previewPane && focused != previewTable:
+	case name == "table":
+		app.SetFocus(previewTable)
+	default:
+		app.SetFocus(previewPane)
+	}
+}
+
+// previewBorder colours the preview's border, whichever of text or table is
+// showing
+func previewBorder(colour tcell.Color) {
+	previewPane.SetBorderColor(colour)
+	previewTable.SetBorderColor(colour)
+}
+
+// sniffDelimiter guesses what the fields in text are split on. The one that
+// splits the most lines into as many fields as the first line wins, and ties
+// go to whichever makes more fields.
+func sniffDelimiter(text string) rune {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 1 {
+		// the last line is empty or cut off
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+	best, bestLines, bestFields := ',', 0, 0
+	for _, d := range delimiters {
+		fields := fieldsIn(lines[0], d.comma)
+		if fields < 2 {
+			continue
+		}
+		same := 0
+		for _, line := range lines {
+			if fieldsIn(line, d.comma) == fields {
+				same++
+			}
+		}
+		if same > bestLines || same == bestLines && fields > bestFields {
+			best, bestLines, bestFields = d.comma, same, fields
+		}
+	}
+	return best
+}
+
+// fieldsIn counts the fields in line split on comma, leaving quoted ones whole
+func fieldsIn(line string, comma rune) int {
+	fields, quoted := 1, false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == comma && !quoted:
+			fields++
+		}
+	}
+	return fields
+}
+
+// parseTable splits text into records, along with where in text each one
+// ends. It's forgiving about quotes and ragged rows since the preview only has
+// part of the file.
+func parseTable(text string, comma rune) ([][]string, []int) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = comma
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	var records [][]string
+	var ends []int
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && record == nil {
+			break
+		}
+		records = append(records, record)
+		ends = append(ends, int(r.InputOffset()))
+	}
+	return records, ends
+}
+
+// tabled is whether the preview is showing the table rather than the text
+func (p *filePager) tabled() bool {
+	return p.format != nil && p.format.table && !p.raw && !p.hex
+}
+
+// showTable fills the table with the whole records in text, the table's half
+// of show. The header is kept from the first page, since after a jump to the
+// end it's no longer loaded.
+func (p *filePager) showTable() {
+	from, to := 0, len(p.text)
+	if p.start > 0 {
+		// the first line is most likely cut short
+		from = strings.IndexByte(p.text, '\n') + 1
+	}
+	if p.end() < p.size {
+		to = strings.LastIndexByte(p.text, '\n') + 1
+	}
+	if to < from {
+		to = from
+	}
+	if p.delim == 0 && to > from {
+		p.delim = sniffDelimiter(p.text[from:to])
+	}
+	var records [][]string
+	var ends []int
+	if p.delim != 0 {
+		records, ends = parseTable(p.text[from:to], p.delim)
+	}
+	p.linesFrom = p.textFrom + from
+	if p.start == 0 && len(records) > 0 {
+		p.header, records = records[0], records[1:]
+		p.headerSize = ends[0]
+		p.linesFrom = p.textFrom + from + ends[0]
+		ends = ends[1:]
+	}
+	for _, end := range ends {
+		p.lineEnds = append(p.lineEnds, p.textFrom+from+end)
+	}
+
+	fillTable(p.header, records, p.highlight)
+	showPreviewPage("table")
+}
+
+// fillTable puts rows in the table under header, running each value through
+// escape, and keeps the same row selected
+func fillTable(header []string, rows [][]string, escape func(string) string) {
+	row, column := previewTable.GetSelection()
+	previewTable.Clear()
+	for i, name := range header {
+		previewTable.SetCell(0, i, tview.NewTableCell(tview.Escape(name)).
+			SetTextColor(tcell.ColorYellow).
+			SetAttributes(tcell.AttrBold).
+			SetMaxWidth(cellWidth).
+			SetSelectable(false))
+	}
+	for i, record := range rows {
+		for j, value := range record {
+			value = strings.ReplaceAll(value, "\n", " ")
+			previewTable.SetCell(i+1, j, tview.NewTableCell(escape(value)).SetMaxWidth(cellWidth))
+		}
+	}
+	if row >= previewTable.GetRowCount() {
+		row = previewTable.GetRowCount() - 1
+	}
+	if row < 1 {
+		row = 1
+	}
+	previewTable.Select(row, column)
+}
+
+// highlight escapes a value in the table, picking out the last search in it
+func (p *filePager) highlight(value string) string {
+	if p.pattern == nil {
+		return tview.Escape(value)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range p.pattern.FindAllStringIndex(value, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(tview.Escape(value[last:loc[0]]))
+		b.WriteString("[black:yellow]" + tview.Escape(value[loc[0]:loc[1]]) + "[-:-]")
+		last = loc[1]
+	}
+	b.WriteString(tview.Escape(value[last:]))
+	return b.String()
+}
+
+// rowPosition is which row of the object is selected in the table, out of how
+// many. Unless the whole object has been read they're guesses, going by how
+// many bytes the rows read so far take up.
+func (p *filePager) rowPosition() string {
+	rows := len(p.lineEnds)
+	if rows == 0 {
+		return "no rows yet"
+	}
+	row, _ := previewTable.GetSelection()
+	if row < 1 {
+		row = 1
+	}
+	perRow := float64(p.lineEnds[rows-1]-p.linesFrom) / float64(rows)
+	before := int(math.Round(float64(p.start+int64(p.linesFrom)-int64(p.headerSize)) / perRow))
+	after := int(math.Round(float64(p.size-p.start-int64(p.lineEnds[rows-1])) / perRow))
+	about, aboutTotal := "", ""
+	if p.start == 0 {
+		before = 0
+	} else {
+		about = "~"
+	}
+	if p.start > 0 || p.end() < p.size {
+		aboutTotal = "~"
+	}
+	return fmt.Sprintf("row %s%d of %s%d", about, before+row, aboutTotal, before+rows+after)
+}
+
+// delimName is what the title calls the table
+func (p *filePager) delimName() string {
+	for _, d := range delimiters {
+		if d.comma == p.delim {
+			return d.name
+		}
+	}
+	return p.format.name
+}
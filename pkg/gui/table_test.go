@@ -0,0 +1,117 @@
+package gui
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		text string
+		want rune
+	}{
+		{"a,b,c\n1,2,3\n", ','},
+		{"a\tb\tc\n1\t2,5\t3\n", '\t'},
+		{"name;price\n\"a;b\";1,5\nc;2,0\n", ';'},
+		{"a|b\n1|2\n3|4", '|'},
+		// the last line is cut off, so it doesn't count against commas
+		{"x,y\n1,2\n3", ','},
+		{"just one column\nof text\n", ','},
+	}
+	for _, tt := range tests {
+		if got := sniffDelimiter(tt.text); got != tt.want {
+			t.Errorf("sniffDelimiter(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	text := "a,b\n\"x, \"\"y\"\"\",2\n\"two\nlines\",3,extra\n"
+	records, ends := parseTable(text, ',')
+	want := [][]string{{"a", "b"}, {`x, "y"`, "2"}, {"two\nlines", "3", "extra"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q", records)
+	}
+	if !reflect.DeepEqual(ends, []int{4, 17, len(text)}) {
+		t.Errorf("ends = %v", ends)
+	}
+}
+
+// startTable opens people.csv, 300 rows split on semicolons, in the preview a
+// small page at a time
+func startTable(t *testing.T) tcell.SimulationScreen {
+	old := pageBytes
+	pageBytes = 500
+	t.Cleanup(func() { pageBytes = old })
+
+	store := newTestStore()
+	var b strings.Builder
+	b.WriteString("ident;name;city;notes;zone\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&b, "%04d;person %03d;town %03d;%s;z%03d\n", i, i, i, strings.Repeat("n", 40), i)
+	}
+	store.Put("alpha", "people.csv", []byte(b.String()))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "people.csv")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "person 000")
+	press(tcell.KeyCtrlP)
+	return screen
+}
+
+func TestPreviewCSVTable(t *testing.T) {
+	screen := startTable(t)
+
+	// the header is kept apart from the rows, which lose their delimiters
+	waitFor(t, screen, "ident")
+	if colourOf(screen, "ident") != tcell.ColorYellow {
+		t.Errorf("header isn't picked out")
+	}
+	waitForGone(t, screen, "0000;person")
+	// long values are cut short
+	waitFor(t, screen, strings.Repeat("n", cellWidth-1)+"…")
+	waitFor(t, screen, "Preview <Ctrl+p> - csv ; - row 1 of ~300")
+
+	// columns scroll sideways
+	press(tcell.KeyRight, tcell.KeyRight)
+	waitForGone(t, screen, "ident")
+	waitFor(t, screen, "z000")
+
+	// scrolling down reads on
+	pressUntil(t, screen, "person 040", tcell.KeyPgDn, func(text string) bool {
+		return numberAfter(text, "person ") >= 40
+	})
+
+	// the end is a guess at how many rows there are
+	pressUntil(t, screen, "person 299", tcell.KeyEnd, func(text string) bool {
+		return strings.Contains(text, "person 299")
+	})
+	waitFor(t, screen, "row ~300 of ~300")
+
+	// and r shows the text as it is
+	typeText("r")
+	waitFor(t, screen, "0299;person 299")
+	waitFor(t, screen, "csv raw")
+	typeText("r")
+	waitForGone(t, screen, "0299;person 299")
+}
+
+func TestPreviewCSVSearch(t *testing.T) {
+	screen := startTable(t)
+	waitFor(t, screen, "ident")
+
+	typeText("/")
+	waitFor(t, screen, "Find in preview")
+	typeText("town 120")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "person 120")
+	eventually(t, "town 120 highlighted", func() bool { return colourOf(screen, "town 120") == tcell.ColorBlack })
+	// the start has been let go of by now, so which row it is is a guess too
+	waitFor(t, screen, "row ~121 of ~300")
+}
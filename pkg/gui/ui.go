@@ -1,21 +1,24 @@
 package gui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
 	"github.com/rogep/s3-tui/pkg/awslib"
-	"github.com/rogep/s3-tui/pkg/utils"
 )
 
 var (
-	app            *tview.Application
+	app *tview.Application
+	// appCtx is cancelled once app has stopped, so work in the background gives
+	// up rather than queueing updates nothing will run
+	appCtx         context.Context
+	stopApp        context.CancelFunc
 	bucketName     string
 	envName        string
 	currentFocus   string // allows refocusing when exiting forms/new app state
@@ -23,6 +26,13 @@ var (
 	initialBuckets []string // used for fuzzy finding as we clear the bucket list and lose state
 	initialFiles   []string // used for fuzzy finding as we clear the files list and lose state
 	targetIndex    int
+	identityText   string // who the credentials belong to, shown after envName
+	footerView     *tview.TextView
+
+	// the three panes, for putting the default grid back from anywhere
+	bucketList  *tview.List
+	fileList    *tview.List
+	previewPane *tview.TextView
 )
 
 func CreateGridWithSearch(buckets *tview.List, files *tview.List, preview *tview.TextView, footer *tview.InputField) *tview.Grid {
@@ -38,7 +48,7 @@ func CreateGridWithSearch(buckets *tview.List, files *tview.List, preview *tview
 
 	grid.AddItem(buckets, 1, 0, 1, 1, 0, 100, false).
 		AddItem(files, 1, 1, 1, 1, 0, 100, false).
-		AddItem(preview, 1, 2, 1, 1, 0, 100, false)
+		AddItem(previewArea(preview), 1, 2, 1, 1, 0, 100, false)
 
 	return grid
 }
@@ -56,26 +66,44 @@ func CreateDefaultGrid(buckets *tview.List, files *tview.List, preview *tview.Te
 
 	grid.AddItem(buckets, 1, 0, 1, 1, 0, 100, false).
 		AddItem(files, 1, 1, 1, 1, 0, 100, false).
-		AddItem(preview, 1, 2, 1, 1, 0, 100, false)
+		AddItem(previewArea(preview), 1, 2, 1, 1, 0, 100, false)
 
 	return grid
 }
 
-func createDefaultFooter(envName string) *tview.TextView {
+// footerText is the credentials in use and the most used keys, the rest are
+// behind ?
+func footerText() string {
 	parts := []string{
-		"Credentials: [yellow]%s[white] - Shortcuts: ([green]/[white])search |",
-		" ([green]ESC[white])ape | <[green]Ctrl+[white]> ([green]c[white])reate bucket |",
-		" ([green]a[white])dd Credentials | ([green]d[white])elete | ([green]r[white])ename |",
-		" ([green]u[white])pload | ([green]s[white])wap credentials",
+		"Credentials: [yellow]%s[white]%s - ([green]?[white]) all keys | ([green]/[white]) search |",
+		" ([green]F[white])ind in bucket | ([green]Space[white]) mark | ([green]Ctrl+w[white]) download |",
+		" ([green]Ctrl+u[white]) upload | ([green]Ctrl+o[white]) operations",
 	}
 
-	footerText := strings.Join(parts, "")
-	footerText = fmt.Sprintf(footerText, envName)
+	text := fmt.Sprintf(strings.Join(parts, ""), envName, identityText)
+	if len(marked) > 0 {
+		text = fmt.Sprintf("[yellow]%d marked[white] - ", len(marked)) + text
+	}
+	return clipboardText() + text
+}
 
-	return tview.NewTextView().
+func createDefaultFooter(envName string) *tview.TextView {
+	footerView = tview.NewTextView().
 		SetTextAlign(tview.AlignLeft).
 		SetDynamicColors(true).
-		SetText(footerText)
+		SetText(footerText())
+	return footerView
+}
+
+// restoreDefaultGrid swaps whatever is on screen for the three panes and the
+// normal footer
+func restoreDefaultGrid(focus tview.Primitive) {
+	if focus != bucketList && focus != fileList && focus != previewPane && focus != previewTable {
+		focus = bucketList
+	}
+	footer := createDefaultFooter(envName)
+	grid := CreateDefaultGrid(bucketList, fileList, previewPane, footer)
+	app.SetRoot(grid, true).SetFocus(focus)
 }
 
 func spinTitle(app *tview.Application, box *tview.List, title string, action func()) {
@@ -119,104 +147,211 @@ func spinTitle(app *tview.Application, box *tview.List, title string, action fun
 	}()
 }
 
-func S3Gui(s *awslib.S3Handler, envName string) {
+func S3Gui(s awslib.ObjectStore, env string, connect Connector) error {
+	grid, buckets, err := setupGui(s, env, connect)
+	if err != nil {
+		return err
+	}
+
+	// TODO: figure out how to change colours based on click events
+	running.Store(true)
+	defer running.Store(false)
+	defer stopApp()
+	return app.SetRoot(grid, true).EnableMouse(false).SetFocus(buckets).Run()
+}
+
+// setupGui builds the app and wires up every handler without running it, so
+// tests can swap in a simulation screen before calling app.Run
+func setupGui(s awslib.ObjectStore, env string, connect Connector) (*tview.Grid, *tview.List, error) {
 	res, err := s.GetBuckets()
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	app = tview.NewApplication()
+	appCtx, stopApp = context.WithCancel(context.Background())
+	errorQueue = make(chan reportedError, errorQueueSize)
+	go watchErrors(app, errorQueue)
+	envName = env
+	identityText = ""
+	bucketName = ""
+	selectedFile = ""
+	initialBuckets = nil
+	initialFiles = nil
+	marked = map[string]bool{}
+	clipboard = nil
+	stopListing()
+	forgetRows()
+	listView = viewSettings{filter: listFilter{maxSize: -1}}
+	forgetIndex()
+	searchMode = 0
+	pager = nil
+	previewWrap = false
+
 	buckets := tview.NewList().ShowSecondaryText(false)
-	for _, val := range res {
-		buckets.AddItem(val, "", 0, nil)
-		initialBuckets = append(initialBuckets, val)
-	}
+	loadBuckets(s, buckets, res)
 
 	// SetBackgroundColor(tcell.ColorDefault)
 	buckets.SetBorder(true).SetTitle("Buckets <Ctrl+b>").SetBorderColor(tcell.ColorYellow)
+	// tview calls the changed func on a goroutine of its own
+	a := app
 	preview := tview.NewTextView().SetWordWrap(true).
 		SetChangedFunc(func() {
-			app.Draw()
+			a.Draw()
 		})
-	preview.SetBorder(true).SetTitle("Preview <Ctrl+p>").SetBorderColor(tcell.ColorWhite)
+	preview.SetBorder(true).SetTitle(previewTitle).SetBorderColor(tcell.ColorWhite)
+	preview.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if pager == nil {
+			return event
+		}
+		return pager.handleKey(preview, event)
+	})
+	preview.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if pager != nil && (action == tview.MouseScrollDown || action == tview.MouseScrollUp) {
+			pager.afterDraw(preview)
+		}
+		return action, event
+	})
 	files := tview.NewList()
 	files.ShowSecondaryText(false).
 		SetDoneFunc(func() {
+			stopListing()
 			files.Clear()
-			preview.Clear()
+			clearPreview()
 			app.SetFocus(buckets)
 		})
-	files.SetBorder(true).SetTitle("Files <Ctrl+f>").SetBorderColor(tcell.ColorWhite)
+	files.SetBorder(true).SetTitle(filesTitle()).SetBorderColor(tcell.ColorWhite)
 	currentFocus = "buckets"
+	// csv is shown in a table that swaps in for the text
+	table := tview.NewTable().SetFixed(1, 0).SetSelectable(true, false)
+	table.SetBorder(true).SetTitle(previewTitle).SetBorderColor(tcell.ColorWhite)
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if pager == nil {
+			return event
+		}
+		return pager.handleKey(preview, event)
+	})
+	previewTable = table
+	previewSlot = tview.NewPages().
+		AddPage("text", preview, true, true).
+		AddPage("table", table, true, false)
+	bucketList, fileList, previewPane = buckets, files, preview
+	go watchCredentials(s)
+
+	// openPrefix lists prefix into the files pane, offering a retry if S3 says
+	// no. then runs once the first page is in.
+	var openPrefix func(prefix string, then func())
+	openPrefix = func(prefix string, then func()) {
+		loadFiles(s, files, prefix, func(err error) {
+			if err != nil {
+				reportErrorWithRetry(err, func() { openPrefix(prefix, then) })
+				return
+			}
+			if then != nil {
+				then()
+			}
+		})
+	}
+	files.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		fetchMore(files)
+	})
 
 	// LIST ACTIONS
 	buckets.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
 		currentFocus = "files"
-		selectedBucket := mainText
+		selectedBucket := bucketFromLabel(mainText)
 		bucketName = selectedBucket
+		selectedFile = ""
+		clearMarks()
 
-		result, err := s.GetDirectoryStructure(bucketName, "/", "")
-		initialFiles = result
-		if err != nil {
-			panic(err)
-		}
-
+		// don't leave the last bucket's files around if this one can't be listed
+		stopListing()
 		files.Clear()
-		preview.Clear()
+		initialFiles = nil
+		clearPreview()
 		app.SetFocus(files)
 		files.SetBorderColor(tcell.ColorYellow)
 		buckets.SetBorderColor(tcell.ColorWhite)
-
-		for _, val := range result {
-			if val == "" {
-				continue
-			}
-			files.AddItem(val, "", 0, nil)
-		}
+		openPrefix("", func() { checkPendingRenames(s, files) })
 	})
 
 	files.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// pasting works into an empty folder too, and a filter can hide everything
+		if event.Key() == tcell.KeyRune {
+			switch event.Rune() {
+			case 'p':
+				paste(s, files)
+				return nil
+			case 's':
+				showSortPicker(files)
+				return nil
+			case 'f':
+				showFilterPrompt(files)
+				return nil
+			case 'F':
+				showBucketSearch(s, files, currentPrefix())
+				return nil
+			}
+		}
 		if files.GetItemCount() == 0 {
 			return event
 		}
 		currentFocus = "files"
 		selectedItemIndex := files.GetCurrentItem()
-		selectedKey, _ := files.GetItemText(selectedItemIndex)
+		selectedLabel, _ := files.GetItemText(selectedItemIndex)
+		selectedKey := keyFromLabel(selectedLabel)
 		switch event.Key() {
 		case tcell.KeyCtrlD:
-			res, err := s.DeleteObject(bucketName, selectedKey)
-			if res == false {
-				return event
-			}
-			var prefix string
-			splitKey := strings.Split(selectedKey, "/")
-			if len(splitKey) == 1 {
-				prefix = ""
-			} else {
-				prefix = strings.Join(splitKey[:len(splitKey)-1], "/") + "/"
-			}
+			confirmDelete(s, files, selection(files))
+			return nil
 
-			result, err := s.GetDirectoryStructure(bucketName, "/", prefix)
-			initialFiles = result
-			if err != nil {
-				panic(err)
-			}
-			files.Clear()
-			preview.Clear()
-			app.SetFocus(files)
-			files.SetBorderColor(tcell.ColorYellow)
-			buckets.SetBorderColor(tcell.ColorWhite)
-			for _, val := range result {
-				files.AddItem(val, "", 0, nil)
+		case tcell.KeyCtrlW:
+			showDownloadInput(s, files, selection(files))
+			return nil
+
+		case tcell.KeyCtrlO:
+			showSelectionMenu(s, files)
+			return nil
+
+		case tcell.KeyCtrlA:
+			markAll(files)
+			return nil
+
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case ' ':
+				toggleMark(files)
+				return nil
+			case 'y':
+				yank(s, files, false)
+				return nil
+			case 'x':
+				yank(s, files, true)
+				return nil
+			case '*':
+				invertMarks(files)
+				return nil
+			case 'c':
+				showColumnPicker(files)
+				return nil
+
+			case '+':
+				showPrompt("Mark matching: ", "", func(pattern string) {
+					if err := markGlob(files, pattern); err != nil {
+						showMessage(fmt.Sprintf("Bad pattern %q: %v", pattern, err))
+					}
+				})
+				return nil
 			}
 
 			// TODO: remove key in rename
 		case tcell.KeyCtrlR:
-			if selectedKey == ".." || selectedKey[len(selectedKey)-1:] == "/" {
-				footer := createDefaultFooter(envName)
-				grid := CreateDefaultGrid(buckets, files, preview, footer)
-				app.SetRoot(grid, true).SetFocus(files)
+			if selectedKey == ".." {
 				return event
 			}
+			if strings.HasSuffix(selectedKey, "/") {
+				showRenameFolder(s, files, selectedKey)
+				return nil
+			}
 			renameInput := tview.NewInputField().
 				SetLabel("Rename: ").
 				SetFieldWidth(100)
@@ -227,7 +362,9 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 				if key == tcell.KeyEnter {
 					_, err := s.RenameObject(bucketName, selectedKey, renameInput.GetText())
 					if err != nil {
-						panic(err)
+						restoreDefaultGrid(files)
+						reportError(err)
+						return
 					}
 					var prefix string
 					splitKey := strings.Split(selectedKey, "/")
@@ -236,20 +373,11 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 					} else {
 						prefix = strings.Join(splitKey[:len(splitKey)-1], "/") + "/"
 					}
-					result, err := s.GetDirectoryStructure(bucketName, "/", prefix)
-					initialFiles = result
-					if err != nil {
-						panic(err)
-					}
-
-					files.Clear()
-					preview.Clear()
+					clearPreview()
 					app.SetFocus(files)
 					files.SetBorderColor(tcell.ColorYellow)
 					buckets.SetBorderColor(tcell.ColorWhite)
-					for _, val := range result {
-						files.AddItem(val, "", 0, nil)
-					}
+					openPrefix(prefix, nil)
 
 					footer := createDefaultFooter(envName)
 					grid := CreateDefaultGrid(buckets, files, preview, footer)
@@ -262,7 +390,7 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 	})
 	files.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
 		currentFocus = "files"
-		selectedKey := mainText
+		selectedKey := keyFromLabel(mainText)
 		if selectedKey != ".." {
 			selectedFile = selectedKey
 		} else {
@@ -276,42 +404,20 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 			selectedFile = selectedKey
 		}
 
-		if selectedKey == "" {
-			files.Clear()
-			res, err := s.GetDirectoryStructure(bucketName, "/", selectedKey)
-			initialFiles = res
-			if err != nil {
-				panic(err)
-			}
-			for _, val := range res {
-				files.AddItem(val, "", 0, nil)
-			}
-		} else if selectedKey[len(selectedKey)-1:] == "/" {
-			files.Clear()
-			res, err := s.GetDirectoryStructure(bucketName, "/", selectedKey)
-			initialFiles = res
-			if err != nil {
-				panic(err)
-			}
-			for _, val := range res {
-				files.AddItem(val, "", 0, nil)
-			}
-
+		if selectedKey == "" || selectedKey[len(selectedKey)-1:] == "/" {
+			openPrefix(selectedKey, nil)
 		} else {
 			glacier, err := s.IsGlacier(bucketName, selectedKey)
 			if err != nil {
-				panic(err)
+				clearPreview()
+				reportError(err)
+				return
 			}
 			if glacier {
+				clearPreview()
 				preview.SetText(string("Cannot view a file stored in Glacier. Please restore the file if you wish to view."))
 			} else {
-				byteContent, err := s.PreviewFile(bucketName, selectedKey)
-				if err != nil {
-					// TODO: fix error handling
-					panic(err)
-				}
-				byteContent = utils.ParsePreview(byteContent)
-				preview.SetText(string(byteContent))
+				openPreview(s, preview, bucketName, selectedKey)
 			}
 		}
 	})
@@ -325,41 +431,46 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 	// 	AddItem(files, 0, 0, 0, 0, 0, 0, false).
 	// 	AddItem(preview, 0, 0, 0, 0, 0, 0, false)
 
+	// every handler in here closes over s, so this swaps them all over
+	swap := func(store awslib.ObjectStore, profile string, res []string) {
+		s = store
+		envName = profile
+		identityText = ""
+		bucketName = ""
+		selectedFile = ""
+		initialFiles = nil
+		marked = map[string]bool{}
+		stopListing()
+		forgetIndex()
+		files.Clear()
+		clearPreview()
+		loadBuckets(s, buckets, res)
+		restoreDefaultGrid(buckets)
+		go watchCredentials(s)
+	}
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
 		case tcell.KeyCtrlB:
 			app.SetFocus(buckets)
 			buckets.SetBorderColor(tcell.ColorYellow)
 			files.SetBorderColor(tcell.ColorWhite)
-			preview.SetBorderColor(tcell.ColorWhite)
+			previewBorder(tcell.ColorWhite)
 			currentFocus = "buckets"
 		case tcell.KeyCtrlF:
 			app.SetFocus(files)
 			files.SetBorderColor(tcell.ColorYellow)
 			buckets.SetBorderColor(tcell.ColorWhite)
-			preview.SetBorderColor(tcell.ColorWhite)
+			previewBorder(tcell.ColorWhite)
 			currentFocus = "files"
 		case tcell.KeyCtrlP:
-			app.SetFocus(preview)
-			preview.SetBorderColor(tcell.ColorYellow)
+			app.SetFocus(previewSlot)
+			previewBorder(tcell.ColorYellow)
 			buckets.SetBorderColor(tcell.ColorWhite)
 			files.SetBorderColor(tcell.ColorWhite)
 		case tcell.KeyCtrlE:
-			form := tview.NewForm().
-				AddInputField("Name", "", 40, nil, nil).
-				AddInputField("Access Key", "", 40, nil, nil).
-				AddPasswordField("Secret Access Key", "", 40, '*', nil).
-				AddInputField("SSO (Optional)", "", 40, nil, nil).
-				AddTextView("Note", "Credentials will be stored inside\n.aws/credentials", 40, 2, true, false).
-				AddButton("Save", func() {
-					// add env logic here
-					app.SetRoot(grid, true).EnableMouse(true).Run()
-				}).
-				AddButton("Quit", func() {
-					app.SetRoot(grid, true).EnableMouse(true).Run()
-				})
-			form.SetBorder(true).SetTitle("AWS Credentials Configuration").SetTitleAlign(tview.AlignLeft)
-			app.SetRoot(form, true).EnableMouse(true).Run()
+			showAddProfileForm(connect, swap)
+			return nil
 
 		case tcell.KeyCtrlT:
 			bucketInput := tview.NewInputField().
@@ -370,50 +481,65 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 			app.SetRoot(grid, true).SetFocus(bucketInput)
 
 			bucketInput.SetDoneFunc(func(key tcell.Key) {
-				if key == tcell.KeyEnter {
-					spinTitle(app, buckets, "Creating bucket", func() {
-						_, err := s.CreateBucket(bucketInput.GetText(), 8)
+				restoreDefaultGrid(buckets)
+				name := bucketInput.GetText()
+				if key != tcell.KeyEnter || name == "" {
+					return
+				}
+				store := s
+				spinTitle(a, buckets, "Creating bucket", func() {
+					_, err := store.CreateBucket(name, 8)
+					var res []string
+					if err == nil {
+						res, err = store.GetBuckets()
+					}
+					a.QueueUpdateDraw(func() {
 						if err != nil {
-							panic("lame")
+							reportError(err)
+							return
 						}
+						loadBuckets(store, buckets, res)
 					})
-					res, err := s.GetBuckets()
-					if err != nil {
-						panic(err)
-					}
-
-					buckets.Clear()
-					buckets.SetTitle("Buckets <Ctrl+b")
-					for _, val := range res {
-						buckets.AddItem(val, "", 0, nil)
-					}
-
-					footer := createDefaultFooter(envName)
-					grid := CreateDefaultGrid(buckets, files, preview, footer)
-					app.SetRoot(grid, true).SetFocus(buckets)
-				}
+				})
 			})
 
 			// nested switch is needed to use '/' (or skill issue). LETS GOOOOOOOOOOO
 		case tcell.KeyRune:
 			switch event.Rune() {
+			case '?':
+				// like / below, typed into an input it's just a ?
+				switch app.GetFocus() {
+				case buckets, files, preview, previewTable:
+					showHelp()
+					return nil
+				}
 			case '/':
+				// only search from the panes, otherwise you can't type paths into inputs
+				focused := app.GetFocus()
+				if focused == preview || focused == previewTable {
+					showPreviewSearch(preview)
+					return nil
+				}
+				if focused != buckets && focused != files {
+					break
+				}
 				renameInput := tview.NewInputField().
-					SetLabel("Search: ").
 					SetFieldWidth(100)
 
 				grid := CreateGridWithSearch(buckets, files, preview, renameInput)
 
 				app.SetRoot(grid, true).SetFocus(renameInput)
-				if currentFocus == "buckets" {
-					FuzzyFind(renameInput, buckets, initialBuckets, buckets, files, preview)
-				} else if currentFocus == "files" {
-					FuzzyFind(renameInput, files, initialFiles, buckets, files, preview)
+				if focused == buckets {
+					FuzzyFind(renameInput, buckets, initialBuckets, searchBucketLabel, buckets, files, preview)
 				} else {
-					fmt.Println("Focus is not on a tview.List")
+					FuzzyFind(renameInput, files, initialFiles, searchFileLabel, buckets, files, preview)
 				}
 			}
 
+		case tcell.KeyCtrlS:
+			showProfilePicker(connect, swap)
+			return nil
+
 		case tcell.KeyCtrlQ:
 			modal := tview.NewModal().
 				SetText("Do you want to quit s3-tui?").
@@ -430,20 +556,11 @@ func S3Gui(s *awslib.S3Handler, envName string) {
 			app.SetRoot(modal, true).EnableMouse(true).Run()
 
 		case tcell.KeyCtrlU:
-			var mu sync.Mutex
-			mu.Lock()
-			defer mu.Unlock()
-			spinTitle(app, files, "Uploading", func() {
-				// swap this with s3 upload
-				time.Sleep(10 * time.Second)
-			})
+			showUploadInput(s, buckets, files, preview)
 
 		}
 		return event
 	})
 
-	// TODO: figure out how to change colours based on click events
-	if err := app.SetRoot(grid, true).EnableMouse(false).SetFocus(buckets).Run(); err != nil {
-		panic(err)
-	}
+	return grid, buckets, nil
 }
@@ -0,0 +1,322 @@
+package gui
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+)
+
+func newTestStore() *awslib.MemoryStore {
+	store := awslib.NewMemoryStore()
+	store.Put("alpha", "top.txt", []byte("hello from the top"))
+	store.Put("alpha", "docs/readme.md", []byte("# readme"))
+	store.Put("alpha", "docs/deep/nested.txt", []byte("nested"))
+	store.Put("alpha", "frozen.bin", []byte("cold"))
+	store.SetStorageClass("alpha", "frozen.bin", "GLACIER")
+	store.Put("beta", "other.txt", []byte("other"))
+	return store
+}
+
+// startGui runs the app against a simulation screen until the test finishes
+func startGui(t *testing.T, store awslib.ObjectStore) tcell.SimulationScreen {
+	t.Helper()
+	return startGuiWithConnector(t, store, nil)
+}
+
+func startGuiWithConnector(t *testing.T, store awslib.ObjectStore, connect Connector) tcell.SimulationScreen {
+	t.Helper()
+	grid, buckets, err := setupGui(store, "test", connect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	screen := tcell.NewSimulationScreen("UTF-8")
+	app.SetScreen(screen)
+	screen.SetSize(180, 30)
+
+	done := make(chan error)
+	go func() {
+		done <- app.SetRoot(grid, true).SetFocus(buckets).Run()
+	}()
+	t.Cleanup(func() {
+		app.Stop()
+		stopApp()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	waitFor(t, screen, "Buckets <Ctrl+b>")
+	return screen
+}
+
+// waitTimeout is how long the wait helpers give the app, plenty even with
+// -race slowing everything down
+const waitTimeout = 10 * time.Second
+
+// screenText is what's on screen. GetContents hands back the cells without
+// holding the screen's lock, so they're read under it, where a draw on the
+// app's goroutine can't change them halfway through.
+func screenText(screen tcell.SimulationScreen) string {
+	cells, width, _ := screen.GetContents()
+	locker := screen.(sync.Locker)
+	locker.Lock()
+	defer locker.Unlock()
+	var b strings.Builder
+	for i, cell := range cells {
+		if len(cell.Runes) == 0 {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(cell.Runes[0])
+		}
+		if (i+1)%width == 0 {
+			b.WriteRune('\n')
+		}
+	}
+	return b.String()
+}
+
+func waitUntil(t *testing.T, screen tcell.SimulationScreen, desc string, cond func(string) bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		if cond(screenText(screen)) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s, screen:\n%s", desc, screenText(screen))
+}
+
+// pressUntil presses key until cond holds for what's on screen. It waits for
+// the screen to change, or a while, between presses, but a slow run can still
+// get a press or two ahead so cond shouldn't need an exact line.
+func pressUntil(t *testing.T, screen tcell.SimulationScreen, desc string, key tcell.Key, cond func(string) bool) {
+	t.Helper()
+	last, waited := "", 0
+	waitUntil(t, screen, desc, func(text string) bool {
+		if cond(text) {
+			return true
+		}
+		if waited++; text != last || waited > 25 {
+			last, waited = text, 0
+			press(key)
+		}
+		return false
+	})
+}
+
+// numberAfter is the first number on screen after prefix, or -1
+func numberAfter(text string, prefix string) int {
+	i := strings.Index(text, prefix)
+	if i < 0 {
+		return -1
+	}
+	digits := text[i+len(prefix):]
+	n := 0
+	for n < len(digits) && digits[n] >= '0' && digits[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+	v, _ := strconv.Atoi(digits[:n])
+	return v
+}
+
+// eventually waits for something off screen, like the store, to change
+func eventually(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitFor(t *testing.T, screen tcell.SimulationScreen, want string) {
+	t.Helper()
+	waitUntil(t, screen, want, func(text string) bool {
+		return strings.Contains(text, want)
+	})
+}
+
+func waitForGone(t *testing.T, screen tcell.SimulationScreen, gone string) {
+	t.Helper()
+	waitUntil(t, screen, gone+" to disappear", func(text string) bool {
+		return !strings.Contains(text, gone)
+	})
+}
+
+// onApp runs f on the app's goroutine and waits for it, for looking at
+// widgets and globals the app might be changing
+func onApp(f func()) {
+	app.QueueUpdate(f)
+}
+
+// press and typeText go through app.QueueEvent rather than screen.InjectKey as
+// the simulation screen silently drops events once its small buffer is full
+func press(keys ...tcell.Key) {
+	for _, key := range keys {
+		app.QueueEvent(tcell.NewEventKey(key, 0, tcell.ModNone))
+	}
+}
+
+func typeText(text string) {
+	for _, r := range text {
+		app.QueueEvent(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+}
+
+func TestNavigateIntoFolderAndBack(t *testing.T) {
+	screen := startGui(t, newTestStore())
+	waitFor(t, screen, "alpha (us-east-1)")
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	waitFor(t, screen, "docs/")
+
+	// folders are listed first so docs/ is selected
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/readme.md")
+	waitFor(t, screen, "docs/deep/")
+	waitForGone(t, screen, "top.txt")
+
+	// ".." is the first entry inside a folder
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	waitForGone(t, screen, "docs/readme.md")
+}
+
+func TestPreviewFile(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "hello from the top")
+}
+
+func TestPreviewGlacierFile(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "frozen.bin")
+	press(tcell.KeyDown, tcell.KeyEnter)
+	waitFor(t, screen, "Cannot view a file stored in Glacier")
+}
+
+func TestDeleteFile(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyCtrlD)
+	waitFor(t, screen, "Delete s3://alpha/top.txt?")
+	// cancel is the default
+	press(tcell.KeyEnter)
+	waitForGone(t, screen, "Delete s3://alpha/top.txt?")
+	if _, ok := store.Get("alpha", "top.txt"); !ok {
+		t.Fatal("top.txt was deleted without confirming")
+	}
+
+	press(tcell.KeyCtrlD)
+	waitFor(t, screen, "Delete s3://alpha/top.txt?")
+	press(tcell.KeyTab, tcell.KeyEnter)
+	waitForGone(t, screen, "top.txt")
+
+	if _, ok := store.Get("alpha", "top.txt"); ok {
+		t.Error("top.txt still exists in the store")
+	}
+	if _, ok := store.Get("alpha", "frozen.bin"); !ok {
+		t.Error("frozen.bin was deleted too")
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	store := newTestStore()
+	store.Put("alpha", "docs/", nil)
+	store.Put("alpha", "docsify.txt", []byte("not in docs/"))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "docs/")
+	press(tcell.KeyCtrlD)
+	waitFor(t, screen, "Delete everything under s3://alpha/docs/?")
+	// the folder marker, readme.md (8 B) and deep/nested.txt (6 B)
+	waitFor(t, screen, "3 objects, 14 B")
+	waitFor(t, screen, "docs/deep/nested.txt")
+
+	press(tcell.KeyTab, tcell.KeyEnter)
+	waitForGone(t, screen, "docs/")
+	waitFor(t, screen, "top.txt")
+
+	for _, key := range []string{"docs/", "docs/readme.md", "docs/deep/nested.txt"} {
+		if _, ok := store.Get("alpha", key); ok {
+			t.Errorf("%s still exists", key)
+		}
+	}
+	if _, ok := store.Get("alpha", "docsify.txt"); !ok {
+		t.Error("docsify.txt shares the prefix but isn't in the folder")
+	}
+}
+
+func TestRenameFile(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyCtrlR)
+	waitFor(t, screen, "Rename:")
+	typeText("renamed.txt")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "renamed.txt")
+	waitForGone(t, screen, "top.txt")
+
+	data, ok := store.Get("alpha", "renamed.txt")
+	if !ok || string(data) != "hello from the top" {
+		t.Errorf("renamed.txt = %q, %v", data, ok)
+	}
+	if _, ok := store.Get("alpha", "top.txt"); ok {
+		t.Error("top.txt still exists after rename")
+	}
+}
+
+func TestRenameIntoFolder(t *testing.T) {
+	store := newTestStore()
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+	press(tcell.KeyDown, tcell.KeyDown, tcell.KeyCtrlR)
+	waitFor(t, screen, "Rename:")
+	// "/" opens search from the panes but has to be typeable in here
+	typeText("docs/top.txt")
+	press(tcell.KeyEnter)
+	waitForGone(t, screen, "Rename:")
+
+	if _, ok := store.Get("alpha", "docs/top.txt"); !ok {
+		t.Error("docs/top.txt doesn't exist after rename")
+	}
+}
+
+func TestSearchBuckets(t *testing.T) {
+	screen := startGui(t, newTestStore())
+
+	typeText("/")
+	waitFor(t, screen, "Search (fuzzy, Tab to change):")
+	typeText("bet")
+	waitForGone(t, screen, "alpha")
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "other.txt")
+}
@@ -0,0 +1,103 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// currentPrefix works out the folder being browsed from the last thing selected
+// in the files list. selectedFile is either a folder ("a/b/") or a file ("a/b/c.txt")
+func currentPrefix() string {
+	if selectedFile == "" || strings.HasSuffix(selectedFile, "/") {
+		return selectedFile
+	}
+	i := strings.LastIndex(selectedFile, "/")
+	if i == -1 {
+		return ""
+	}
+	return selectedFile[:i+1]
+}
+
+// progressTitle returns a ProgressFunc that renders transfer progress into the
+// title of box, only redrawing when the percentage ticks over
+func progressTitle(box *tview.List, verb string, name string) awslib.ProgressFunc {
+	last := int64(-1)
+	a := app
+	return func(done int64, total int64) {
+		pct := int64(utils.Percent(done, total))
+		if atomic.SwapInt64(&last, pct) == pct {
+			return
+		}
+		title := fmt.Sprintf("%s %s %d%% (%s/%s)", verb, name, pct, utils.HumanBytes(done), utils.HumanBytes(total))
+		a.QueueUpdateDraw(func() {
+			box.SetTitle(title)
+		})
+	}
+}
+
+func showUploadInput(s awslib.ObjectStore, buckets *tview.List, files *tview.List, preview *tview.TextView) {
+	if bucketName == "" {
+		return
+	}
+	prefix := currentPrefix()
+	uploadInput := tview.NewInputField().
+		SetLabel(fmt.Sprintf("Upload to s3://%s/%s from: ", bucketName, prefix)).
+		SetFieldWidth(100)
+	grid := CreateGridWithSearch(buckets, files, preview, uploadInput)
+	app.SetRoot(grid, true).SetFocus(uploadInput)
+
+	uploadInput.SetDoneFunc(func(key tcell.Key) {
+		footer := createDefaultFooter(envName)
+		grid := CreateDefaultGrid(buckets, files, preview, footer)
+		app.SetRoot(grid, true).SetFocus(files)
+		if key != tcell.KeyEnter || uploadInput.GetText() == "" {
+			return
+		}
+
+		localPath := expandHome(uploadInput.GetText())
+		bucket := bucketName
+		originalTitle := files.GetTitle()
+		progress := progressTitle(files, "Uploading", filepath.Base(localPath))
+
+		a := app
+		var upload func()
+		upload = func() {
+			info, err := os.Stat(localPath)
+			if err == nil {
+				if info.IsDir() {
+					_, err = s.UploadDirectory(bucket, prefix, localPath, progress)
+				} else {
+					_, err = s.UploadFile(bucket, prefix, localPath, progress)
+				}
+			}
+			a.QueueUpdateDraw(func() {
+				files.SetTitle(originalTitle)
+				if err != nil {
+					reportErrorWithRetry(err, func() { go upload() })
+					return
+				}
+				// only refresh if the user is still looking at the same place
+				if bucket == bucketName && prefix == currentPrefix() {
+					refreshFiles(s, files)
+				}
+			})
+		}
+		go upload()
+	})
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return os.Getenv("HOME") + p[1:]
+	}
+	return p
+}
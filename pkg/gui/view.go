@@ -0,0 +1,348 @@
+package gui
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"github.com/rogep/s3-tui/pkg/awslib"
+	"github.com/rogep/s3-tui/pkg/utils"
+)
+
+// sortMode is one of the orders the files pane can be sorted in. Folders stay
+// above keys whatever the order.
+type sortMode struct {
+	name     string
+	shortcut rune
+	less     func(a, b awslib.DirectoryEntry) bool
+}
+
+var sortModes = []sortMode{
+	{"name", 'n', func(a, b awslib.DirectoryEntry) bool { return a.Key < b.Key }},
+	{"natural", 'a', func(a, b awslib.DirectoryEntry) bool { return naturalLess(a.Key, b.Key) }},
+	{"size", 'z', func(a, b awslib.DirectoryEntry) bool { return a.Size < b.Size }},
+	{"modified", 'm', func(a, b awslib.DirectoryEntry) bool { return a.LastModified.Before(b.LastModified) }},
+	{"class", 'c', func(a, b awslib.DirectoryEntry) bool { return classRank(a.StorageClass) < classRank(b.StorageClass) }},
+}
+
+// storageTiers is the storage classes from quickest to slowest to read back
+var storageTiers = []string{
+	"EXPRESS_ONEZONE", "STANDARD", "REDUCED_REDUNDANCY", "INTELLIGENT_TIERING",
+	"STANDARD_IA", "ONEZONE_IA", "GLACIER_IR", "GLACIER", "DEEP_ARCHIVE",
+}
+
+func classRank(class string) int {
+	for i, c := range storageTiers {
+		if c == class {
+			return i
+		}
+	}
+	return len(storageTiers)
+}
+
+// listFilter hides keys in the files pane. Folders are always shown so there's
+// still a way into them.
+type listFilter struct {
+	text    string // as typed, shown in the title
+	exts    []string
+	minSize int64
+	maxSize int64 // -1 for no limit
+	since   time.Time
+	classes map[string]bool
+}
+
+// viewSettings is how the files pane is sorted and filtered. It sticks while
+// moving between folders and buckets.
+type viewSettings struct {
+	sort   int // index into sortModes
+	desc   bool
+	filter listFilter
+}
+
+var listView = viewSettings{filter: listFilter{maxSize: -1}}
+
+// plain is S3's own order with nothing hidden, so pages can just be appended
+func (v viewSettings) plain() bool {
+	return v.sort == 0 && !v.desc && v.filter.text == ""
+}
+
+func (v viewSettings) sortFolders(folders []string) []string {
+	sorted := append([]string(nil), folders...)
+	mode := sortModes[v.sort].name
+	less := func(i, j int) bool { return sorted[i] < sorted[j] }
+	if mode == "natural" {
+		less = func(i, j int) bool { return naturalLess(sorted[i], sorted[j]) }
+	}
+	sort.SliceStable(sorted, less)
+	// only name orders say anything about folders
+	if v.desc && (mode == "name" || mode == "natural") {
+		reverse(sorted)
+	}
+	return sorted
+}
+
+// sortKeys filters and sorts keys using what the listing said about them
+func (v viewSettings) sortKeys(keys []string) []string {
+	var kept []string
+	for _, key := range keys {
+		if v.filter.keep(fileInfo[key]) {
+			kept = append(kept, key)
+		}
+	}
+	less := sortModes[v.sort].less
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := fileInfo[kept[i]], fileInfo[kept[j]]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Key < b.Key
+	})
+	if v.desc {
+		reverse(kept)
+	}
+	return kept
+}
+
+func (v viewSettings) String() string {
+	var parts []string
+	if v.sort != 0 || v.desc {
+		arrow := "↑"
+		if v.desc {
+			arrow = "↓"
+		}
+		parts = append(parts, fmt.Sprintf("sort: %s %s", sortModes[v.sort].name, arrow))
+	}
+	if v.filter.text != "" {
+		parts = append(parts, "filter: "+v.filter.text)
+	}
+	return strings.Join(parts, " | ")
+}
+
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// naturalLess compares runs of digits by value, so "file2" comes before "file10"
+func naturalLess(a string, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+func (f listFilter) keep(e awslib.DirectoryEntry) bool {
+	if len(f.exts) > 0 {
+		ext := strings.ToLower(path.Ext(e.Key))
+		found := false
+		for _, want := range f.exts {
+			if ext == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if e.Size < f.minSize || (f.maxSize >= 0 && e.Size > f.maxSize) {
+		return false
+	}
+	if !f.since.IsZero() && e.LastModified.Before(f.since) {
+		return false
+	}
+	if len(f.classes) > 0 && !f.classes[e.StorageClass] {
+		return false
+	}
+	return true
+}
+
+// parseFilter reads filters like "ext:csv,json size:1MiB-10MiB since:7d
+// class:glacier". An empty string filters nothing.
+func parseFilter(text string, now time.Time) (listFilter, error) {
+	f := listFilter{text: strings.Join(strings.Fields(text), " "), maxSize: -1}
+	for _, field := range strings.Fields(text) {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			return listFilter{}, fmt.Errorf("%q should look like name:value", field)
+		}
+		switch strings.ToLower(name) {
+		case "ext":
+			for _, ext := range strings.Split(value, ",") {
+				f.exts = append(f.exts, "."+strings.ToLower(strings.TrimPrefix(ext, ".")))
+			}
+		case "size":
+			min, max, err := parseSizeRange(value)
+			if err != nil {
+				return listFilter{}, err
+			}
+			f.minSize, f.maxSize = min, max
+		case "since":
+			since, err := parseSince(value, now)
+			if err != nil {
+				return listFilter{}, err
+			}
+			f.since = since
+		case "class":
+			f.classes = map[string]bool{}
+			for _, class := range strings.Split(value, ",") {
+				full := storageClassNamed(class)
+				if full == "" {
+					return listFilter{}, fmt.Errorf("unknown storage class %q", class)
+				}
+				f.classes[full] = true
+			}
+		default:
+			return listFilter{}, fmt.Errorf("unknown filter %q, pick from ext, size, since and class", name)
+		}
+	}
+	return f, nil
+}
+
+// parseSizeRange reads "1MiB-10MiB", ">1KB", "<5MiB", "1GB-" or "-1KiB"
+func parseSizeRange(value string) (int64, int64, error) {
+	min, max := int64(0), int64(-1)
+	var err error
+	switch {
+	case strings.HasPrefix(value, ">"):
+		min, err = utils.ParseBytes(strings.TrimLeft(value, ">="))
+	case strings.HasPrefix(value, "<"):
+		max, err = utils.ParseBytes(strings.TrimLeft(value, "<="))
+	default:
+		lo, hi, ranged := strings.Cut(value, "-")
+		if !ranged {
+			return 0, 0, fmt.Errorf("size %q should be a range like 1MiB-10MiB, >1MiB or <1MiB", value)
+		}
+		if lo != "" {
+			if min, err = utils.ParseBytes(lo); err != nil {
+				return 0, 0, err
+			}
+		}
+		if hi != "" {
+			max, err = utils.ParseBytes(hi)
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if max >= 0 && max < min {
+		return 0, 0, fmt.Errorf("size %q is an empty range", value)
+	}
+	return min, max, nil
+}
+
+// parseSince reads a date like 2024-01-31 or an age like 90m, 12h, 7d or 2w
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	units := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if unit, ok := units[value[len(value)-1]]; ok {
+		var n int
+		if _, err := fmt.Sscanf(value[:len(value)-1], "%d", &n); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("since %q should be a date like 2024-01-31 or an age like 7d", value)
+}
+
+// storageClassNamed accepts a storage class or its badge, in any case
+func storageClassNamed(name string) string {
+	name = strings.ToUpper(name)
+	for _, class := range storageTiers {
+		if class == name || classBadges[class].text == name {
+			return class
+		}
+	}
+	return ""
+}
+
+// filesTitle is the files pane title, with the sort and filter if there are any
+func filesTitle() string {
+	if view := listView.String(); view != "" {
+		return "Files <Ctrl+f> - " + view
+	}
+	return "Files <Ctrl+f>"
+}
+
+// applyView redraws the files pane after the sort or filter changes
+func applyView(files *tview.List) {
+	files.SetTitle(filesTitle())
+	if listing != nil && listing.started {
+		showListing(files, listing)
+		fetchMore(files)
+	}
+}
+
+// showSortPicker picks the files pane order. Picking the order already in use
+// flips it.
+func showSortPicker(files *tview.List) {
+	focus := app.GetFocus()
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle("Sort by").SetTitleAlign(tview.AlignLeft)
+	list.SetDoneFunc(func() {
+		restoreDefaultGrid(focus)
+	})
+	for i, mode := range sortModes {
+		i := i
+		label := mode.name
+		if i == listView.sort {
+			label += " (again to reverse)"
+		}
+		list.AddItem(label, "", mode.shortcut, func() {
+			if i == listView.sort {
+				listView.desc = !listView.desc
+			} else {
+				listView.sort, listView.desc = i, false
+			}
+			restoreDefaultGrid(focus)
+			applyView(files)
+		})
+	}
+	list.SetCurrentItem(listView.sort)
+	app.SetRoot(centered(list, 40, len(sortModes)+2), true).SetFocus(list)
+}
+
+// showFilterPrompt edits the files pane filter
+func showFilterPrompt(files *tview.List) {
+	showPrompt("Filter (ext:csv,json size:1MiB-10MiB since:7d class:glacier): ", listView.filter.text, func(text string) {
+		filter, err := parseFilter(text, time.Now())
+		if err != nil {
+			showMessage(err.Error())
+			return
+		}
+		listView.filter = filter
+		applyView(files)
+	})
+}
@@ -0,0 +1,105 @@
+package gui
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// rowsOf is the files pane as the rows' keys, top to bottom
+func rowsOf(screen tcell.SimulationScreen, keys ...string) []string {
+	text := screenText(screen)
+	var found []string
+	for _, line := range strings.Split(text, "\n") {
+		for _, key := range keys {
+			if strings.Contains(line, " "+key+" ") {
+				found = append(found, key)
+			}
+		}
+	}
+	return found
+}
+
+func TestSortAndFilterFiles(t *testing.T) {
+	store := newTestStore()
+	store.Put("alpha", "big.csv", []byte(strings.Repeat("x", 3000)))
+	store.Put("alpha", "file10.csv", []byte("ten"))
+	store.Put("alpha", "file9.csv", []byte("nine!"))
+	store.SetModified("alpha", "big.csv", time.Now().Add(-60*24*time.Hour))
+	screen := startGui(t, store)
+
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "top.txt")
+
+	typeText("s")
+	waitFor(t, screen, "Sort by")
+	typeText("z")
+	waitFor(t, screen, "Files <Ctrl+f> - sort: size ↑")
+	all := []string{"docs/", "big.csv", "file10.csv", "file9.csv", "frozen.bin", "top.txt"}
+	want := []string{"docs/", "file10.csv", "frozen.bin", "file9.csv", "top.txt", "big.csv"}
+	eventually(t, "rows by size", func() bool { return strings.Join(rowsOf(screen, all...), " ") == strings.Join(want, " ") })
+
+	// again flips it, folders stay on top
+	typeText("s")
+	waitFor(t, screen, "size (again to reverse)")
+	typeText("z")
+	waitFor(t, screen, "sort: size ↓")
+	want = []string{"docs/", "big.csv", "top.txt", "file9.csv", "frozen.bin", "file10.csv"}
+	eventually(t, "rows by size, biggest first", func() bool { return strings.Join(rowsOf(screen, all...), " ") == strings.Join(want, " ") })
+
+	typeText("f")
+	waitFor(t, screen, "Filter (")
+	typeText("ext:csv since:30d")
+	press(tcell.KeyEnter)
+	waitFor(t, screen, "filter: ext:csv since:30d")
+	waitForGone(t, screen, "top.txt")
+	waitForGone(t, screen, "big.csv")
+	waitFor(t, screen, "file9.csv")
+
+	// the filter sticks going into a folder
+	press(tcell.KeyHome, tcell.KeyEnter)
+	waitFor(t, screen, "deep/")
+	waitForGone(t, screen, "readme.md")
+	waitFor(t, screen, "filter: ext:csv since:30d")
+}
+
+func TestNaturalLess(t *testing.T) {
+	keys := []string{"file10.txt", "file2.txt", "file1.txt", "file02b.txt", "a", "file"}
+	sort.Slice(keys, func(i, j int) bool { return naturalLess(keys[i], keys[j]) })
+	want := "a file file1.txt file2.txt file02b.txt file10.txt"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	f, err := parseFilter("ext:.CSV,json  size:1KiB-2MB since:7d class:glac,standard_ia", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.exts, ",") != ".csv,.json" || f.minSize != 1024 || f.maxSize != 2000000 {
+		t.Errorf("filter = %+v", f)
+	}
+	if !f.since.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Errorf("since = %v", f.since)
+	}
+	if !f.classes["GLACIER"] || !f.classes["STANDARD_IA"] || len(f.classes) != 2 {
+		t.Errorf("classes = %v", f.classes)
+	}
+	if f.text != "ext:.CSV,json size:1KiB-2MB since:7d class:glac,standard_ia" {
+		t.Errorf("text = %q", f.text)
+	}
+
+	if f, err := parseFilter("size:>1M", now); err != nil || f.minSize != 1<<20 || f.maxSize != -1 {
+		t.Errorf(">1M = %+v, %v", f, err)
+	}
+	for _, bad := range []string{"csv", "owner:me", "size:10-1", "size:lots", "since:soon", "class:cold"} {
+		if _, err := parseFilter(bad, now); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
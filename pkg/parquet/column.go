@@ -0,0 +1,499 @@
+package parquet
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// repetition types
+const (
+	required = 0
+	optional = 1
+	repeated = 2
+)
+
+var repetitionNames = []string{"required", "optional", "repeated"}
+
+// physical types, how values are stored
+const (
+	physBoolean = iota
+	physInt32
+	physInt64
+	physInt96
+	physFloat
+	physDouble
+	physByteArray
+	physFixed
+)
+
+var physicalNames = []string{"BOOLEAN", "INT32", "INT64", "INT96", "FLOAT", "DOUBLE", "BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY"}
+
+var codecNames = []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+
+var encodingNames = []string{"PLAIN", "GROUP_VAR_INT", "PLAIN_DICTIONARY", "RLE", "BIT_PACKED", "DELTA_BINARY_PACKED", "DELTA_LENGTH_BYTE_ARRAY", "DELTA_BYTE_ARRAY", "RLE_DICTIONARY", "BYTE_STREAM_SPLIT"}
+
+// nameOf looks n up in names, for enums in the metadata
+func nameOf(names []string, n int64) string {
+	if n >= 0 && n < int64(len(names)) {
+		return names[n]
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", n)
+}
+
+// Column is a leaf of the schema, one that holds values
+type Column struct {
+	Path []string
+	// Type is how values are stored, e.g. INT64, and Logical what they are,
+	// e.g. TIMESTAMP(MICROS). Logical is "" when they're nothing more.
+	Type       string
+	Logical    string
+	Repetition string
+	// across every row group
+	Codecs       []string
+	Encodings    []string
+	Compressed   int64
+	Uncompressed int64
+
+	physical int64
+	length   int // of a fixed length byte array
+	maxDef   int
+	maxRep   int
+	logical  logical
+
+	// statistics so far, as values are added from each row group
+	groups       int
+	nulls        int64
+	nullsUnknown bool
+	distinct     int64
+	min, max     any
+	rangeUnknown bool
+}
+
+func newColumn(e thriftStruct, path []string, def int, rep int) *Column {
+	c := &Column{
+		Path:       path,
+		Repetition: nameOf(repetitionNames, e.int(3)),
+		physical:   e.int(1),
+		length:     int(e.int(2)),
+		maxDef:     def,
+		maxRep:     rep,
+		logical:    logicalOf(e),
+	}
+	c.Type = nameOf(physicalNames, c.physical)
+	if c.physical == physFixed {
+		c.Type += fmt.Sprintf("(%d)", c.length)
+	}
+	c.Logical = c.logical.String()
+	return c
+}
+
+// add takes in the column's ColumnMetaData from another row group
+func (c *Column) add(m thriftStruct) {
+	if codec := nameOf(codecNames, m.int(4)); !contains(c.Codecs, codec) {
+		c.Codecs = append(c.Codecs, codec)
+	}
+	for _, e := range m.list(2) {
+		n, _ := e.(int64)
+		if enc := nameOf(encodingNames, n); !contains(c.Encodings, enc) {
+			c.Encodings = append(c.Encodings, enc)
+		}
+	}
+	c.Compressed += m.int(7)
+	c.Uncompressed += m.int(6)
+	c.groups++
+
+	st := m.strct(12)
+	if st.has(3) {
+		c.nulls += st.int(3)
+	} else {
+		c.nullsUnknown = true
+	}
+	c.distinct = st.int(4)
+	// min_value and max_value replaced min and max, which sorted bytes as signed
+	lo, hi := st.bytes(6), st.bytes(5)
+	if !st.has(6) || !st.has(5) {
+		lo, hi = st.bytes(2), st.bytes(1)
+		if !st.has(2) || !st.has(1) {
+			// a group of nothing but nulls has no range, anything else
+			// without one leaves the file's unknown
+			if !st.has(3) || st.int(3) != m.int(5) {
+				c.rangeUnknown = true
+			}
+			return
+		}
+	}
+	low, ok := c.plainValue(lo)
+	high, ok2 := c.plainValue(hi)
+	if !ok || !ok2 {
+		c.rangeUnknown = true
+		return
+	}
+	if c.min == nil || c.compare(low, c.min) < 0 {
+		c.min = low
+	}
+	if c.max == nil || c.compare(high, c.max) > 0 {
+		c.max = high
+	}
+}
+
+// Stats is what a column's statistics say over the whole file. Writers don't
+// have to keep any of them, so each has a flag for whether it's known.
+type Stats struct {
+	Min, Max    string
+	HasRange    bool
+	Nulls       int64
+	HasNulls    bool
+	Distinct    int64
+	HasDistinct bool
+}
+
+// Stats sums up the statistics from every row group
+func (c *Column) Stats() Stats {
+	s := Stats{Nulls: c.nulls, HasNulls: c.groups > 0 && !c.nullsUnknown}
+	if c.min != nil && !c.rangeUnknown {
+		s.Min, s.Max, s.HasRange = c.Format(c.min), c.Format(c.max), true
+	}
+	// distinct counts can't be added up across groups
+	if c.groups == 1 && c.distinct > 0 {
+		s.Distinct, s.HasDistinct = c.distinct, true
+	}
+	return s
+}
+
+// int96 is the old timestamp type, nanoseconds into a julian day
+type int96 [12]byte
+
+// plainValue decodes a single plain encoded value without a length prefix, as
+// statistics are stored
+func (c *Column) plainValue(b []byte) (any, bool) {
+	switch c.physical {
+	case physBoolean:
+		return len(b) > 0 && b[0] != 0, len(b) == 1
+	case physInt32:
+		if len(b) == 4 {
+			return int32(binary.LittleEndian.Uint32(b)), true
+		}
+	case physInt64:
+		if len(b) == 8 {
+			return int64(binary.LittleEndian.Uint64(b)), true
+		}
+	case physInt96:
+		var v int96
+		return v, copy(v[:], b) == 12
+	case physFloat:
+		if len(b) == 4 {
+			return math.Float32frombits(binary.LittleEndian.Uint32(b)), true
+		}
+	case physDouble:
+		if len(b) == 8 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b)), true
+		}
+	case physByteArray, physFixed:
+		return b, true
+	}
+	return nil, false
+}
+
+// compare orders two values of the column
+func (c *Column) compare(a any, b any) int {
+	unsigned := c.logical.kind == kindInt && !c.logical.signed
+	switch a := a.(type) {
+	case bool:
+		return cmp.Compare(boolInt(a), boolInt(b.(bool)))
+	case int32:
+		if unsigned {
+			return cmp.Compare(uint32(a), uint32(b.(int32)))
+		}
+		return cmp.Compare(a, b.(int32))
+	case int64:
+		if unsigned {
+			return cmp.Compare(uint64(a), uint64(b.(int64)))
+		}
+		return cmp.Compare(a, b.(int64))
+	case float32:
+		return cmp.Compare(a, b.(float32))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case []byte:
+		if c.logical.kind == kindDecimal {
+			return signedBytes(a).Cmp(signedBytes(b.([]byte)))
+		}
+		return bytes.Compare(a, b.([]byte))
+	case int96:
+		return int96Time(a).Compare(int96Time(b.(int96)))
+	}
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// what a column's values are, beyond how they're stored
+const (
+	kindNone = iota
+	kindString
+	kindEnum
+	kindJSON
+	kindBSON
+	kindUUID
+	kindDate
+	kindTime
+	kindTimestamp
+	kindDecimal
+	kindInt
+	kindInterval
+	kindFloat16
+)
+
+// time units
+const (
+	millis = 1
+	micros = 2
+	nanos  = 3
+)
+
+var unitNames = []string{"", "MILLIS", "MICROS", "NANOS"}
+
+// logical is a column's logical type, or its converted type in older files
+type logical struct {
+	kind      int
+	unit      int64
+	scale     int
+	precision int
+	bits      int
+	signed    bool
+}
+
+// logicalOf reads a SchemaElement's logical type, falling back on the
+// converted type older writers use
+func logicalOf(e thriftStruct) logical {
+	if lt := e.strct(10); len(lt) > 0 {
+		switch {
+		case lt.has(1):
+			return logical{kind: kindString}
+		case lt.has(4):
+			return logical{kind: kindEnum}
+		case lt.has(5):
+			d := lt.strct(5)
+			return logical{kind: kindDecimal, scale: int(d.int(1)), precision: int(d.int(2))}
+		case lt.has(6):
+			return logical{kind: kindDate}
+		case lt.has(7):
+			return logical{kind: kindTime, unit: unitOf(lt.strct(7).strct(2))}
+		case lt.has(8):
+			return logical{kind: kindTimestamp, unit: unitOf(lt.strct(8).strct(2))}
+		case lt.has(10):
+			i := lt.strct(10)
+			return logical{kind: kindInt, bits: int(i.int(1)), signed: i.boolean(2, true)}
+		case lt.has(12):
+			return logical{kind: kindJSON}
+		case lt.has(13):
+			return logical{kind: kindBSON}
+		case lt.has(14):
+			return logical{kind: kindUUID}
+		case lt.has(15):
+			return logical{kind: kindFloat16}
+		}
+	}
+	if !e.has(6) {
+		return logical{}
+	}
+	switch converted := e.int(6); {
+	case converted == 0:
+		return logical{kind: kindString}
+	case converted == 4:
+		return logical{kind: kindEnum}
+	case converted == 5:
+		return logical{kind: kindDecimal, scale: int(e.int(7)), precision: int(e.int(8))}
+	case converted == 6:
+		return logical{kind: kindDate}
+	case converted == 7, converted == 8:
+		return logical{kind: kindTime, unit: converted - 6}
+	case converted == 9, converted == 10:
+		return logical{kind: kindTimestamp, unit: converted - 8}
+	case converted >= 11 && converted <= 14:
+		return logical{kind: kindInt, bits: 8 << (converted - 11)}
+	case converted >= 15 && converted <= 18:
+		return logical{kind: kindInt, bits: 8 << (converted - 15), signed: true}
+	case converted == 19:
+		return logical{kind: kindJSON}
+	case converted == 20:
+		return logical{kind: kindBSON}
+	case converted == 21:
+		return logical{kind: kindInterval}
+	}
+	return logical{}
+}
+
+func unitOf(u thriftStruct) int64 {
+	switch {
+	case u.has(1):
+		return millis
+	case u.has(3):
+		return nanos
+	}
+	return micros
+}
+
+func (l logical) String() string {
+	switch l.kind {
+	case kindString:
+		return "STRING"
+	case kindEnum:
+		return "ENUM"
+	case kindJSON:
+		return "JSON"
+	case kindBSON:
+		return "BSON"
+	case kindUUID:
+		return "UUID"
+	case kindDate:
+		return "DATE"
+	case kindTime:
+		return "TIME(" + unitNames[l.unit] + ")"
+	case kindTimestamp:
+		return "TIMESTAMP(" + unitNames[l.unit] + ")"
+	case kindDecimal:
+		return fmt.Sprintf("DECIMAL(%d,%d)", l.precision, l.scale)
+	case kindInt:
+		if l.signed {
+			return fmt.Sprintf("INT%d", l.bits)
+		}
+		return fmt.Sprintf("UINT%d", l.bits)
+	case kindInterval:
+		return "INTERVAL"
+	case kindFloat16:
+		return "FLOAT16"
+	}
+	return ""
+}
+
+const timestampLayout = "2006-01-02 15:04:05.999999999"
+
+// Format shows a value read from the column, nil being null
+func (c *Column) Format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case int32:
+		if c.logical.kind == kindInt && !c.logical.signed {
+			return strconv.FormatUint(uint64(uint32(v)), 10)
+		}
+		return c.formatInt(int64(v))
+	case int64:
+		if c.logical.kind == kindInt && !c.logical.signed {
+			return strconv.FormatUint(uint64(v), 10)
+		}
+		return c.formatInt(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int96:
+		return int96Time(v).Format(timestampLayout)
+	case []byte:
+		return c.formatBytes(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func (c *Column) formatInt(n int64) string {
+	switch c.logical.kind {
+	case kindDate:
+		return time.Unix(n*24*60*60, 0).UTC().Format("2006-01-02")
+	case kindTimestamp:
+		return instant(n, c.logical.unit).Format(timestampLayout)
+	case kindTime:
+		return time.Time{}.Add(time.Duration(n) * unitDuration(c.logical.unit)).Format("15:04:05.999999999")
+	case kindDecimal:
+		return decimal(big.NewInt(n), c.logical.scale)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (c *Column) formatBytes(b []byte) string {
+	switch c.logical.kind {
+	case kindDecimal:
+		return decimal(signedBytes(b), c.logical.scale)
+	case kindUUID:
+		if len(b) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+		}
+	case kindString, kindEnum, kindJSON:
+		return string(b)
+	}
+	if c.physical == physByteArray && utf8.Valid(b) {
+		return string(b)
+	}
+	return "0x" + hex.EncodeToString(b)
+}
+
+func unitDuration(unit int64) time.Duration {
+	switch unit {
+	case millis:
+		return time.Millisecond
+	case nanos:
+		return time.Nanosecond
+	}
+	return time.Microsecond
+}
+
+// instant is a timestamp n units after the epoch
+func instant(n int64, unit int64) time.Time {
+	switch unit {
+	case millis:
+		return time.UnixMilli(n).UTC()
+	case nanos:
+		return time.Unix(0, n).UTC()
+	}
+	return time.UnixMicro(n).UTC()
+}
+
+// julianEpoch is the julian day of 1970-01-01
+const julianEpoch = 2440588
+
+func int96Time(v int96) time.Time {
+	nanos := int64(binary.LittleEndian.Uint64(v[:8]))
+	day := int64(binary.LittleEndian.Uint32(v[8:]))
+	return time.Unix((day-julianEpoch)*24*60*60, nanos).UTC()
+}
+
+// signedBytes reads a big endian two's complement number, as decimals stored
+// in byte arrays are
+func signedBytes(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
+
+// decimal puts the point scale digits from the right of n
+func decimal(n *big.Int, scale int) string {
+	s := new(big.Int).Abs(n).String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if n.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,214 @@
+// Package parquet reads enough of a Parquet file to describe it and show its
+// first rows: the footer, with the schema, row groups and column statistics,
+// and the first pages of each column. Everything is read through an
+// io.ReaderAt, so only those parts of the file are fetched.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const magic = "PAR1"
+
+// footerBytes is how much of the end of a file Open reads first, which is
+// usually enough to take in all the metadata as well as its length
+var footerBytes int64 = 64 << 10
+
+// maxMetadata is more metadata than any sensible file has, past it the footer
+// is taken to be corrupt rather than read
+const maxMetadata = 64 << 20
+
+// File is a Parquet file's metadata
+type File struct {
+	r         io.ReaderAt
+	Size      int64
+	Version   int64
+	NumRows   int64
+	CreatedBy string
+	// the size of the metadata in the footer
+	MetadataSize int64
+	// the leaf columns, the ones holding values, in the order they're stored
+	Columns   []*Column
+	RowGroups []RowGroup
+}
+
+// RowGroup is a run of rows stored together, a chunk for each column
+type RowGroup struct {
+	NumRows    int64
+	TotalBytes int64
+	chunks     []chunk
+}
+
+// chunk is where a column's values for one row group are
+type chunk struct {
+	codec  int64
+	start  int64
+	size   int64
+	values int64
+}
+
+// readFull reads n bytes at offset, or fails
+func readFull(r io.ReaderAt, offset int64, n int64) ([]byte, error) {
+	if n < 0 || offset < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	got, err := r.ReadAt(buf, offset)
+	if int64(got) == n {
+		return buf, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
+// Open reads the metadata from the end of a file of size bytes
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, errors.New("parquet: too small to be a parquet file")
+	}
+	tail := footerBytes
+	if tail > size {
+		tail = size
+	}
+	buf, err := readFull(r, size-tail, tail)
+	if err != nil {
+		return nil, err
+	}
+	switch string(buf[tail-4:]) {
+	case magic:
+	case "PARE":
+		return nil, errors.New("parquet: the footer is encrypted")
+	default:
+		return nil, errors.New("parquet: no PAR1 at the end, it may be cut short")
+	}
+	n := int64(binary.LittleEndian.Uint32(buf[tail-8:]))
+	if n > size-12 {
+		return nil, fmt.Errorf("parquet: footer says the metadata is %d bytes, more than the file", n)
+	}
+	if n > maxMetadata {
+		return nil, fmt.Errorf("parquet: footer says the metadata is %d bytes, too much to be right", n)
+	}
+	var meta []byte
+	if n+8 <= tail {
+		meta = buf[tail-8-n : tail-8]
+	} else if meta, err = readFull(r, size-8-n, n); err != nil {
+		return nil, err
+	}
+	st, _, err := decodeStruct(meta)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{r: r, Size: size, MetadataSize: n}
+	if err := f.load(st); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load fills in f from the FileMetaData struct
+func (f *File) load(st thriftStruct) error {
+	f.Version = st.int(1)
+	f.NumRows = st.int(3)
+	f.CreatedBy = st.str(6)
+	if f.NumRows < 0 {
+		return fmt.Errorf("parquet: the metadata says there are %d rows", f.NumRows)
+	}
+	schema := st.structs(2)
+	if len(schema) == 0 {
+		return errors.New("parquet: no schema in the metadata")
+	}
+	if _, err := f.walk(schema, 0, nil, 0, 0); err != nil {
+		return err
+	}
+
+	for _, g := range st.structs(4) {
+		group := RowGroup{NumRows: g.int(3), TotalBytes: g.int(2)}
+		if group.NumRows < 0 {
+			return fmt.Errorf("parquet: a row group says it has %d rows", group.NumRows)
+		}
+		for i, c := range g.structs(1) {
+			m := c.strct(3)
+			if i >= len(f.Columns) || m == nil {
+				continue
+			}
+			ch := chunk{codec: m.int(4), start: m.int(9), size: m.int(7), values: m.int(5)}
+			// writers that don't write a dictionary put 0 here, or leave it out
+			if dict := m.int(11); dict > 0 && dict < ch.start {
+				ch.start = dict
+			}
+			group.chunks = append(group.chunks, ch)
+			f.Columns[i].add(m)
+		}
+		f.RowGroups = append(f.RowGroups, group)
+	}
+	return nil
+}
+
+// walk goes through the schema, a tree stored depth first, from elements[i]
+// down. path, def and rep are those of the group it's in. It returns where the
+// next sibling is.
+func (f *File) walk(elements []thriftStruct, i int, path []string, def int, rep int) (int, error) {
+	e := elements[i]
+	children := int(e.int(5))
+	if len(path) > maxDepth {
+		return 0, errors.New("parquet: the schema is nested too deep")
+	}
+	if i > 0 {
+		path = append(path[:len(path):len(path)], e.str(4))
+		switch e.int(3) {
+		case optional:
+			def++
+		case repeated:
+			def++
+			rep++
+		}
+	}
+	if i > 0 && children == 0 {
+		f.Columns = append(f.Columns, newColumn(e, path, def, rep))
+		return i + 1, nil
+	}
+	next := i + 1
+	for c := 0; c < children; c++ {
+		if next >= len(elements) {
+			return 0, errors.New("parquet: the schema is missing columns")
+		}
+		var err error
+		if next, err = f.walk(elements, next, path, def, rep); err != nil {
+			return 0, err
+		}
+	}
+	return next, nil
+}
+
+// Codecs are the compression used by each column, e.g. "SNAPPY"
+func (f *File) Codecs() []string {
+	var codecs []string
+	for _, c := range f.Columns {
+		for _, codec := range c.Codecs {
+			if !contains(codecs, codec) {
+				codecs = append(codecs, codec)
+			}
+		}
+	}
+	return codecs
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Name is the column's path through the schema, e.g. "address.city"
+func (c *Column) Name() string {
+	return strings.Join(c.Path, ".")
+}
@@ -0,0 +1,430 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encodeStruct writes s with the compact protocol, the other half of
+// decodeStruct, for building files to read back. Ints are written as i64,
+// which reads the same as the smaller ones.
+func encodeStruct(b *bytes.Buffer, s thriftStruct) {
+	var ids []int
+	for id := range s {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	last := 0
+	for _, id := range ids {
+		v := s[int16(id)]
+		typ := typeFor(v)
+		if delta := id - last; delta > 0 && delta <= 15 {
+			b.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			b.WriteByte(typ)
+			b.Write(binary.AppendUvarint(nil, zigzag(int64(id))))
+		}
+		last = id
+		if _, ok := v.(bool); !ok {
+			encodeValue(b, v)
+		}
+	}
+	b.WriteByte(typeStop)
+}
+
+func zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func typeFor(v any) byte {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return typeTrue
+		}
+		return typeFalse
+	case int, int64:
+		return typeI64
+	case string, []byte:
+		return typeBinary
+	case []any:
+		return typeList
+	}
+	return typeStruct
+}
+
+func encodeValue(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case bool:
+		b.WriteByte(typeFor(v))
+	case int:
+		b.Write(binary.AppendUvarint(nil, zigzag(int64(v))))
+	case int64:
+		b.Write(binary.AppendUvarint(nil, zigzag(v)))
+	case string:
+		encodeValue(b, []byte(v))
+	case []byte:
+		b.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		b.Write(v)
+	case []any:
+		typ := byte(typeStruct)
+		if len(v) > 0 {
+			typ = typeFor(v[0])
+		}
+		if len(v) < 15 {
+			b.WriteByte(byte(len(v))<<4 | typ)
+		} else {
+			b.WriteByte(0xf0 | typ)
+			b.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		}
+		for _, e := range v {
+			encodeValue(b, e)
+		}
+	case thriftStruct:
+		encodeStruct(b, v)
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	s := thriftStruct{
+		1:   int64(-3),
+		2:   []byte("name"),
+		3:   true,
+		4:   false,
+		5:   []any{int64(1), int64(-2)},
+		40:  thriftStruct{1: []byte("deep")},
+		41:  make([]any, 20),
+		300: int64(1 << 40),
+	}
+	for i := range s[41].([]any) {
+		s[41].([]any)[i] = thriftStruct{1: int64(i)}
+	}
+	var b bytes.Buffer
+	encodeStruct(&b, s)
+	b.WriteString("after")
+	got, n, err := decodeStruct(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("got %v\nwant %v", got, s)
+	}
+	if n != b.Len()-len("after") {
+		t.Errorf("used %d bytes of %d", n, b.Len())
+	}
+
+	if _, _, err := decodeStruct(b.Bytes()[:n-3]); err != errShort {
+		t.Errorf("cut short gave %v", err)
+	}
+}
+
+func TestSnappyDecode(t *testing.T) {
+	// "ab" then a copy of it three times over, which overlaps itself
+	src := []byte{8, 1 << 2, 'a', 'b', 1 | 2<<2, 2}
+	got, err := snappyDecode(src)
+	if err != nil || string(got) != "abababab" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := snappyDecode([]byte{8, 1 << 2, 'a', 'b', 1 | 2<<2, 9}); err == nil {
+		t.Error("a copy from before the start was allowed")
+	}
+	lit := snappyLiteral([]byte(strings.Repeat("x", 100)))
+	if got, err := snappyDecode(lit); err != nil || string(got) != strings.Repeat("x", 100) {
+		t.Errorf("long literal gave %q, %v", got, err)
+	}
+}
+
+// snappyLiteral compresses data as one literal, which is valid if not small
+func snappyLiteral(data []byte) []byte {
+	out := binary.AppendUvarint(nil, uint64(len(data)))
+	if len(data) <= 60 {
+		out = append(out, byte(len(data)-1)<<2)
+	} else {
+		out = append(out, 61<<2, byte(len(data)-1), byte((len(data)-1)>>8))
+	}
+	return append(out, data...)
+}
+
+func TestHybrid(t *testing.T) {
+	// a run of five 3s, then eight values packed two bits each
+	data := []byte{5 << 1, 3, 1<<1 | 1, 0b11100100, 0b00011011}
+	got, err := hybrid(data, 2, 12)
+	want := []int{3, 3, 3, 3, 3, 0, 1, 2, 3, 3, 2, 1}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := hybrid(data[:3], 2, 12); err != errPageShort {
+		t.Errorf("cut short gave %v", err)
+	}
+}
+
+// countingReader counts how many reads go to the file
+type countingReader struct {
+	*bytes.Reader
+	reads int
+	bytes int
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	n, err := c.Reader.ReadAt(p, off)
+	c.bytes += n
+	return n, err
+}
+
+func le64(values ...int64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+	return b
+}
+
+func le32(values ...int32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+// page is a page header and its body
+func page(header thriftStruct, body []byte) []byte {
+	var b bytes.Buffer
+	encodeStruct(&b, header)
+	b.Write(body)
+	return b.Bytes()
+}
+
+// testFile is three rows of four columns, each stored a different way:
+//
+//	id     required INT64, plain
+//	name   optional STRING, snappy, with a dictionary and a null
+//	price  required DECIMAL(9,2) in an INT32, in a v2 page
+//	at     optional TIMESTAMP(MILLIS), zstd which can't be read
+func testFile() []byte {
+	var file bytes.Buffer
+	file.WriteString(magic)
+	var columns []any
+	chunk := func(codec int, stats thriftStruct, path string, typ int, pages ...[]byte) {
+		start := file.Len()
+		for _, p := range pages {
+			file.Write(p)
+		}
+		columns = append(columns, thriftStruct{3: thriftStruct{
+			1: typ, 2: []any{0, 8}, 3: []any{path}, 4: codec, 5: 3,
+			6: file.Len() - start, 7: file.Len() - start, 9: start, 12: stats,
+		}})
+	}
+
+	ids := le64(1, 2, 3)
+	chunk(0, thriftStruct{3: 0, 5: le64(3), 6: le64(1)}, "id", physInt64,
+		page(thriftStruct{1: dataPage, 2: len(ids), 3: len(ids), 5: thriftStruct{1: 3, 2: plain}}, ids))
+
+	dict := append(append(le32(3), "ann"...), append(le32(3), "bob"...)...)
+	// levels 1 0 1 packed a bit each, then indexes 0 1 packed likewise
+	data := []byte{2, 0, 0, 0, 1<<1 | 1, 0b101, 1, 1<<1 | 1, 0b10}
+	chunk(snappy, thriftStruct{3: 1, 4: 2, 5: []byte("bob"), 6: []byte("ann")}, "name", physByteArray,
+		page(thriftStruct{1: dictionaryPage, 2: len(dict), 3: len(snappyLiteral(dict)), 7: thriftStruct{1: 2, 2: plain}}, snappyLiteral(dict)),
+		page(thriftStruct{1: dataPage, 2: len(data), 3: len(snappyLiteral(data)), 5: thriftStruct{1: 3, 2: rleDictionary}}, snappyLiteral(data)))
+
+	prices := le32(1999, -5, 100000)
+	chunk(0, nil, "price", physInt32,
+		page(thriftStruct{1: dataPageV2, 2: len(prices), 3: len(prices), 8: thriftStruct{1: 3, 2: 0, 3: 3, 4: plain, 5: 0, 6: 0, 7: false}}, prices))
+
+	chunk(6, thriftStruct{3: 0, 5: le64(86400000), 6: le64(0)}, "at", physInt64, []byte("not really zstd"))
+
+	var meta bytes.Buffer
+	encodeStruct(&meta, thriftStruct{
+		1: 1,
+		2: []any{
+			thriftStruct{4: "schema", 5: 4},
+			thriftStruct{1: physInt64, 3: required, 4: "id"},
+			thriftStruct{1: physByteArray, 3: optional, 4: "name", 6: 0},
+			thriftStruct{1: physInt32, 3: required, 4: "price", 6: 5, 7: 2, 8: 9},
+			thriftStruct{1: physInt64, 3: optional, 4: "at", 10: thriftStruct{8: thriftStruct{1: true, 2: thriftStruct{1: thriftStruct{}}}}},
+		},
+		3: 3,
+		4: []any{thriftStruct{1: columns, 2: file.Len(), 3: 3}},
+		6: "hand written",
+	})
+	file.Write(meta.Bytes())
+	file.Write(le32(int32(meta.Len())))
+	file.WriteString(magic)
+	return file.Bytes()
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/people.parquet")
+
+// testdata/people.parquet is testFile, for the gui's tests to preview
+func TestTestdata(t *testing.T) {
+	if *update {
+		if err := os.WriteFile("testdata/people.parquet", testFile(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile("testdata/people.parquet")
+	if err != nil || !bytes.Equal(data, testFile()) {
+		t.Errorf("testdata/people.parquet is out of date, run go test -update (%v)", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	data := testFile()
+	r := &countingReader{Reader: bytes.NewReader(data)}
+	f, err := Open(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.reads != 1 {
+		t.Errorf("took %d reads to get the metadata, want 1", r.reads)
+	}
+	if f.NumRows != 3 || len(f.RowGroups) != 1 || f.CreatedBy != "hand written" {
+		t.Errorf("got %d rows in %d groups by %q", f.NumRows, len(f.RowGroups), f.CreatedBy)
+	}
+	if codecs := f.Codecs(); !reflect.DeepEqual(codecs, []string{"UNCOMPRESSED", "SNAPPY", "ZSTD"}) {
+		t.Errorf("codecs = %v", codecs)
+	}
+
+	tests := []struct {
+		name, typ, logical, repetition string
+		stats                          Stats
+	}{
+		{"id", "INT64", "", "required", Stats{Min: "1", Max: "3", HasRange: true, HasNulls: true}},
+		{"name", "BYTE_ARRAY", "STRING", "optional", Stats{Min: "ann", Max: "bob", HasRange: true, Nulls: 1, HasNulls: true, Distinct: 2, HasDistinct: true}},
+		{"price", "INT32", "DECIMAL(9,2)", "required", Stats{}},
+		{"at", "INT64", "TIMESTAMP(MILLIS)", "optional", Stats{Min: "1970-01-01 00:00:00", Max: "1970-01-02 00:00:00", HasRange: true, HasNulls: true}},
+	}
+	if len(f.Columns) != len(tests) {
+		t.Fatalf("got %d columns", len(f.Columns))
+	}
+	for i, tt := range tests {
+		c := f.Columns[i]
+		if c.Name() != tt.name || c.Type != tt.typ || c.Logical != tt.logical || c.Repetition != tt.repetition {
+			t.Errorf("column %d is %s %s %s %s", i, c.Name(), c.Type, c.Logical, c.Repetition)
+		}
+		if got := c.Stats(); got != tt.stats {
+			t.Errorf("%s stats = %+v, want %+v", tt.name, got, tt.stats)
+		}
+	}
+
+	// with a short first read the metadata takes a second
+	old := footerBytes
+	footerBytes = 16
+	defer func() { footerBytes = old }()
+	r = &countingReader{Reader: bytes.NewReader(data)}
+	if _, err := Open(r, int64(len(data))); err != nil || r.reads != 2 {
+		t.Errorf("got %v after %d reads", err, r.reads)
+	}
+}
+
+func TestOpenNotParquet(t *testing.T) {
+	for _, data := range []string{"PAR1", "PAR1 just some text", "PAR1\x00\x00\x00\x00PARE", "PAR1\xff\xff\x00\x00PAR1"} {
+		if _, err := Open(strings.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%q opened", data)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	data := testFile()
+	r := &countingReader{Reader: bytes.NewReader(data)}
+	f, err := Open(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := f.Rows(10)
+	want := [][]string{
+		{"1", "ann", "19.99", ""},
+		{"2", "null", "-0.05", ""},
+		{"3", "bob", "1000.00", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q", rows)
+	}
+	if err == nil || !strings.Contains(err.Error(), "at: ZSTD compression isn't supported") {
+		t.Errorf("error = %v", err)
+	}
+
+	rows, _ = f.Rows(1)
+	if len(rows) != 1 || rows[0][1] != "ann" {
+		t.Errorf("first row = %q", rows)
+	}
+}
+
+func TestStatsAcrossGroups(t *testing.T) {
+	c := newColumn(thriftStruct{1: int64(physInt32), 3: int64(optional), 6: int64(13)}, []string{"n"}, 1, 0)
+	c.add(thriftStruct{4: int64(1), 5: int64(2), 12: thriftStruct{3: int64(0), 5: le32(-1), 6: le32(5)}})
+	c.add(thriftStruct{4: int64(1), 5: int64(2), 12: thriftStruct{3: int64(2)}})
+	c.add(thriftStruct{4: int64(1), 5: int64(2), 12: thriftStruct{3: int64(1), 5: le32(7), 6: le32(2)}})
+	// unsigned, so -1 is the biggest there is
+	want := Stats{Min: "2", Max: "4294967295", HasRange: true, Nulls: 3, HasNulls: true}
+	if got := c.Stats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if c.Logical != "UINT32" || !reflect.DeepEqual(c.Codecs, []string{"SNAPPY"}) {
+		t.Errorf("%s %v", c.Logical, c.Codecs)
+	}
+
+	// a group that didn't keep a range leaves the file's unknown
+	c.add(thriftStruct{4: int64(2), 5: int64(2)})
+	if got := c.Stats(); got.HasRange || got.HasNulls {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// readAll is everything the preview does with a file
+func readAll(data []byte) {
+	f, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return
+	}
+	f.Codecs()
+	for _, c := range f.Columns {
+		c.Stats()
+	}
+	f.Rows(50)
+}
+
+func FuzzOpen(f *testing.F) {
+	f.Add(testFile())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		readAll(data)
+	})
+}
+
+// corrupt files, as a broken upload or a bad writer leaves them, are errors
+// rather than panics
+func TestCorruptFiles(t *testing.T) {
+	good := testFile()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		data := append([]byte{}, good...)
+		for n := rng.Intn(4) + 1; n > 0; n-- {
+			at := rng.Intn(len(data))
+			switch rng.Intn(3) {
+			case 0:
+				data[at] = byte(rng.Intn(256))
+			case 1:
+				data[at] ^= 0x80
+			default:
+				data[at] = 0xff
+			}
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("file %d: %v\n%q", i, r, data)
+				}
+			}()
+			readAll(data)
+		}()
+	}
+}
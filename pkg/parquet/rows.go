@@ -0,0 +1,539 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// page types
+const (
+	dataPage       = 0
+	dictionaryPage = 2
+	dataPageV2     = 3
+)
+
+// encodings of values
+const (
+	plain           = 0
+	plainDictionary = 2
+	rle             = 3
+	rleDictionary   = 8
+)
+
+// codecs this can decompress
+const (
+	uncompressed = 0
+	snappy       = 1
+	gzipCodec    = 2
+)
+
+// readAhead is how much of a column chunk is read at a time, enough for a page
+// header and usually the page too
+var readAhead int64 = 64 << 10
+
+// maxPageValues and maxPageBytes are more than any sensible page has, past
+// them the page header is taken to be corrupt rather than decoded
+const (
+	maxPageValues = 1 << 24
+	maxPageBytes  = 64 << 20
+)
+
+// Rows reads the first n rows as text, a column at a time, reading only as
+// many pages as that takes. Values in a column it can't read, for a codec or
+// encoding it doesn't know, are left blank and the error says why.
+func (f *File) Rows(n int) ([][]string, error) {
+	if int64(n) > f.NumRows {
+		n = int(f.NumRows)
+	}
+	if n < 0 {
+		n = 0
+	}
+	rows := make([][]string, n)
+	for i := range rows {
+		rows[i] = make([]string, len(f.Columns))
+	}
+	var errs []error
+	for i, c := range f.Columns {
+		values, err := f.columnValues(i, n)
+		for r := 0; r < len(values) && r < n; r++ {
+			if values[r] != unread {
+				rows[r][i] = c.Format(values[r])
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+		}
+	}
+	return rows, errors.Join(errs...)
+}
+
+// unread stands in for values that couldn't be read
+var unread any = errors.New("unread")
+
+// columnValues reads the first n values of column i, nil for nulls, going on
+// into the next row group if the first is short
+func (f *File) columnValues(i int, n int) ([]any, error) {
+	c := f.Columns[i]
+	var values []any
+	fill := func() []any {
+		for len(values) < n {
+			values = append(values, unread)
+		}
+		return values
+	}
+	if c.maxRep > 0 {
+		return fill(), errors.New("repeated columns aren't shown")
+	}
+	for _, g := range f.RowGroups {
+		if len(values) >= n {
+			break
+		}
+		if i >= len(g.chunks) {
+			return fill(), errors.New("no chunk for the column")
+		}
+		got, err := f.readChunk(c, g.chunks[i], n-len(values))
+		values = append(values, got...)
+		if err != nil {
+			return fill(), err
+		}
+	}
+	return values, nil
+}
+
+// readChunk decodes pages from the start of ch until it has want values
+func (f *File) readChunk(c *Column, ch chunk, want int) ([]any, error) {
+	switch ch.codec {
+	case uncompressed, snappy, gzipCodec:
+	default:
+		return nil, fmt.Errorf("%s compression isn't supported", nameOf(codecNames, ch.codec))
+	}
+	if ch.start < 0 || ch.size < 0 || ch.start > f.Size-ch.size {
+		return nil, errors.New("the column chunk is outside the file")
+	}
+	r := &chunkReader{r: f.r, pos: ch.start, end: ch.start + ch.size}
+	var dict, values []any
+	for len(values) < want && r.pos < r.end {
+		header, err := r.pageHeader()
+		if err != nil {
+			return values, err
+		}
+		compressed, size := header.int(3), int(header.int(2))
+		if compressed < 0 || compressed > maxPageBytes || size < 0 || size > maxPageBytes {
+			return values, fmt.Errorf("page says it's %d bytes, %d uncompressed", compressed, size)
+		}
+		body, err := r.take(compressed)
+		if err != nil {
+			return values, err
+		}
+		switch header.int(1) {
+		case dictionaryPage:
+			data, err := decompress(ch.codec, body, size)
+			if err != nil {
+				return values, err
+			}
+			count, err := pageCount(header.strct(7).int(1), maxPageValues)
+			if err != nil {
+				return values, err
+			}
+			if dict, err = c.plain(data, count); err != nil {
+				return values, err
+			}
+		case dataPage:
+			h := header.strct(5)
+			data, err := decompress(ch.codec, body, size)
+			if err != nil {
+				return values, err
+			}
+			count, err := pageCount(h.int(1), ch.values)
+			if err != nil {
+				return values, err
+			}
+			var defs []int
+			if c.maxDef > 0 {
+				if len(data) < 4 {
+					return values, errPageShort
+				}
+				n := int(binary.LittleEndian.Uint32(data))
+				if n > len(data)-4 {
+					return values, errPageShort
+				}
+				if defs, err = hybrid(data[4:4+n], bits.Len(uint(c.maxDef)), count); err != nil {
+					return values, err
+				}
+				data = data[4+n:]
+			}
+			page, err := c.page(data, h.int(2), defs, count, dict)
+			values = append(values, page...)
+			if err != nil {
+				return values, err
+			}
+		case dataPageV2:
+			h := header.strct(8)
+			count, err := pageCount(h.int(1), ch.values)
+			if err != nil {
+				return values, err
+			}
+			defLength, repLength := int(h.int(5)), int(h.int(6))
+			if defLength < 0 || repLength < 0 || defLength+repLength > len(body) {
+				return values, errPageShort
+			}
+			data := body[defLength+repLength:]
+			if h.boolean(7, true) {
+				if data, err = decompress(ch.codec, data, size-defLength-repLength); err != nil {
+					return values, err
+				}
+			}
+			var defs []int
+			if c.maxDef > 0 {
+				levels := body[repLength : repLength+defLength]
+				if defs, err = hybrid(levels, bits.Len(uint(c.maxDef)), count); err != nil {
+					return values, err
+				}
+			}
+			page, err := c.page(data, h.int(4), defs, count, dict)
+			values = append(values, page...)
+			if err != nil {
+				return values, err
+			}
+		}
+	}
+	return values, nil
+}
+
+var errPageShort = errors.New("page ends early")
+
+// pageCount checks the number of values a page header gives, which can't be
+// more than most, the values in its chunk
+func pageCount(n int64, most int64) (int, error) {
+	if n < 0 || n > most || n > maxPageValues {
+		return 0, fmt.Errorf("page says it has %d values", n)
+	}
+	return int(n), nil
+}
+
+// page decodes a data page's values, count of them including nulls. defs
+// are the definition levels, nil when nothing can be null.
+func (c *Column) page(data []byte, encoding int64, defs []int, count int, dict []any) ([]any, error) {
+	present := count
+	if defs != nil {
+		present = 0
+		for _, d := range defs {
+			if d == c.maxDef {
+				present++
+			}
+		}
+	}
+	var stored []any
+	var err error
+	switch {
+	case encoding == plain:
+		stored, err = c.plain(data, present)
+	case encoding == plainDictionary || encoding == rleDictionary:
+		if len(data) == 0 {
+			if present > 0 {
+				return nil, errPageShort
+			}
+			break
+		}
+		var ids []int
+		if ids, err = hybrid(data[1:], int(data[0]), present); err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if id >= len(dict) {
+				return nil, errors.New("dictionary index out of range")
+			}
+			stored = append(stored, dict[id])
+		}
+	case encoding == rle && c.physical == physBoolean:
+		if len(data) < 4 {
+			return nil, errPageShort
+		}
+		var bits []int
+		if bits, err = hybrid(data[4:], 1, present); err != nil {
+			return nil, err
+		}
+		for _, b := range bits {
+			stored = append(stored, b == 1)
+		}
+	default:
+		return nil, fmt.Errorf("%s encoding isn't supported", nameOf(encodingNames, encoding))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if defs == nil {
+		return stored, nil
+	}
+	values := make([]any, count)
+	j := 0
+	for i, d := range defs {
+		if d == c.maxDef && j < len(stored) {
+			values[i] = stored[j]
+			j++
+		}
+	}
+	return values, nil
+}
+
+// plain decodes count plain encoded values
+func (c *Column) plain(data []byte, count int) ([]any, error) {
+	size := map[int64]int{physInt32: 4, physInt64: 8, physInt96: 12, physFloat: 4, physDouble: 8, physFixed: c.length}[c.physical]
+	switch {
+	case c.physical == physBoolean:
+		if count > len(data)*8 {
+			return nil, errPageShort
+		}
+	case c.physical == physByteArray:
+		// every one has its length first
+		if count > len(data)/4 {
+			return nil, errPageShort
+		}
+	case size <= 0 || count > len(data)/size:
+		return nil, errPageShort
+	}
+	values := make([]any, 0, count)
+	for i := 0; i < count; i++ {
+		switch c.physical {
+		case physBoolean:
+			if i/8 >= len(data) {
+				return values, errPageShort
+			}
+			values = append(values, data[i/8]>>(i%8)&1 == 1)
+		case physInt32:
+			values = append(values, int32(binary.LittleEndian.Uint32(data[4*i:])))
+		case physInt64:
+			values = append(values, int64(binary.LittleEndian.Uint64(data[8*i:])))
+		case physInt96:
+			var v int96
+			copy(v[:], data[12*i:])
+			values = append(values, v)
+		case physFloat:
+			values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+		case physDouble:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:])))
+		case physByteArray:
+			if len(data) < 4 {
+				return values, errPageShort
+			}
+			n := int(binary.LittleEndian.Uint32(data))
+			if n > len(data)-4 {
+				return values, errPageShort
+			}
+			values = append(values, data[4:4+n])
+			data = data[4+n:]
+		case physFixed:
+			values = append(values, data[size*i:size*(i+1)])
+		}
+	}
+	return values, nil
+}
+
+// hybrid decodes count values of width bits from parquet's mix of run length
+// and bit packed runs
+func hybrid(data []byte, width int, count int) ([]int, error) {
+	if width > 32 {
+		return nil, fmt.Errorf("bit width %d is too wide", width)
+	}
+	var values []int
+	for len(values) < count {
+		header, k := binary.Uvarint(data)
+		if k <= 0 {
+			return values, errPageShort
+		}
+		data = data[k:]
+		if header>>1 > maxPageValues {
+			return values, fmt.Errorf("run of %d values is too long", header>>1)
+		}
+		if header&1 == 0 {
+			// a run of the same value
+			n, size := int(header>>1), (width+7)/8
+			if len(data) < size {
+				return values, errPageShort
+			}
+			var v int
+			for i := 0; i < size; i++ {
+				v |= int(data[i]) << (8 * i)
+			}
+			data = data[size:]
+			for i := 0; i < n && len(values) < count; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		// groups of eight values packed width bits each, lowest bits first
+		n := int(header>>1) * 8
+		if need := count - len(values); need < n && need*width > len(data)*8 || need >= n && n*width > len(data)*8 {
+			return values, errPageShort
+		}
+		for i := 0; i < n && len(values) < count; i++ {
+			var v int
+			for b := 0; b < width; b++ {
+				at := i*width + b
+				v |= int(data[at/8]>>(at%8)&1) << b
+			}
+			values = append(values, v)
+		}
+		if n*width/8 >= len(data) {
+			data = nil
+		} else {
+			data = data[n*width/8:]
+		}
+	}
+	return values, nil
+}
+
+func decompress(codec int64, data []byte, size int) ([]byte, error) {
+	switch codec {
+	case snappy:
+		return snappyDecode(data)
+	case gzipCodec:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.LimitReader(r, int64(size)))
+	}
+	return data, nil
+}
+
+// chunkReader reads through a column chunk, readAhead at a time
+type chunkReader struct {
+	r   io.ReaderAt
+	buf []byte
+	at  int64 // where buf starts in the file
+	pos int64
+	end int64
+}
+
+// peek is up to n bytes from pos, reading them if they aren't in buf
+func (c *chunkReader) peek(n int64) ([]byte, error) {
+	if c.pos+n > c.end {
+		n = c.end - c.pos
+	}
+	if c.pos < c.at || c.pos+n > c.at+int64(len(c.buf)) {
+		size := n
+		if size < readAhead {
+			size = readAhead
+		}
+		if c.pos+size > c.end {
+			size = c.end - c.pos
+		}
+		buf, err := readFull(c.r, c.pos, size)
+		if err != nil {
+			return nil, err
+		}
+		c.buf, c.at = buf, c.pos
+	}
+	return c.buf[c.pos-c.at : c.pos-c.at+n], nil
+}
+
+// take is the next n bytes
+func (c *chunkReader) take(n int64) ([]byte, error) {
+	if n < 0 || c.pos+n > c.end {
+		return nil, errPageShort
+	}
+	b, err := c.peek(n)
+	if err != nil {
+		return nil, err
+	}
+	c.pos += n
+	return b, nil
+}
+
+// pageHeader reads the PageHeader struct at pos, reading further if it's
+// bigger than expected, as it can be with statistics in it
+func (c *chunkReader) pageHeader() (thriftStruct, error) {
+	for n := int64(1 << 10); ; n *= 4 {
+		b, err := c.peek(n)
+		if err != nil {
+			return nil, err
+		}
+		header, used, err := decodeStruct(b)
+		if err == errShort && int64(len(b)) == n {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.pos += int64(used)
+		return header, nil
+	}
+}
+
+// snappyDecode decompresses a snappy block, a run of literals and copies of
+// what's come before
+func snappyDecode(src []byte) ([]byte, error) {
+	errCorrupt := errors.New("snappy data is corrupt")
+	size, k := binary.Uvarint(src)
+	if k <= 0 || size > maxPageBytes {
+		return nil, errCorrupt
+	}
+	src = src[k:]
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, errCorrupt
+				}
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			length++
+			if length > len(src) {
+				return nil, errCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errCorrupt
+			}
+			length = 4 + int(tag>>2&7)
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > size {
+			return nil, errCorrupt
+		}
+		// copies can overlap what they're writing, so a byte at a time
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
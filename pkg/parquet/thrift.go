@@ -0,0 +1,245 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errShort is a struct running off the end of what's been read, which for a
+// page header means reading more and trying again
+var errShort = errors.New("parquet: metadata ends early")
+
+// thrift compact protocol types
+const (
+	typeStop   = 0
+	typeTrue   = 1
+	typeFalse  = 2
+	typeByte   = 3
+	typeI16    = 4
+	typeI32    = 5
+	typeI64    = 6
+	typeDouble = 7
+	typeBinary = 8
+	typeList   = 9
+	typeSet    = 10
+	typeMap    = 11
+	typeStruct = 12
+)
+
+// maxDepth stops a corrupt footer nesting structs until the stack runs out
+const maxDepth = 64
+
+// thriftStruct is a decoded struct, its fields by id. Parquet's metadata is
+// read into these and picked apart, rather than into a type per struct.
+// Integers are int64, binary is []byte, lists are []any and nested structs
+// are thriftStructs.
+type thriftStruct map[int16]any
+
+func (s thriftStruct) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s thriftStruct) int(id int16) int64 {
+	n, _ := s[id].(int64)
+	return n
+}
+
+func (s thriftStruct) bytes(id int16) []byte {
+	b, _ := s[id].([]byte)
+	return b
+}
+
+func (s thriftStruct) str(id int16) string {
+	return string(s.bytes(id))
+}
+
+func (s thriftStruct) boolean(id int16, def bool) bool {
+	if b, ok := s[id].(bool); ok {
+		return b
+	}
+	return def
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	t, _ := s[id].(thriftStruct)
+	return t
+}
+
+func (s thriftStruct) list(id int16) []any {
+	l, _ := s[id].([]any)
+	return l
+}
+
+// structs is a list of structs, skipping anything else in it
+func (s thriftStruct) structs(id int16) []thriftStruct {
+	var out []thriftStruct
+	for _, v := range s.list(id) {
+		if t, ok := v.(thriftStruct); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// decodeStruct reads a compact protocol struct from the start of b, returning
+// it and how many bytes it took up
+func decodeStruct(b []byte) (thriftStruct, int, error) {
+	d := &decoder{b: b}
+	s := d.structure(0)
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+	return s, d.pos, nil
+}
+
+type decoder struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.b) {
+		d.fail(errShort)
+		return 0
+	}
+	c := d.b[d.pos]
+	d.pos++
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, k := binary.Uvarint(d.b[d.pos:])
+	switch {
+	case k == 0:
+		d.fail(errShort)
+	case k < 0:
+		d.fail(errors.New("parquet: bad varint in metadata"))
+	default:
+		d.pos += k
+	}
+	return n
+}
+
+// varint is a zigzag encoded int
+func (d *decoder) varint() int64 {
+	n := d.uvarint()
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+func (d *decoder) binary() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)-d.pos) {
+		d.fail(errShort)
+		return nil
+	}
+	b := d.b[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b
+}
+
+func (d *decoder) structure(depth int) thriftStruct {
+	if depth > maxDepth {
+		d.fail(errors.New("parquet: metadata nested too deep"))
+		return nil
+	}
+	s := thriftStruct{}
+	var id int16
+	for d.err == nil {
+		header := d.byte()
+		typ := header & 0x0f
+		if typ == typeStop {
+			break
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(d.varint())
+		}
+		switch typ {
+		case typeTrue, typeFalse:
+			// a bool field's value is in its type
+			s[id] = typ == typeTrue
+		default:
+			s[id] = d.value(typ, depth+1)
+		}
+	}
+	return s
+}
+
+func (d *decoder) value(typ byte, depth int) any {
+	switch typ {
+	case typeTrue, typeFalse:
+		// in lists a bool takes a byte of its own
+		return d.byte() == typeTrue
+	case typeByte:
+		return int64(int8(d.byte()))
+	case typeI16, typeI32, typeI64:
+		return d.varint()
+	case typeDouble:
+		if len(d.b)-d.pos < 8 {
+			d.fail(errShort)
+			return 0.0
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(d.b[d.pos:]))
+		d.pos += 8
+		return f
+	case typeBinary:
+		return d.binary()
+	case typeList, typeSet:
+		header := d.byte()
+		n := uint64(header >> 4)
+		if n == 15 {
+			n = d.uvarint()
+		}
+		return d.elements(header&0x0f, n, depth)
+	case typeMap:
+		n := d.uvarint()
+		if n == 0 {
+			return []any{}
+		}
+		types := d.byte()
+		// kept as keys and values one after the other, nothing in parquet's
+		// metadata is a map
+		var out []any
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			out = append(out, d.value(types>>4, depth+1), d.value(types&0x0f, depth+1))
+		}
+		return out
+	case typeStruct:
+		return d.structure(depth)
+	}
+	d.fail(fmt.Errorf("parquet: unknown type %d in metadata", typ))
+	return nil
+}
+
+func (d *decoder) elements(typ byte, n uint64, depth int) []any {
+	// every element takes at least a byte, so a huge count is corrupt
+	if d.err != nil || n > uint64(len(d.b)-d.pos) {
+		d.fail(errShort)
+		return nil
+	}
+	out := make([]any, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		out = append(out, d.value(typ, depth+1))
+	}
+	return out
+}
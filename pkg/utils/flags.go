@@ -6,6 +6,19 @@ import (
 	"os"
 )
 
+// Flags holds the parsed command line flags
+type Flags struct {
+	Env       *bool
+	Cred      *bool
+	Profile   *string
+	Region    *string
+	Endpoint  *string
+	PathStyle *bool
+	Insecure  *bool
+	CABundle  *string
+	Columns   *string
+}
+
 func usage() {
 	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 	flag.PrintDefaults()
@@ -14,14 +27,21 @@ func usage() {
 	fmt.Println("\nPositional Arguments:")
 	fmt.Println("  arg1        AWS Access Key ID")
 	fmt.Println("  arg2        AWS Secret Access Key")
-	fmt.Println("  arg3        AWS SSO (Optional)")
+	fmt.Println("  arg3        AWS Session Token (Optional)")
 }
 
-func ParseFlags() (envPtr *bool, credPtr *bool, profilePtr *string) {
-	envPtr = flag.Bool("E", false, "Use AWS credentials from environment variables")
-	credPtr = flag.Bool("c", false, "Use ephemeral AWS credentials from positional arguments")
-	profilePtr = flag.String("p", "default", "Credential profile to select from .aws/credentials. Defaults to \"Default\", or the first found, if no flags are provided.")
+func ParseFlags() Flags {
+	var f Flags
+	f.Env = flag.Bool("E", false, "Use AWS credentials from environment variables")
+	f.Cred = flag.Bool("c", false, "Use ephemeral AWS credentials from positional arguments")
+	f.Profile = flag.String("p", "default", "Profile to select from .aws/credentials or .aws/config. Defaults to \"Default\", or the first found, if no flags are provided.")
+	f.Region = flag.String("region", "", "AWS region to use. Defaults to AWS_REGION, then the profile's region in .aws/config, then us-east-1. Buckets in other regions are detected automatically.")
+	f.Endpoint = flag.String("endpoint", "", "Custom S3 endpoint URL, e.g. http://localhost:9000 for MinIO or LocalStack")
+	f.PathStyle = flag.Bool("path-style", false, "Use path-style addressing (host/bucket/key) instead of virtual-hosted buckets")
+	f.Insecure = flag.Bool("insecure", false, "Skip TLS certificate verification")
+	f.CABundle = flag.String("ca-bundle", "", "PEM file of extra CA certificates to trust")
+	f.Columns = flag.String("columns", "size,modified,class", "Columns to show in the files pane, any of size,modified,class,etag")
 	flag.Usage = usage
 	flag.Parse()
-	return
+	return f
 }
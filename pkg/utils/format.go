@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HumanBytes formats a byte count using binary units, e.g. 1536 -> "1.5 KiB"
+func HumanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseBytes reads a size like "512", "1.5KiB", "10 MB" or "2G". KB, MB... are
+// powers of 1000, KiB, MiB... and the bare K, M... powers of 1024.
+func ParseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := s, ""
+	if i >= 0 {
+		number, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q isn't a size", s)
+	}
+	unit = strings.ToUpper(unit)
+	if unit == "" || unit == "B" {
+		return int64(n), nil
+	}
+	exp := strings.IndexByte("KMGTPE", unit[0])
+	if exp < 0 {
+		return 0, fmt.Errorf("%q isn't a size", s)
+	}
+	base := 1024.0
+	switch unit[1:] {
+	case "", "IB":
+	case "B":
+		base = 1000
+	default:
+		return 0, fmt.Errorf("%q isn't a size", s)
+	}
+	for ; exp >= 0; exp-- {
+		n *= base
+	}
+	return int64(n), nil
+}
+
+// Percent returns done as a percentage of total, treating an empty total as finished
+func Percent(done int64, total int64) int {
+	if total <= 0 {
+		return 100
+	}
+	return int(done * 100 / total)
+}
+
+// HumanTime says roughly how long before now t was, e.g. "3h ago", falling back
+// to the date once it's more than a month old
+func HumanTime(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch ago := now.Sub(t); {
+	case ago < time.Minute:
+		return "just now"
+	case ago < time.Hour:
+		return fmt.Sprintf("%dm ago", int(ago/time.Minute))
+	case ago < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(ago/time.Hour))
+	case ago < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(ago/(24*time.Hour)))
+	case t.Year() == now.Year():
+		return t.Format("Jan 02")
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// HexDump lays data out like hexdump -C, perRow bytes to a line with the
+// offset in the object on the left and the printable bytes on the right.
+// offset is where data starts. Rows line up on multiples of perRow so the
+// first one can start part way along.
+func HexDump(data []byte, offset int64, perRow int) []string {
+	var rows []string
+	for i := 0; i < len(data); {
+		rowStart := offset + int64(i) - (offset+int64(i))%int64(perRow)
+		skip := int(offset + int64(i) - rowStart)
+		n := perRow - skip
+		if n > len(data)-i {
+			n = len(data) - i
+		}
+		rows = append(rows, hexRow(data[i:i+n], rowStart, skip, perRow))
+		i += n
+	}
+	return rows
+}
+
+// HexRowEnds is where each row of HexDump ends, counting from the start of data
+func HexRowEnds(length int, offset int64, perRow int) []int {
+	var ends []int
+	for i := 0; i < length; {
+		i += perRow - int((offset+int64(i))%int64(perRow))
+		if i > length {
+			i = length
+		}
+		ends = append(ends, i)
+	}
+	return ends
+}
+
+// hexRow is one row of a dump, with skip blank bytes before row
+func hexRow(row []byte, at int64, skip int, perRow int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x  ", at)
+	for i := 0; i < perRow; i++ {
+		if i > 0 && i%8 == 0 {
+			b.WriteByte(' ')
+		}
+		if i < skip || i >= skip+len(row) {
+			b.WriteString("   ")
+		} else {
+			fmt.Fprintf(&b, "%02x ", row[i-skip])
+		}
+	}
+	b.WriteString(" |")
+	b.WriteString(strings.Repeat(" ", skip))
+	for _, c := range row {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteString(strings.Repeat(" ", perRow-skip-len(row)))
+	b.WriteByte('|')
+	return b.String()
+}
+
+// HexRowWidth is how many cells a HexDump row takes up
+func HexRowWidth(perRow int) int {
+	return 8 + 2 + perRow*3 + (perRow-1)/8 + 2 + perRow + 1
+}
+
+// fileMagic is what common formats start with
+var fileMagic = []struct {
+	kind  string
+	magic []byte
+}{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"zip", []byte("PK\x03\x04")},
+	{"zip", []byte("PK\x05\x06")},
+	{"parquet", []byte("PAR1")},
+	{"png", []byte("\x89PNG\r\n\x1a\n")},
+	{"pdf", []byte("%PDF-")},
+	{"jpeg", []byte{0xff, 0xd8, 0xff}},
+	{"gif", []byte("GIF8")},
+	{"bzip2", []byte("BZh")},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{"xz", []byte("\xfd7zXZ\x00")},
+	{"avro", []byte("Obj\x01")},
+}
+
+// DetectFileType names the format head, the first bytes of a file, is in by
+// its magic number. It's "" for anything it doesn't know.
+func DetectFileType(head []byte) string {
+	for _, m := range fileMagic {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.kind
+		}
+	}
+	return ""
+}
@@ -4,15 +4,29 @@ import (
 	"unicode/utf8"
 )
 
-func isBinaryFile(preview []byte) bool {
+// IsBinary says whether preview can't be shown as text
+func IsBinary(preview []byte) bool {
 	return !utf8.Valid(preview)
 }
 
-func ParsePreview(preview []byte) []byte {
-	bin := isBinaryFile(preview)
-
-	if bin {
-		return []byte("Cannot preview binary file")
+// RuneBounds trims the partial runes a byte range can cut at its edges. head
+// and tail say whether data was cut from a longer run at that end.
+func RuneBounds(data []byte, head bool, tail bool) (int, int) {
+	from, to := 0, len(data)
+	if head {
+		for from < len(data) && from < utf8.UTFMax && !utf8.RuneStart(data[from]) {
+			from++
+		}
+	}
+	if tail {
+		// back up to the start of the last rune and keep it if it's whole
+		i := to - 1
+		for i > from && to-i < utf8.UTFMax && !utf8.RuneStart(data[i]) {
+			i--
+		}
+		if i >= from && !utf8.FullRune(data[i:to]) {
+			to = i
+		}
 	}
-	return preview
+	return from, to
 }